@@ -2,10 +2,17 @@ module github.com/CaiqueRibeiro/client-api-ex/client
 
 go 1.21
 
-require github.com/stretchr/testify v1.10.0
+require (
+	github.com/CaiqueRibeiro/client-api-ex/shared v0.0.0
+	github.com/gorilla/websocket v1.5.3
+	github.com/stretchr/testify v1.10.0
+)
 
 require (
+	github.com/BurntSushi/toml v1.4.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace github.com/CaiqueRibeiro/client-api-ex/shared => ../shared
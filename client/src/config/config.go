@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/client/src/logging"
+	"github.com/CaiqueRibeiro/client-api-ex/client/src/usecases"
+	"github.com/CaiqueRibeiro/client-api-ex/shared/configloader"
+)
+
+// EnvPrefix prefixa as variáveis de ambiente do cliente (ex.: QUOTATION_CLIENT_SERVER)
+const EnvPrefix = "QUOTATION_CLIENT_"
+
+// maxRequestIDLength é o maior identificador que o servidor aceita
+const maxRequestIDLength = 128
+
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// Config reúne as opções do cliente. Cada campo é uma opção de linha de
+// comando, uma chave do arquivo de configuração com o mesmo nome e uma
+// variável de ambiente (configloader.EnvName). Precedência: flag > ambiente > arquivo > padrão.
+type Config struct {
+	Server     string
+	Output     string
+	Timeout    time.Duration
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	RequestID  string
+	LogFormat  string
+	LogLevel   string
+	// Subscribe lista os pares acompanhados por WebSocket; vazio busca a
+	// cotação uma vez e a salva em Output
+	Subscribe string
+	WSServer  string
+
+	// ConfigFile é o arquivo lido, se houver
+	ConfigFile string
+	// PrintConfig pede que a configuração efetiva seja impressa em vez de
+	// buscar a cotação
+	PrintConfig bool
+
+	flags   *flag.FlagSet
+	sources map[string]string
+}
+
+// Default devolve a configuração usada quando nenhuma fonte define uma opção
+func Default() Config {
+	useCase := usecases.NewGetQuotationUseCase()
+	subscriber := usecases.NewSubscribeQuotationsUseCase()
+
+	return Config{
+		Server:     useCase.ServerURL,
+		Output:     useCase.OutputPath,
+		Timeout:    useCase.Timeout,
+		Attempts:   useCase.Retry.MaxAttempts,
+		Backoff:    useCase.Retry.InitialBackoff,
+		MaxBackoff: useCase.Retry.MaxBackoff,
+		LogFormat:  logging.FormatLogfmt,
+		LogLevel:   "info",
+		WSServer:   subscriber.ServerURL,
+	}
+}
+
+// Load monta a configuração a partir dos padrões, do arquivo indicado por
+// -config ou QUOTATION_CLIENT_CONFIG, das variáveis de ambiente e de args, e a
+// valida. Com -h devolve flag.ErrHelp depois de imprimir o uso.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	cfg.flags = flag.NewFlagSet("client", flag.ContinueOnError)
+	cfg.bind(cfg.flags)
+	cfg.flags.StringVar(&cfg.ConfigFile, configloader.ConfigOption, "", "YAML (.yaml, .yml) or TOML (.toml) file whose keys are the option names below")
+	cfg.flags.BoolVar(&cfg.PrintConfig, configloader.PrintConfigOption, false, "Print the effective configuration, with the source of each value, and exit")
+	cfg.flags.Usage = func() {
+		fmt.Fprintf(cfg.flags.Output(), "Usage: client [options]\n\nEvery option can also be set in the -config file or as %s<OPTION> (e.g. %s).\nPrecedence: flag > environment > file > default.\n\n",
+			EnvPrefix, configloader.EnvName(EnvPrefix, "server"))
+		cfg.flags.PrintDefaults()
+	}
+
+	sources, err := configloader.Load(cfg.flags, EnvPrefix, args, lookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	cfg.sources = sources
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// bind registra uma opção de linha de comando para cada campo
+func (c *Config) bind(flags *flag.FlagSet) {
+	flags.StringVar(&c.Server, "server", c.Server, "URL of the quotation server")
+	flags.StringVar(&c.Output, "output", c.Output, "Path to save the quotation")
+	flags.DurationVar(&c.Timeout, "timeout", c.Timeout, "Overall deadline for fetching the quotation, including retries")
+	flags.IntVar(&c.Attempts, "attempts", c.Attempts, "Maximum number of attempts on transient failures (1 disables retries)")
+	flags.DurationVar(&c.Backoff, "backoff", c.Backoff, "Wait before the first retry; doubles on each attempt")
+	flags.DurationVar(&c.MaxBackoff, "max-backoff", c.MaxBackoff, "Upper bound for the wait between retries")
+	flags.StringVar(&c.RequestID, "request-id", c.RequestID, "Value sent in the X-Request-ID header and logged with every line (empty generates one)")
+	flags.StringVar(&c.LogFormat, "log-format", c.LogFormat, "Log output format: logfmt or json")
+	flags.StringVar(&c.LogLevel, "log-level", c.LogLevel, "Minimum log level: debug, info, warn or error")
+	flags.StringVar(&c.Subscribe, "subscribe", c.Subscribe, "Pairs to follow over WebSocket, separated by commas (e.g. USD-BRL,EUR-BRL), until interrupted; empty fetches the quotation once")
+	flags.StringVar(&c.WSServer, "ws-server", c.WSServer, "WebSocket URL of the quotation server, used with -subscribe")
+}
+
+// Validate confere todas as opções e devolve todos os problemas encontrados
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(option, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{option}, args...)...))
+	}
+
+	if u, err := url.Parse(c.Server); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("server", "must be an http or https URL, got %q", c.Server)
+	}
+	if u, err := url.Parse(c.WSServer); err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		invalid("ws-server", "must be a ws or wss URL, got %q", c.WSServer)
+	}
+	if c.Subscribe != "" && slices.Contains(c.SubscribePairs(), "") {
+		invalid("subscribe", "must be pairs separated by commas, got %q", c.Subscribe)
+	}
+	if c.Output == "" {
+		invalid("output", "must not be empty")
+	}
+	if c.Timeout <= 0 {
+		invalid("timeout", "must be positive, got %s", c.Timeout)
+	}
+	if c.Attempts < 1 {
+		invalid("attempts", "must be at least 1, got %d", c.Attempts)
+	}
+	if c.Backoff < 0 {
+		invalid("backoff", "must not be negative, got %s", c.Backoff)
+	}
+	if c.MaxBackoff < c.Backoff {
+		invalid("max-backoff", "must not be shorter than backoff (%s), got %s", c.Backoff, c.MaxBackoff)
+	}
+	if !validRequestID(c.RequestID) {
+		invalid("request-id", "must have at most %d visible ASCII characters", maxRequestIDLength)
+	}
+	if _, err := logging.ParseFormat(c.LogFormat); err != nil {
+		invalid("log-format", "%v", err)
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		invalid("log-level", "%v", err)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return fmt.Errorf("%w: %w", ErrInvalidConfig, errors.Join(errs...))
+}
+
+// SubscribePairs devolve os pares de Subscribe, sem espaços
+func (c *Config) SubscribePairs() []string {
+	if c.Subscribe == "" {
+		return nil
+	}
+	pairs := strings.Split(c.Subscribe, ",")
+	for i := range pairs {
+		pairs[i] = strings.TrimSpace(pairs[i])
+	}
+	return pairs
+}
+
+// validRequestID aceita o identificador vazio (gerado a cada execução) ou um
+// que o servidor reaproveite em vez de substituir
+func validRequestID(id string) bool {
+	if len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Source devolve a origem do valor efetivo da opção (configloader.SourceDefault,
+// SourceFile, SourceEnv ou SourceFlag), ou "" se a opção não existe ou a
+// configuração não veio de Load
+func (c *Config) Source(option string) string {
+	return c.sources[option]
+}
+
+// Print escreve a configuração efetiva em YAML, com a origem de cada valor. A
+// senha das URLs do servidor com credenciais é omitida.
+func (c *Config) Print(w io.Writer) error {
+	if c.flags == nil {
+		return errors.New("configuration was not loaded")
+	}
+	return configloader.PrintEffective(w, c.flags, EnvPrefix, c.sources, func(option, value string) string {
+		if option != "server" && option != "ws-server" {
+			return value
+		}
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+		return value
+	})
+}
@@ -0,0 +1,65 @@
+package entities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+// canonicalDecimal é a forma em que o servidor envia preços: sem sinal de +,
+// zeros à esquerda, expoente ou separador de milhar
+var canonicalDecimal = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+
+// Decimal é um preço como o servidor o envia, na forma canônica em texto, que
+// preserva todas as casas (5.8500 continua 5.8500). O cliente só exibe e grava
+// o valor, então não faz aritmética com ele.
+type Decimal string
+
+// ParseDecimal aceita apenas a forma canônica, como "5.8576" ou "-0.0313"
+func ParseDecimal(value string) (Decimal, error) {
+	if !canonicalDecimal.MatchString(value) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDecimal, value)
+	}
+	return Decimal(value), nil
+}
+
+func (d Decimal) String() string {
+	if d == "" {
+		return "0"
+	}
+	return string(d)
+}
+
+// IsZero informa se o valor é zero, inclusive quando não foi preenchido
+func (d Decimal) IsZero() bool {
+	return strings.Trim(string(d), "-0.") == ""
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON aceita a string canônica enviada pelo servidor ("5.8576")
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = ""
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDecimal, data)
+	}
+
+	parsed, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
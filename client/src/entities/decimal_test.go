@@ -0,0 +1,70 @@
+package entities
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: "5.8576"},
+		{value: "5.8500"},
+		{value: "-0.0313"},
+		{value: "0.00"},
+		{value: "350123"},
+		{value: "", wantErr: true},
+		{value: "Internal Server Error", wantErr: true},
+		{value: "1e5", wantErr: true},
+		{value: "5,8576", wantErr: true},
+		{value: "5.", wantErr: true},
+		{value: "+12", wantErr: true},
+		{value: "007.10", wantErr: true},
+		{value: " 5.8576\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			d, err := ParseDecimal(tt.value)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidDecimal)
+				return
+			}
+
+			require.NoError(t, err)
+			// The value is kept exactly as the server sent it
+			assert.Equal(t, tt.value, d.String())
+		})
+	}
+}
+
+func TestDecimalIsZero(t *testing.T) {
+	assert.True(t, Decimal("").IsZero())
+	assert.True(t, Decimal("0.0000").IsZero())
+	assert.False(t, Decimal("0.0001").IsZero())
+	assert.False(t, Decimal("-5.8576").IsZero())
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var decoded struct {
+		Bid Decimal `json:"bid"`
+		Ask Decimal `json:"ask"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"bid":"5.8500","ask":"5.8582"}`), &decoded))
+	assert.Equal(t, "5.8500", decoded.Bid.String())
+	assert.Equal(t, "5.8582", decoded.Ask.String())
+
+	data, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"bid":"5.8500","ask":"5.8582"}`, string(data))
+
+	// The server always sends prices as strings
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"bid":5.8582}`), &decoded), ErrInvalidDecimal)
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"bid":"abc"}`), &decoded), ErrInvalidDecimal)
+}
@@ -1,5 +1,25 @@
 package entities
 
+import "time"
+
+// Variation é a variação do preço informada pelo provedor
+type Variation struct {
+	Absolute Decimal `json:"absolute"`
+	Percent  Decimal `json:"percent"`
+}
+
+// Quotation espelha o corpo versionado devolvido por GET /cotacao
 type Quotation struct {
-	Bid string
+	Pair              string    `json:"pair"`
+	Bid               Decimal   `json:"bid"`
+	Ask               Decimal   `json:"ask"`
+	High              Decimal   `json:"high"`
+	Low               Decimal   `json:"low"`
+	Variation         Variation `json:"variation"`
+	ProviderTimestamp time.Time `json:"provider_timestamp"`
+	FetchedAt         time.Time `json:"fetched_at"`
+	Source            string    `json:"source"`
+	Provider          string    `json:"provider,omitempty"`
+	// Stale indica uma cotação armazenada servida enquanto os provedores estão fora
+	Stale bool `json:"stale,omitempty"`
 }
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Formatos de saída aceitos por New
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// RequestIDHeader é o cabeçalho que leva ao servidor o identificador da busca
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey é o atributo que identifica a busca em cada linha de log, o
+// mesmo usado pelo servidor
+const RequestIDKey = "request_id"
+
+var (
+	ErrInvalidFormat = errors.New("invalid log format")
+	ErrInvalidLevel  = errors.New("invalid log level")
+)
+
+// ParseFormat aceita "json" ou "logfmt"
+func ParseFormat(value string) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(value)); format {
+	case FormatJSON, FormatLogfmt:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w %q: expected json or logfmt", ErrInvalidFormat, value)
+	}
+}
+
+// ParseLevel aceita debug, info, warn ou error
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return 0, fmt.Errorf("%w %q: expected debug, info, warn or error", ErrInvalidLevel, value)
+	}
+	return level, nil
+}
+
+// New cria um logger no formato informado que descarta registros abaixo de level
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+
+	switch format {
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case FormatLogfmt:
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("%w %q: expected json or logfmt", ErrInvalidFormat, format)
+	}
+}
+
+// NewRequestID gera um identificador aleatório de 128 bits em hexadecimal
+func NewRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		// crypto/rand não falha nas plataformas suportadas; se falhar, o
+		// identificador fica vazio e o servidor gera um próprio
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		wantErr  bool
+	}{
+		{value: "json", expected: FormatJSON},
+		{value: " LOGFMT ", expected: FormatLogfmt},
+		{value: "text", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			format, err := ParseFormat(tt.value)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidFormat)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, format)
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected slog.Level
+		wantErr  bool
+	}{
+		{value: "debug", expected: slog.LevelDebug},
+		{value: "INFO", expected: slog.LevelInfo},
+		{value: "warn", expected: slog.LevelWarn},
+		{value: "error", expected: slog.LevelError},
+		{value: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			level, err := ParseLevel(tt.value)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLevel)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, level)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+
+	logger.Info("Cotação obtida", RequestIDKey, "req-1")
+	logger.Debug("Descartado pelo nível")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "Cotação obtida", record["msg"])
+	assert.Equal(t, "req-1", record[RequestIDKey])
+
+	out.Reset()
+	logger, err = New(&out, FormatLogfmt, slog.LevelDebug)
+	require.NoError(t, err)
+	logger.Debug("Tentativa falhou", "attempt", 1)
+	assert.Contains(t, out.String(), `level=DEBUG msg="Tentativa falhou" attempt=1`)
+
+	_, err = New(&out, "xml", slog.LevelInfo)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestNewRequestID(t *testing.T) {
+	first := NewRequestID()
+	second := NewRequestID()
+
+	assert.Regexp(t, `^[0-9a-f]{32}$`, first)
+	assert.NotEqual(t, first, second)
+}
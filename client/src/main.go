@@ -1,37 +1,152 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/CaiqueRibeiro/client-api-ex/client/src/config"
+	"github.com/CaiqueRibeiro/client-api-ex/client/src/logging"
 	"github.com/CaiqueRibeiro/client-api-ex/client/src/usecases"
 )
 
+// Códigos de saída do processo, um por tipo de falha
+const (
+	exitOK             = 0
+	exitFailure        = 1
+	exitInvalidConfig  = 2
+	exitTimeout        = 3
+	exitUnreachable    = 4
+	exitServerError    = 5
+	exitInvalidPayload = 6
+)
+
+// exitCode traduz o erro da busca da cotação no código de saída correspondente
+func exitCode(err error) int {
+	var serverErr *usecases.ServerError
+	var subscriptionErr *usecases.SubscriptionError
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, usecases.ErrTimeout):
+		return exitTimeout
+	case errors.Is(err, usecases.ErrUnreachable):
+		return exitUnreachable
+	case errors.As(err, &serverErr), errors.As(err, &subscriptionErr):
+		return exitServerError
+	case errors.Is(err, usecases.ErrInvalidPayload):
+		return exitInvalidPayload
+	default:
+		return exitFailure
+	}
+}
+
 func main() {
-	// Analisa os flags da linha de comando
-	serverURL := flag.String("server", "http://localhost:8080/cotacao", "URL of the quotation server")
-	outputPath := flag.String("output", "cotacao.txt", "Path to save the quotation")
-	flag.Parse()
+	// Junta padrões, arquivo de configuração, ambiente e flags
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(exitOK)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(exitInvalidConfig)
+	}
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+			os.Exit(exitFailure)
+		}
+		os.Exit(exitOK)
+	}
+
+	logger, err := newLogger(cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(exitInvalidConfig)
+	}
+
+	if cfg.Subscribe != "" {
+		os.Exit(subscribe(cfg, logger))
+	}
 
-	// Cria um caso de uso personalizado com a URL do servidor e caminho de saída fornecidos
+	// Cria um caso de uso personalizado com a URL do servidor e caminho de saída configurados
 	getQuotationUseCase := &usecases.GetQuotationUseCase{
-		ServerURL:  *serverURL,
-		OutputPath: *outputPath,
+		ServerURL:  cfg.Server,
+		OutputPath: cfg.Output,
+		Timeout:    cfg.Timeout,
+		Retry: usecases.RetryPolicy{
+			MaxAttempts:    cfg.Attempts,
+			InitialBackoff: cfg.Backoff,
+			MaxBackoff:     cfg.MaxBackoff,
+		},
+		RequestID: cfg.RequestID,
+		Logger:    logger,
 	}
 
+	// Em caso de falha o arquivo de saída não é tocado
 	quotation, err := getQuotationUseCase.Execute()
 	if err != nil {
-		log.Fatalf("Failed to get quotation: %v", err)
+		logger.Error("Failed to get quotation", logging.RequestIDKey, getQuotationUseCase.RequestID, "error", err)
+		os.Exit(exitCode(err))
 	}
 
-	fmt.Printf("USD-BRL quotation: %s\n", quotation.Bid)
+	// O par vem da resposta: -server pode apontar para /cotacao/{pair}
+	fmt.Println(usecases.FormatQuotation(quotation))
 
 	// Salva a cotação no arquivo especificado
 	err = getQuotationUseCase.SaveQuotationToFile(quotation)
 	if err != nil {
-		log.Fatalf("Failed to save quotation to file: %v", err)
+		logger.Error("Failed to save quotation to file", logging.RequestIDKey, getQuotationUseCase.RequestID, "error", err)
+		os.Exit(exitFailure)
+	}
+
+	fmt.Printf("Quotation saved to %s\n", cfg.Output)
+}
+
+// subscribe acompanha os pares de -subscribe pela WebSocket do servidor até
+// SIGINT ou SIGTERM, imprimindo cada cotação recebida
+func subscribe(cfg *config.Config, logger *slog.Logger) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	subscribeUseCase := usecases.NewSubscribeQuotationsUseCase(cfg.SubscribePairs()...)
+	subscribeUseCase.ServerURL = cfg.WSServer
+	subscribeUseCase.Reconnect = usecases.RetryPolicy{
+		MaxAttempts:    cfg.Attempts,
+		InitialBackoff: cfg.Backoff,
+		MaxBackoff:     cfg.MaxBackoff,
+	}
+	subscribeUseCase.RequestID = cfg.RequestID
+	subscribeUseCase.Logger = logger
+
+	err := subscribeUseCase.Execute(ctx, func(event usecases.QuotationEvent) {
+		// Erros informados pelo servidor já são registrados pelo caso de uso
+		if event.Err != nil {
+			return
+		}
+		fmt.Printf("%s (%s)\n", usecases.FormatQuotation(event.Quotation), event.Type)
+	})
+	if err != nil {
+		logger.Error("Quotation subscription failed", logging.RequestIDKey, subscribeUseCase.RequestID, "error", err)
 	}
+	return exitCode(err)
+}
 
-	fmt.Printf("Quotation saved to %s\n", *outputPath)
+// newLogger cria o logger a partir dos flags -log-format e -log-level
+func newLogger(format, level string) (*slog.Logger, error) {
+	parsedFormat, err := logging.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	parsedLevel, err := logging.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return logging.New(os.Stderr, parsedFormat, parsedLevel)
 }
@@ -85,7 +85,7 @@ func (suite *EndToEndTestSuite) TestClientServerIntegration() {
 
 	content, err := os.ReadFile(suite.cotacaoPath)
 	require.NoError(suite.T(), err)
-	assert.Contains(suite.T(), string(content), "Dólar: ")
+	assert.Contains(suite.T(), string(content), "USD-BRL quotation: ")
 
 	db, err := sql.Open("sqlite3", suite.dbPath)
 	if err == nil {
@@ -99,6 +99,27 @@ func (suite *EndToEndTestSuite) TestClientServerIntegration() {
 	}
 }
 
+func (suite *EndToEndTestSuite) TestClientLeavesOutputUntouchedWhenServerIsUnreachable() {
+	outputPath := filepath.Join(suite.tempDir, "unreachable.txt")
+	require.NoError(suite.T(), os.WriteFile(outputPath, []byte("USD-BRL quotation: 5.0000"), 0o644))
+
+	// Nothing listens on this port
+	clientCmd := exec.Command("go", "run", "../../main.go",
+		"-server", "http://localhost:1/cotacao",
+		"-output", outputPath)
+
+	output, err := clientCmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	require.ErrorAs(suite.T(), err, &exitErr, "Client should have failed: %s", output)
+	// go run exits with 1 and prints the program's own exit status
+	assert.Contains(suite.T(), string(output), "quotation server unreachable")
+	assert.Contains(suite.T(), string(output), "exit status 4")
+
+	content, err := os.ReadFile(outputPath)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "USD-BRL quotation: 5.0000", string(content))
+}
+
 func TestEndToEndSuite(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping end-to-end test in short mode")
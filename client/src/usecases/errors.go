@@ -0,0 +1,59 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+var (
+	// ErrTimeout indica que o servidor não respondeu dentro do prazo
+	ErrTimeout = errors.New("quotation request timed out")
+	// ErrUnreachable indica que não foi possível conectar ao servidor
+	ErrUnreachable = errors.New("quotation server unreachable")
+	// ErrInvalidPayload indica que a resposta não segue o contrato esperado
+	ErrInvalidPayload = errors.New("invalid quotation payload")
+)
+
+// ServerError é devolvido quando o servidor responde com status fora da faixa 2xx
+type ServerError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Retryable  bool
+}
+
+func (e *ServerError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("server returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("server returned %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// SubscriptionError é um erro enviado pelo servidor numa conexão WebSocket,
+// como um par não suportado ou uma cotação indisponível
+type SubscriptionError struct {
+	// Pair é o par afetado, quando o erro se refere a um só
+	Pair      string
+	Code      string
+	Message   string
+	Retryable bool
+}
+
+func (e *SubscriptionError) Error() string {
+	if e.Pair == "" {
+		return fmt.Sprintf("server rejected the request: %s: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("server reported an error for %s: %s: %s", e.Pair, e.Code, e.Message)
+}
+
+// classifyTransportError separa estouro de prazo de falhas de conexão
+func classifyTransportError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", ErrUnreachable, err)
+}
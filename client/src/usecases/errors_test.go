@@ -0,0 +1,71 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerError_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      *ServerError
+		expected string
+	}{
+		{
+			name:     "with error body",
+			err:      &ServerError{StatusCode: http.StatusBadGateway, Code: "upstream_unavailable", Message: "upstream unavailable"},
+			expected: "server returned 502: upstream_unavailable: upstream unavailable",
+		},
+		{
+			name:     "without error body",
+			err:      &ServerError{StatusCode: http.StatusInternalServerError},
+			expected: "server returned 500 Internal Server Error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.err.Error())
+		})
+	}
+}
+
+func TestSubscriptionError_Error(t *testing.T) {
+	rejected := &SubscriptionError{Code: "unsupported_pair", Message: `unsupported currency pair: "XYZ-BRL"`}
+	assert.Equal(t, `server rejected the request: unsupported_pair: unsupported currency pair: "XYZ-BRL"`, rejected.Error())
+
+	unavailable := &SubscriptionError{Pair: "USD-BRL", Code: "upstream_unavailable", Message: "timeout", Retryable: true}
+	assert.Equal(t, "server reported an error for USD-BRL: upstream_unavailable: timeout", unavailable.Error())
+}
+
+func TestClassifyTransportError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name:     "deadline exceeded",
+			err:      fmt.Errorf("Get \"http://localhost\": %w", context.DeadlineExceeded),
+			expected: ErrTimeout,
+		},
+		{
+			name:     "connection refused",
+			err:      errors.New("dial tcp 127.0.0.1:1: connect: connection refused"),
+			expected: ErrUnreachable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyTransportError(tt.err)
+			assert.ErrorIs(t, err, tt.expected)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
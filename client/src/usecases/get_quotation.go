@@ -2,57 +2,190 @@ package usecases
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/CaiqueRibeiro/client-api-ex/client/src/entities"
+	"github.com/CaiqueRibeiro/client-api-ex/client/src/logging"
 )
 
+// ResponseVersion é a versão do contrato de resposta entendida pelo cliente
+const ResponseVersion = 1
+
+var ErrUnsupportedVersion = errors.New("unsupported response version")
+
+// quotationResponse é o envelope JSON devolvido pelo servidor, tanto em caso de
+// sucesso (campos da cotação) quanto de erro (campo error)
+type quotationResponse struct {
+	Version int `json:"version"`
+	entities.Quotation
+	Error *errorDetail `json:"error,omitempty"`
+}
+
+type errorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+// DefaultTimeout é o prazo total da busca, somando todas as tentativas
+const DefaultTimeout = 300 * time.Millisecond
+
 type GetQuotationUseCase struct {
 	ServerURL  string
 	OutputPath string
+	Timeout    time.Duration // Zero usa DefaultTimeout
+	Retry      RetryPolicy   // Zero faz uma única tentativa
+	// RequestID é enviado no cabeçalho X-Request-ID de todas as tentativas e
+	// incluído em cada linha de log, para cruzá-las com as do servidor. Vazio
+	// gera um novo identificador na primeira chamada de Execute.
+	RequestID string
+	Logger    *slog.Logger // Nil usa slog.Default()
 }
 
 func NewGetQuotationUseCase() *GetQuotationUseCase {
 	return &GetQuotationUseCase{
 		ServerURL:  "http://localhost:8080/cotacao",
 		OutputPath: "cotacao.txt", // Caminho padrão
+		Timeout:    DefaultTimeout,
+		Retry:      DefaultRetryPolicy,
 	}
 }
 
 func (g *GetQuotationUseCase) Execute() (entities.Quotation, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	if g.RequestID == "" {
+		g.RequestID = logging.NewRequestID()
+	}
+	logger := g.logger()
+
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	maxAttempts := g.Retry.attempts()
+	for attempt := 1; ; attempt++ {
+		quotation, err := g.fetch(ctx, logger)
+		if err == nil {
+			if attempt > 1 {
+				logger.Info("Cotação obtida após nova tentativa", "attempt", attempt, "max_attempts", maxAttempts)
+			}
+			return quotation, nil
+		}
+
+		if attempt == maxAttempts || !isRetryable(err) {
+			logger.Error("Desistindo da busca", "attempts", attempt, "error", err)
+			return entities.Quotation{}, err
+		}
+
+		// Não espera se o prazo total acabaria antes da próxima tentativa
+		delay := g.Retry.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			logger.Error("Desistindo da busca, prazo esgotado", "attempts", attempt, "error", err)
+			return entities.Quotation{}, err
+		}
+
+		logger.Warn("Tentativa falhou, repetindo", "attempt", attempt, "max_attempts", maxAttempts, "backoff", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return entities.Quotation{}, err
+		}
+	}
+}
+
+// fetch faz uma única requisição ao servidor dentro do prazo de ctx
+func (g *GetQuotationUseCase) fetch(ctx context.Context, logger *slog.Logger) (entities.Quotation, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.ServerURL, nil)
 	if err != nil {
-		log.Printf("Erro ao criar requisição: %v", err)
+		logger.Error("Erro ao criar requisição", "error", err)
 		return entities.Quotation{}, err
 	}
+	if g.RequestID != "" {
+		req.Header.Set(logging.RequestIDHeader, g.RequestID)
+	}
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("Erro ao fazer requisição: %v", err)
-		return entities.Quotation{}, err
+		logger.Warn("Erro ao fazer requisição", "error", err)
+		return entities.Quotation{}, classifyTransportError(err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Erro ao ler corpo da resposta: %v", err)
-		return entities.Quotation{}, err
+		logger.Warn("Erro ao ler corpo da resposta", "error", err)
+		return entities.Quotation{}, classifyTransportError(err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		serverErr := newServerError(resp.StatusCode, body)
+		logger.Warn("Servidor retornou erro", "status", resp.StatusCode, "error", serverErr)
+		return entities.Quotation{}, serverErr
 	}
 
-	quotation := entities.Quotation{
-		Bid: string(body),
+	var response quotationResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		logger.Error("Resposta do servidor não é um JSON válido", "error", err)
+		return entities.Quotation{}, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
 	}
 
-	return quotation, nil
+	if response.Version != ResponseVersion {
+		logger.Error("Versão de resposta não suportada", "version", response.Version)
+		return entities.Quotation{}, fmt.Errorf("%w: %w: %d", ErrInvalidPayload, ErrUnsupportedVersion, response.Version)
+	}
+
+	if response.Error != nil || response.Pair == "" || response.Bid.IsZero() {
+		logger.Error("Resposta do servidor não contém uma cotação")
+		return entities.Quotation{}, fmt.Errorf("%w: missing quotation", ErrInvalidPayload)
+	}
+
+	logger.Debug("Cotação recebida", "status", resp.StatusCode, "bid", response.Bid)
+	return response.Quotation, nil
+}
+
+// logger devolve o Logger configurado, ou o padrão, com o request_id da busca
+func (g *GetQuotationUseCase) logger() *slog.Logger {
+	logger := g.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if g.RequestID != "" {
+		logger = logger.With(logging.RequestIDKey, g.RequestID)
+	}
+	return logger
+}
+
+// newServerError usa o corpo de erro estruturado quando o servidor o envia e,
+// caso contrário, apenas o status HTTP
+func newServerError(statusCode int, body []byte) *ServerError {
+	serverErr := &ServerError{StatusCode: statusCode}
+
+	var response quotationResponse
+	if err := json.Unmarshal(body, &response); err == nil && response.Error != nil {
+		serverErr.Code = response.Error.Code
+		serverErr.Message = response.Error.Message
+		serverErr.Retryable = response.Error.Retryable
+	}
+
+	return serverErr
+}
+
+// FormatQuotation descreve a cotação como o cliente a imprime e a salva em
+// arquivo (ex.: "USD-BRL quotation: 5.8576")
+func FormatQuotation(quotation entities.Quotation) string {
+	return fmt.Sprintf("%s quotation: %s", quotation.Pair, quotation.Bid)
 }
 
 func (g *GetQuotationUseCase) SaveQuotationToFile(quotation entities.Quotation) error {
@@ -60,18 +193,31 @@ func (g *GetQuotationUseCase) SaveQuotationToFile(quotation entities.Quotation)
 	if outputPath == "" {
 		outputPath = "cotacao.txt" // Usa o padrão se não estiver definido
 	}
+	logger := g.logger().With("path", outputPath)
 
-	file, err := os.Create(outputPath)
+	// Escreve em um arquivo temporário e renomeia, para que uma falha no meio
+	// da escrita não deixe o arquivo anterior truncado
+	file, err := os.CreateTemp(filepath.Dir(outputPath), filepath.Base(outputPath)+".*.tmp")
 	if err != nil {
-		log.Printf("Erro ao criar arquivo: %v", err)
+		logger.Error("Erro ao criar arquivo", "error", err)
 		return err
 	}
-	defer file.Close()
+	defer os.Remove(file.Name())
 
-	content := fmt.Sprintf("Dólar: %s", quotation.Bid)
-	_, err = file.WriteString(content)
-	if err != nil {
-		log.Printf("Erro ao escrever no arquivo: %v", err)
+	content := FormatQuotation(quotation)
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		logger.Error("Erro ao escrever no arquivo", "error", err)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		logger.Error("Erro ao escrever no arquivo", "error", err)
+		return err
+	}
+
+	if err := os.Rename(file.Name(), outputPath); err != nil {
+		logger.Error("Erro ao salvar arquivo", "error", err)
 		return err
 	}
 
@@ -1,14 +1,22 @@
 package usecases
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/CaiqueRibeiro/client-api-ex/client/src/entities"
+	"github.com/CaiqueRibeiro/client-api-ex/client/src/logging"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -20,11 +28,12 @@ func TestGetQuotationUseCase_Execute(t *testing.T) {
 		serverStatus   int
 		serverDelay    time.Duration
 		expectError    bool
+		expectedErr    error
 		expectedBid    string
 	}{
 		{
 			name:           "success",
-			serverResponse: "5.8576",
+			serverResponse: quotationJSON("5.8576"),
 			serverStatus:   http.StatusOK,
 			serverDelay:    0,
 			expectError:    false,
@@ -35,15 +44,67 @@ func TestGetQuotationUseCase_Execute(t *testing.T) {
 			serverResponse: "Internal Server Error",
 			serverStatus:   http.StatusInternalServerError,
 			serverDelay:    0,
-			expectError:    false, // No error because we still get a response body
-			expectedBid:    "Internal Server Error",
+			expectError:    true,
+			expectedErr:    &ServerError{StatusCode: http.StatusInternalServerError},
+			expectedBid:    "",
 		},
 		{
-			name:           "timeout",
+			name:           "structured server error",
+			serverResponse: `{"version": 1, "error": {"code": "upstream_unavailable", "message": "upstream unavailable: timeout", "retryable": true}}`,
+			serverStatus:   http.StatusBadGateway,
+			serverDelay:    0,
+			expectError:    true,
+			expectedErr: &ServerError{
+				StatusCode: http.StatusBadGateway,
+				Code:       "upstream_unavailable",
+				Message:    "upstream unavailable: timeout",
+				Retryable:  true,
+			},
+			expectedBid: "",
+		},
+		{
+			name:           "malformed bid",
+			serverResponse: quotationJSON("5,8576"),
+			serverStatus:   http.StatusOK,
+			serverDelay:    0,
+			expectError:    true,
+			expectedErr:    ErrInvalidPayload,
+			expectedBid:    "",
+		},
+		{
+			name:           "bare bid body",
 			serverResponse: "5.8576",
 			serverStatus:   http.StatusOK,
+			serverDelay:    0,
+			expectError:    true,
+			expectedErr:    ErrInvalidPayload,
+			expectedBid:    "",
+		},
+		{
+			name:           "missing pair",
+			serverResponse: `{"version": 1, "bid": "5.8576"}`,
+			serverStatus:   http.StatusOK,
+			serverDelay:    0,
+			expectError:    true,
+			expectedErr:    ErrInvalidPayload,
+			expectedBid:    "",
+		},
+		{
+			name:           "unsupported version",
+			serverResponse: `{"version": 2, "pair": "USD-BRL", "bid": "5.8576"}`,
+			serverStatus:   http.StatusOK,
+			serverDelay:    0,
+			expectError:    true,
+			expectedErr:    ErrInvalidPayload,
+			expectedBid:    "",
+		},
+		{
+			name:           "timeout",
+			serverResponse: quotationJSON("5.8576"),
+			serverStatus:   http.StatusOK,
 			serverDelay:    400 * time.Millisecond, // More than the 300ms timeout
 			expectError:    true,
+			expectedErr:    ErrTimeout,
 			expectedBid:    "",
 		},
 	}
@@ -68,17 +129,211 @@ func TestGetQuotationUseCase_Execute(t *testing.T) {
 
 			// Check if we expected an error
 			if tt.expectError {
-				assert.Error(t, err)
+				var serverErr *ServerError
+				if expected, ok := tt.expectedErr.(*ServerError); ok {
+					require.ErrorAs(t, err, &serverErr)
+					assert.Equal(t, expected, serverErr)
+				} else {
+					assert.ErrorIs(t, err, tt.expectedErr)
+					assert.False(t, errors.As(err, &serverErr))
+				}
 				return
 			}
 
 			// Check the result
 			require.NoError(t, err)
-			assert.Equal(t, tt.expectedBid, quotation.Bid)
+			assert.Equal(t, "USD-BRL", quotation.Pair)
+			assert.Equal(t, tt.expectedBid, quotation.Bid.String())
+			assert.Equal(t, "5.8600", quotation.Ask.String())
+			assert.Equal(t, "live", quotation.Source)
+			assert.Equal(t, time.Date(2023, 11, 29, 17, 29, 2, 0, time.UTC), quotation.ProviderTimestamp)
 		})
 	}
 }
 
+func TestGetQuotationUseCase_ExecuteUnreachable(t *testing.T) {
+	// Start and immediately close a server so its address refuses connections
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	useCase := &GetQuotationUseCase{
+		ServerURL: server.URL,
+	}
+
+	_, err := useCase.Execute()
+	assert.ErrorIs(t, err, ErrUnreachable)
+}
+
+func TestGetQuotationUseCase_ExecuteRetries(t *testing.T) {
+	retry := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name             string
+		statuses         []int // Status per attempt; the last one repeats
+		expectedAttempts int32
+		expectError      bool
+	}{
+		{
+			name:             "recovers after transient failures",
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			expectedAttempts: 3,
+		},
+		{
+			name:             "gives up after max attempts",
+			statuses:         []int{http.StatusGatewayTimeout},
+			expectedAttempts: 3,
+			expectError:      true,
+		},
+		{
+			name:             "does not retry client errors",
+			statuses:         []int{http.StatusBadRequest},
+			expectedAttempts: 1,
+			expectError:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1))
+				status := tt.statuses[min(n, len(tt.statuses))-1]
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					w.Write([]byte(quotationJSON("5.8576")))
+				}
+			}))
+			defer server.Close()
+
+			useCase := &GetQuotationUseCase{
+				ServerURL: server.URL,
+				Retry:     retry,
+			}
+
+			quotation, err := useCase.Execute()
+
+			assert.Equal(t, tt.expectedAttempts, attempts.Load())
+			if tt.expectError {
+				var serverErr *ServerError
+				assert.ErrorAs(t, err, &serverErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "5.8576", quotation.Bid.String())
+		})
+	}
+}
+
+func TestGetQuotationUseCase_ExecuteSendsRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+	}{
+		{name: "uses the configured ID", requestID: "client-run-42"},
+		{name: "generates an ID when empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var received []string
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				received = append(received, r.Header.Get(logging.RequestIDHeader))
+				mu.Unlock()
+
+				if attempts.Add(1) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(quotationJSON("5.8576")))
+			}))
+			defer server.Close()
+
+			var logs bytes.Buffer
+			logger, err := logging.New(&logs, logging.FormatJSON, slog.LevelDebug)
+			require.NoError(t, err)
+
+			useCase := &GetQuotationUseCase{
+				ServerURL: server.URL,
+				Retry:     RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+				RequestID: tt.requestID,
+				Logger:    logger,
+			}
+
+			_, err = useCase.Execute()
+			require.NoError(t, err)
+
+			if tt.requestID != "" {
+				assert.Equal(t, tt.requestID, useCase.RequestID)
+			}
+			require.NotEmpty(t, useCase.RequestID)
+
+			// Every attempt carries the same ID, so retries can be correlated on the server
+			assert.Equal(t, []string{useCase.RequestID, useCase.RequestID}, received)
+
+			// ...and so does every log line of the fetch
+			lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+			require.NotEmpty(t, lines)
+			for _, line := range lines {
+				var record map[string]any
+				require.NoError(t, json.Unmarshal([]byte(line), &record))
+				assert.Equal(t, useCase.RequestID, record[logging.RequestIDKey], line)
+			}
+		})
+	}
+}
+
+func TestGetQuotationUseCase_ExecuteRetriesWithinDeadline(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// The backoff alone would take far longer than the overall deadline
+	useCase := &GetQuotationUseCase{
+		ServerURL: server.URL,
+		Timeout:   50 * time.Millisecond,
+		Retry: RetryPolicy{
+			MaxAttempts:    10,
+			InitialBackoff: 20 * time.Millisecond,
+			MaxBackoff:     time.Second,
+		},
+	}
+
+	start := time.Now()
+	_, err := useCase.Execute()
+
+	// Depending on timing the last attempt either got a 503 or ran out of time
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	assert.Less(t, attempts.Load(), int32(10))
+}
+
+// quotationJSON builds a version 1 response body with the given bid
+func quotationJSON(bid string) string {
+	return `{
+		"version": 1,
+		"pair": "USD-BRL",
+		"bid": "` + bid + `",
+		"ask": "5.8600",
+		"high": "5.8700",
+		"low": "5.8400",
+		"variation": {"absolute": "0.0123", "percent": "0.21"},
+		"provider_timestamp": "2023-11-29T17:29:02Z",
+		"fetched_at": "2023-11-29T17:29:03Z",
+		"source": "live",
+		"provider": "awesomeapi"
+	}`
+}
+
 // Custom usecase with file path for testing
 type testableGetQuotationUseCase struct {
 	GetQuotationUseCase
@@ -92,7 +347,7 @@ func (g *testableGetQuotationUseCase) SaveQuotationToFile(quotation entities.Quo
 	}
 	defer file.Close()
 
-	content := "Dólar: " + quotation.Bid
+	content := FormatQuotation(quotation)
 	_, err = file.WriteString(content)
 	return err
 }
@@ -113,7 +368,8 @@ func TestGetQuotationUseCase_SaveQuotationToFile(t *testing.T) {
 
 	// Test saving a quotation to a file
 	quotation := entities.Quotation{
-		Bid: "5.8576",
+		Pair: "EUR-BRL",
+		Bid:  entities.Decimal("6.3894"),
 	}
 
 	err = useCase.SaveQuotationToFile(quotation)
@@ -123,8 +379,27 @@ func TestGetQuotationUseCase_SaveQuotationToFile(t *testing.T) {
 	content, err := os.ReadFile(tempFilePath)
 	require.NoError(t, err)
 
-	// Verify the content
-	assert.Equal(t, "Dólar: 5.8576", string(content))
+	// The file names the pair, as the client's output does
+	assert.Equal(t, "EUR-BRL quotation: 6.3894", string(content))
+}
+
+func TestGetQuotationUseCase_SaveQuotationToFileReplacesContent(t *testing.T) {
+	tempDir := t.TempDir()
+	outputPath := filepath.Join(tempDir, "cotacao.txt")
+	require.NoError(t, os.WriteFile(outputPath, []byte("USD-BRL quotation: 5.0000"), 0o644))
+
+	useCase := &GetQuotationUseCase{OutputPath: outputPath}
+	err := useCase.SaveQuotationToFile(entities.Quotation{Pair: "EUR-BRL", Bid: entities.Decimal("6.3894")})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Equal(t, "EUR-BRL quotation: 6.3894", string(content))
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
 }
 
 func TestGetQuotationUseCase_Integration(t *testing.T) {
@@ -134,7 +409,7 @@ func TestGetQuotationUseCase_Integration(t *testing.T) {
 
 	// Start a test server to mock the real server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("5.8576"))
+		w.Write([]byte(quotationJSON("5.8576")))
 	}))
 	defer server.Close()
 
@@ -146,7 +421,7 @@ func TestGetQuotationUseCase_Integration(t *testing.T) {
 	// Execute the use case
 	quotation, err := useCase.Execute()
 	require.NoError(t, err)
-	assert.Equal(t, "5.8576", quotation.Bid)
+	assert.Equal(t, "5.8576", quotation.Bid.String())
 
 	// Test saving to file using a temp file
 	tempDir, err := os.MkdirTemp("", "test_cotacao_*")
@@ -167,5 +442,5 @@ func TestGetQuotationUseCase_Integration(t *testing.T) {
 	// Read and verify the content
 	content, err := os.ReadFile(tempFilePath)
 	require.NoError(t, err)
-	assert.Equal(t, "Dólar: 5.8576", string(content))
+	assert.Equal(t, "USD-BRL quotation: 5.8576", string(content))
 }
@@ -0,0 +1,68 @@
+package usecases
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy define quantas vezes e com que espera entre tentativas a busca da
+// cotação é repetida em falhas transitórias
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy cabe com folga no prazo padrão de 300ms do cliente
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 20 * time.Millisecond,
+	MaxBackoff:     100 * time.Millisecond,
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff devolve a espera antes da próxima tentativa: o valor dobra a cada
+// falha (limitado a MaxBackoff) e metade dele é sorteada, para que vários
+// clientes não repitam a requisição no mesmo instante
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// isRetryable informa se vale a pena repetir a requisição: falhas de conexão,
+// 502/503/504 e erros que o próprio servidor declara como temporários
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrUnreachable) {
+		return true
+	}
+
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		switch serverErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return serverErr.Retryable
+	}
+
+	return false
+}
@@ -0,0 +1,70 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     40 * time.Millisecond,
+	}
+
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{attempt: 1, base: 10 * time.Millisecond},
+		{attempt: 2, base: 20 * time.Millisecond},
+		{attempt: 3, base: 40 * time.Millisecond},
+		{attempt: 4, base: 40 * time.Millisecond}, // Capped at MaxBackoff
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempt %d", tt.attempt), func(t *testing.T) {
+			// Jitter keeps the delay between half and the full exponential value
+			for i := 0; i < 100; i++ {
+				delay := policy.backoff(tt.attempt)
+				assert.GreaterOrEqual(t, delay, tt.base/2)
+				assert.LessOrEqual(t, delay, tt.base)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Attempts(t *testing.T) {
+	assert.Equal(t, 1, RetryPolicy{}.attempts())
+	assert.Equal(t, 3, DefaultRetryPolicy.attempts())
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "unreachable", err: fmt.Errorf("%w: connection refused", ErrUnreachable), expected: true},
+		{name: "bad gateway", err: &ServerError{StatusCode: http.StatusBadGateway}, expected: true},
+		{name: "service unavailable", err: &ServerError{StatusCode: http.StatusServiceUnavailable}, expected: true},
+		{name: "gateway timeout", err: &ServerError{StatusCode: http.StatusGatewayTimeout}, expected: true},
+		{name: "declared retryable", err: &ServerError{StatusCode: http.StatusInternalServerError, Retryable: true}, expected: true},
+		{name: "internal error", err: &ServerError{StatusCode: http.StatusInternalServerError}, expected: false},
+		{name: "bad request", err: &ServerError{StatusCode: http.StatusBadRequest}, expected: false},
+		{name: "timeout", err: fmt.Errorf("%w: deadline exceeded", ErrTimeout), expected: false},
+		{name: "invalid payload", err: fmt.Errorf("%w: bad json", ErrInvalidPayload), expected: false},
+		{name: "other", err: errors.New("boom"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isRetryable(tt.err))
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/client/src/entities"
+	"github.com/CaiqueRibeiro/client-api-ex/client/src/logging"
+	"github.com/gorilla/websocket"
+)
+
+// Tipos de mensagem do protocolo de GET /cotacao/ws
+const (
+	MessageSubscribe     = "subscribe"
+	MessageUnsubscribe   = "unsubscribe"
+	MessageSnapshot      = "snapshot"
+	MessageSubscriptions = "subscriptions"
+	MessageDelta         = "delta"
+	MessageError         = "error"
+)
+
+var ErrNotConnected = errors.New("not connected to the quotation server")
+
+// DefaultReconnectPolicy espera mais que DefaultRetryPolicy: uma assinatura
+// dura o quanto o usuário quiser, e o servidor pode estar reiniciando
+var DefaultReconnectPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// clientMessage é uma mensagem enviada ao servidor
+type clientMessage struct {
+	Type  string   `json:"type"`
+	ID    string   `json:"id,omitempty"`
+	Pairs []string `json:"pairs,omitempty"`
+}
+
+// serverMessage é uma mensagem recebida do servidor
+type serverMessage struct {
+	Version   int                 `json:"version"`
+	Type      string              `json:"type"`
+	ID        string              `json:"id,omitempty"`
+	Pairs     []string            `json:"pairs,omitempty"`
+	Pair      string              `json:"pair,omitempty"`
+	Quotation *entities.Quotation `json:"quotation,omitempty"`
+	Error     *errorDetail        `json:"error,omitempty"`
+}
+
+// QuotationEvent é uma cotação ou um erro recebido por uma assinatura
+type QuotationEvent struct {
+	// Type é MessageSnapshot, MessageDelta ou MessageError
+	Type      string
+	Quotation entities.Quotation
+	// Err é preenchido quando Type é MessageError
+	Err *SubscriptionError
+}
+
+// SubscribeQuotationsUseCase acompanha cotações por WebSocket: assina Pairs,
+// recebe o snapshot de cada par e depois um delta a cada cotação nova. Pares
+// podem ser assinados e cancelados durante a execução; se a conexão cair, o
+// caso de uso reconecta seguindo Reconnect e assina de novo os mesmos pares.
+type SubscribeQuotationsUseCase struct {
+	ServerURL string
+	Pairs     []string
+	// HandshakeTimeout limita cada conexão ao servidor
+	HandshakeTimeout time.Duration
+	// ReadTimeout é quanto tempo a conexão fica sem receber nada, nem ping do
+	// servidor, antes de ser considerada perdida
+	ReadTimeout time.Duration
+	// WriteTimeout limita cada mensagem enviada ao servidor
+	WriteTimeout time.Duration
+	// Reconnect define quantas conexões seguidas podem falhar e a espera entre
+	// elas; a contagem recomeça a cada assinatura confirmada
+	Reconnect RetryPolicy
+	// RequestID é enviado no cabeçalho X-Request-ID de cada conexão e incluído
+	// em cada linha de log. Vazio gera um novo identificador em Execute.
+	RequestID string
+	Logger    *slog.Logger // Nil usa slog.Default()
+
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	pairs  []string
+	nextID int
+}
+
+func NewSubscribeQuotationsUseCase(pairs ...string) *SubscribeQuotationsUseCase {
+	return &SubscribeQuotationsUseCase{
+		ServerURL:        "ws://localhost:8080/cotacao/ws",
+		Pairs:            pairs,
+		HandshakeTimeout: 5 * time.Second,
+		ReadTimeout:      90 * time.Second,
+		WriteTimeout:     5 * time.Second,
+		Reconnect:        DefaultReconnectPolicy,
+	}
+}
+
+// Execute conecta, assina os pares e entrega cada cotação a handle até ctx
+// ser cancelado, quando devolve nil. Devolve erro se o servidor recusar a
+// assinatura inicial ou se Reconnect.MaxAttempts conexões seguidas falharem.
+// handle roda na goroutine de Execute e pode chamar Subscribe e Unsubscribe.
+func (s *SubscribeQuotationsUseCase) Execute(ctx context.Context, handle func(QuotationEvent)) error {
+	if s.RequestID == "" {
+		s.RequestID = logging.NewRequestID()
+	}
+	logger := s.logger()
+
+	s.mu.Lock()
+	s.pairs = mergePairs(s.pairs, s.Pairs)
+	s.mu.Unlock()
+
+	maxAttempts := s.Reconnect.attempts()
+	failures := 0
+	for {
+		established, err := s.session(ctx, logger, handle)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if established {
+			failures = 0
+		}
+
+		// Conexão sem resposta é reconectada; recusas e mensagens fora do
+		// contrato se repetiriam na próxima conexão
+		if !isRetryable(err) && !errors.Is(err, ErrTimeout) {
+			logger.Error("Assinatura encerrada", "error", err)
+			return err
+		}
+
+		failures++
+		if failures >= maxAttempts {
+			logger.Error("Desistindo da assinatura", "attempts", failures, "error", err)
+			return err
+		}
+
+		delay := s.Reconnect.backoff(failures)
+		logger.Warn("Conexão perdida, reconectando", "attempt", failures, "max_attempts", maxAttempts, "backoff", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Subscribe assina mais pares. Sem conexão, eles são assinados na próxima.
+func (s *SubscribeQuotationsUseCase) Subscribe(pairs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pairs = mergePairs(s.pairs, pairs)
+	if s.conn == nil {
+		return nil
+	}
+	_, err := s.send(MessageSubscribe, normalizePairs(pairs))
+	return err
+}
+
+// Unsubscribe cancela os pares informados, ou todos se nenhum for informado
+func (s *SubscribeQuotationsUseCase) Unsubscribe(pairs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pairs = normalizePairs(pairs)
+	if len(pairs) == 0 {
+		s.pairs = nil
+	} else {
+		s.pairs = removePairs(s.pairs, pairs)
+	}
+	if s.conn == nil {
+		return nil
+	}
+	_, err := s.send(MessageUnsubscribe, pairs)
+	return err
+}
+
+// Snapshot pede a cotação atual dos pares informados, ou dos assinados se
+// nenhum for informado; ela chega a handle como MessageSnapshot
+func (s *SubscribeQuotationsUseCase) Snapshot(pairs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return ErrNotConnected
+	}
+	_, err := s.send(MessageSnapshot, normalizePairs(pairs))
+	return err
+}
+
+// session faz uma conexão e lê suas mensagens até ela cair ou ctx ser
+// cancelado. established informa se a conexão chegou a ser confirmada pelo
+// servidor, para que a contagem de falhas recomece.
+func (s *SubscribeQuotationsUseCase) session(ctx context.Context, logger *slog.Logger, handle func(QuotationEvent)) (established bool, err error) {
+	conn, subscribeID, err := s.connect(ctx, logger)
+	if err != nil {
+		return false, err
+	}
+	defer s.disconnect(conn)
+	// Sem pares para assinar não há confirmação a esperar
+	established = subscribeID == ""
+
+	// Cancelar ctx fecha a conexão educadamente e interrompe a leitura
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline(s.WriteTimeout))
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	extend := func() error {
+		return conn.SetReadDeadline(deadline(s.ReadTimeout))
+	}
+	conn.SetPingHandler(func(data string) error {
+		if err := extend(); err != nil {
+			return err
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), deadline(s.WriteTimeout))
+	})
+
+	for {
+		if err := extend(); err != nil {
+			return established, classifyTransportError(err)
+		}
+
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return established, nil
+			}
+			if websocket.IsCloseError(err, websocket.CloseGoingAway) {
+				logger.Info("Servidor encerrou a conexão", "error", err)
+			}
+			return established, classifyTransportError(err)
+		}
+
+		var message serverMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			logger.Error("Mensagem do servidor não é um JSON válido", "error", err)
+			return established, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+		}
+
+		if message.Version != ResponseVersion {
+			logger.Error("Versão de mensagem não suportada", "version", message.Version)
+			return established, fmt.Errorf("%w: %w: %d", ErrInvalidPayload, ErrUnsupportedVersion, message.Version)
+		}
+
+		switch message.Type {
+		case MessageSubscriptions:
+			if message.ID == subscribeID {
+				established = true
+			}
+			logger.Debug("Assinaturas confirmadas", "pairs", message.Pairs)
+		case MessageSnapshot, MessageDelta:
+			if message.Quotation == nil || message.Quotation.Bid.IsZero() {
+				logger.Error("Mensagem do servidor não contém uma cotação", "type", message.Type)
+				return established, fmt.Errorf("%w: missing quotation in %s", ErrInvalidPayload, message.Type)
+			}
+			logger.Debug("Cotação recebida", "type", message.Type, "pair", message.Quotation.Pair, "bid", message.Quotation.Bid)
+			handle(QuotationEvent{Type: message.Type, Quotation: *message.Quotation})
+		case MessageError:
+			subscriptionErr := &SubscriptionError{Pair: message.Pair}
+			if message.Error != nil {
+				subscriptionErr.Code = message.Error.Code
+				subscriptionErr.Message = message.Error.Message
+				subscriptionErr.Retryable = message.Error.Retryable
+			}
+			// A assinatura inicial recusada não melhora reconectando
+			if message.ID == subscribeID && message.Pair == "" && !subscriptionErr.Retryable {
+				return established, subscriptionErr
+			}
+			logger.Warn("Servidor informou um erro", "error", subscriptionErr)
+			handle(QuotationEvent{Type: MessageError, Err: subscriptionErr})
+		default:
+			// Tipos novos do servidor são ignorados, como campos novos no JSON
+			logger.Debug("Mensagem do servidor ignorada", "type", message.Type)
+		}
+	}
+}
+
+// connect abre a conexão e assina os pares desejados, devolvendo o ID da
+// mensagem de assinatura, ou vazio se não havia pares
+func (s *SubscribeQuotationsUseCase) connect(ctx context.Context, logger *slog.Logger) (*websocket.Conn, string, error) {
+	header := http.Header{}
+	header.Set(logging.RequestIDHeader, s.RequestID)
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: s.HandshakeTimeout,
+	}
+	conn, resp, err := dialer.DialContext(ctx, s.ServerURL, header)
+	if err != nil {
+		if resp != nil {
+			serverErr := &ServerError{StatusCode: resp.StatusCode}
+			logger.Warn("Servidor recusou a conexão", "status", resp.StatusCode, "error", serverErr)
+			return nil, "", serverErr
+		}
+		logger.Warn("Erro ao conectar", "error", err)
+		return nil, "", classifyTransportError(err)
+	}
+	logger.Info("Conectado ao servidor", "url", s.ServerURL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conn = conn
+	if len(s.pairs) == 0 {
+		return conn, "", nil
+	}
+	id, err := s.send(MessageSubscribe, s.pairs)
+	if err != nil {
+		s.conn = nil
+		conn.Close()
+		return nil, "", classifyTransportError(err)
+	}
+	return conn, id, nil
+}
+
+func (s *SubscribeQuotationsUseCase) disconnect(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == conn {
+		s.conn = nil
+	}
+	conn.Close()
+}
+
+// send escreve uma mensagem na conexão atual; chamado com s.mu travado, que
+// garante um único escritor por vez
+func (s *SubscribeQuotationsUseCase) send(messageType string, pairs []string) (string, error) {
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+
+	if err := s.conn.SetWriteDeadline(deadline(s.WriteTimeout)); err != nil {
+		return "", err
+	}
+	return id, s.conn.WriteJSON(clientMessage{Type: messageType, ID: id, Pairs: pairs})
+}
+
+// logger devolve o Logger configurado, ou o padrão, com o request_id da assinatura
+func (s *SubscribeQuotationsUseCase) logger() *slog.Logger {
+	logger := s.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if s.RequestID != "" {
+		logger = logger.With(logging.RequestIDKey, s.RequestID)
+	}
+	return logger
+}
+
+// deadline devolve o prazo para uma operação de duração timeout; zero não
+// tem prazo
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+// normalizePairs deixa os pares em maiúsculas, sem espaços, vazios ou repetições
+func normalizePairs(pairs []string) []string {
+	return mergePairs(nil, pairs)
+}
+
+func mergePairs(current []string, pairs []string) []string {
+	merged := append([]string(nil), current...)
+	for _, pair := range pairs {
+		pair = strings.ToUpper(strings.TrimSpace(pair))
+		if pair != "" && !slices.Contains(merged, pair) {
+			merged = append(merged, pair)
+		}
+	}
+	return merged
+}
+
+func removePairs(current []string, pairs []string) []string {
+	var remaining []string
+	for _, pair := range current {
+		if !slices.Contains(pairs, pair) {
+			remaining = append(remaining, pair)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,263 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/client/src/logging"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wsQuotationJSON is a quotation as sent inside a WebSocket message
+func wsQuotationJSON(pair, bid string) string {
+	return `{"version": 1, "pair": "` + pair + `", "bid": "` + bid + `", "ask": "` + bid + `", "high": "` + bid + `", "low": "` + bid + `",
+		"variation": {"absolute": "0", "percent": "0"}, "provider_timestamp": "2023-11-29T17:55:42Z", "fetched_at": "2023-11-29T17:55:43Z", "source": "live"}`
+}
+
+// newWebSocketServer serves each connection with session and returns its ws:// URL
+func newWebSocketServer(t *testing.T, session func(conn *websocket.Conn, r *http.Request)) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		session(conn, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func readClientMessage(t *testing.T, conn *websocket.Conn) clientMessage {
+	t.Helper()
+
+	var message clientMessage
+	require.NoError(t, conn.ReadJSON(&message))
+	return message
+}
+
+func writeServerMessage(t *testing.T, conn *websocket.Conn, message string) {
+	t.Helper()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(message)))
+}
+
+func TestSubscribeQuotationsUseCase_Execute(t *testing.T) {
+	received := make(chan clientMessage, 10)
+	var requestID atomic.Value
+
+	url := newWebSocketServer(t, func(conn *websocket.Conn, r *http.Request) {
+		requestID.Store(r.Header.Get(logging.RequestIDHeader))
+
+		subscribe := readClientMessage(t, conn)
+		received <- subscribe
+		writeServerMessage(t, conn, `{"version": 1, "type": "subscriptions", "id": "`+subscribe.ID+`", "pairs": ["USD-BRL"]}`)
+		writeServerMessage(t, conn, `{"version": 1, "type": "snapshot", "id": "`+subscribe.ID+`", "quotation": `+wsQuotationJSON("USD-BRL", "5.8576")+`}`)
+		writeServerMessage(t, conn, `{"version": 1, "type": "delta", "quotation": `+wsQuotationJSON("USD-BRL", "5.8600")+`}`)
+
+		for {
+			var message clientMessage
+			if err := conn.ReadJSON(&message); err != nil {
+				return
+			}
+			received <- message
+			if message.Type == MessageSubscribe {
+				writeServerMessage(t, conn, `{"version": 1, "type": "error", "id": "`+message.ID+`", "pair": "EUR-BRL", "error": {"code": "upstream_unavailable", "message": "timeout", "retryable": true}}`)
+			}
+		}
+	})
+
+	useCase := NewSubscribeQuotationsUseCase("usd-brl", "USD-BRL")
+	useCase.ServerURL = url
+	useCase.RequestID = "client-abc-123"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var events []QuotationEvent
+	err := useCase.Execute(ctx, func(event QuotationEvent) {
+		events = append(events, event)
+		switch len(events) {
+		case 2:
+			// Pairs can be changed from the handler
+			assert.NoError(t, useCase.Subscribe("eur-brl"))
+		case 3:
+			assert.NoError(t, useCase.Unsubscribe("USD-BRL"))
+			assert.NoError(t, useCase.Snapshot())
+			cancel()
+		}
+	})
+
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, MessageSnapshot, events[0].Type)
+	assert.Equal(t, "5.8576", events[0].Quotation.Bid.String())
+	assert.Equal(t, MessageDelta, events[1].Type)
+	assert.Equal(t, "5.8600", events[1].Quotation.Bid.String())
+	assert.Equal(t, MessageError, events[2].Type)
+	assert.Equal(t, &SubscriptionError{Pair: "EUR-BRL", Code: "upstream_unavailable", Message: "timeout", Retryable: true}, events[2].Err)
+
+	assert.Equal(t, "client-abc-123", requestID.Load())
+	assert.Equal(t, clientMessage{Type: MessageSubscribe, ID: "1", Pairs: []string{"USD-BRL"}}, <-received)
+	assert.Equal(t, clientMessage{Type: MessageSubscribe, ID: "2", Pairs: []string{"EUR-BRL"}}, <-received)
+	assert.Equal(t, clientMessage{Type: MessageUnsubscribe, ID: "3", Pairs: []string{"USD-BRL"}}, <-received)
+	assert.Equal(t, clientMessage{Type: MessageSnapshot, ID: "4"}, <-received)
+
+	assert.ErrorIs(t, useCase.Snapshot(), ErrNotConnected)
+}
+
+func TestSubscribeQuotationsUseCase_ExecuteReconnects(t *testing.T) {
+	var mu sync.Mutex
+	var subscriptions [][]string
+
+	url := newWebSocketServer(t, func(conn *websocket.Conn, r *http.Request) {
+		subscribe := readClientMessage(t, conn)
+		mu.Lock()
+		subscriptions = append(subscriptions, subscribe.Pairs)
+		attempt := len(subscriptions)
+		mu.Unlock()
+
+		writeServerMessage(t, conn, `{"version": 1, "type": "subscriptions", "id": "`+subscribe.ID+`", "pairs": ["USD-BRL", "EUR-BRL"]}`)
+		writeServerMessage(t, conn, `{"version": 1, "type": "delta", "quotation": `+wsQuotationJSON("USD-BRL", "5.8576")+`}`)
+		if attempt == 1 {
+			// Server shutting down: the client reconnects to another instance
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+			return
+		}
+		conn.ReadMessage()
+	})
+
+	useCase := NewSubscribeQuotationsUseCase("USD-BRL", "EUR-BRL")
+	useCase.ServerURL = url
+	useCase.Reconnect = RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	deltas := 0
+	err := useCase.Execute(ctx, func(event QuotationEvent) {
+		deltas++
+		if deltas == 2 {
+			cancel()
+		}
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, deltas)
+	mu.Lock()
+	defer mu.Unlock()
+	// The same pairs are subscribed again on the new connection
+	assert.Equal(t, [][]string{{"USD-BRL", "EUR-BRL"}, {"USD-BRL", "EUR-BRL"}}, subscriptions)
+}
+
+func TestSubscribeQuotationsUseCase_ExecuteErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		session     func(t *testing.T, conn *websocket.Conn)
+		expectedErr error
+	}{
+		{
+			name: "subscription rejected",
+			session: func(t *testing.T, conn *websocket.Conn) {
+				subscribe := readClientMessage(t, conn)
+				writeServerMessage(t, conn, `{"version": 1, "type": "error", "id": "`+subscribe.ID+`", "error": {"code": "unsupported_pair", "message": "unsupported currency pair: \"XYZ-BRL\"", "retryable": false}}`)
+				conn.ReadMessage()
+			},
+			expectedErr: &SubscriptionError{Code: "unsupported_pair", Message: `unsupported currency pair: "XYZ-BRL"`},
+		},
+		{
+			name: "unsupported version",
+			session: func(t *testing.T, conn *websocket.Conn) {
+				readClientMessage(t, conn)
+				writeServerMessage(t, conn, `{"version": 2, "type": "delta"}`)
+				conn.ReadMessage()
+			},
+			expectedErr: ErrUnsupportedVersion,
+		},
+		{
+			name: "invalid JSON",
+			session: func(t *testing.T, conn *websocket.Conn) {
+				readClientMessage(t, conn)
+				writeServerMessage(t, conn, `{"version": 1, "type":`)
+				conn.ReadMessage()
+			},
+			expectedErr: ErrInvalidPayload,
+		},
+		{
+			name: "quotation missing",
+			session: func(t *testing.T, conn *websocket.Conn) {
+				readClientMessage(t, conn)
+				writeServerMessage(t, conn, `{"version": 1, "type": "delta"}`)
+				conn.ReadMessage()
+			},
+			expectedErr: ErrInvalidPayload,
+		},
+		{
+			name: "connection lost on every attempt",
+			session: func(t *testing.T, conn *websocket.Conn) {
+				readClientMessage(t, conn)
+			},
+			expectedErr: ErrUnreachable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var connections atomic.Int32
+			url := newWebSocketServer(t, func(conn *websocket.Conn, r *http.Request) {
+				connections.Add(1)
+				tt.session(t, conn)
+			})
+
+			useCase := NewSubscribeQuotationsUseCase("USD-BRL")
+			useCase.ServerURL = url
+			useCase.Reconnect = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err := useCase.Execute(ctx, func(QuotationEvent) {})
+
+			var expected *SubscriptionError
+			if errors.As(tt.expectedErr, &expected) {
+				var rejected *SubscriptionError
+				require.ErrorAs(t, err, &rejected)
+				assert.Equal(t, expected, rejected)
+				assert.Equal(t, int32(1), connections.Load())
+				return
+			}
+			assert.ErrorIs(t, err, tt.expectedErr)
+			if errors.Is(tt.expectedErr, ErrUnreachable) {
+				assert.Equal(t, int32(3), connections.Load())
+			} else {
+				// Messages that break the contract are not retried
+				assert.Equal(t, int32(1), connections.Load())
+			}
+		})
+	}
+}
+
+func TestSubscribeQuotationsUseCase_ExecuteHandshakeRejected(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	useCase := NewSubscribeQuotationsUseCase("USD-BRL")
+	useCase.ServerURL = "ws" + strings.TrimPrefix(server.URL, "http") + "/cotacao/ws"
+
+	err := useCase.Execute(context.Background(), func(QuotationEvent) {})
+
+	var serverErr *ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, http.StatusNotFound, serverErr.StatusCode)
+}
@@ -0,0 +1,147 @@
+package aggregations
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+)
+
+// MaxCandles limita a quantidade de intervalos de uma única consulta
+const MaxCandles = 1000
+
+var (
+	ErrInvalidInterval = errors.New("invalid candle interval")
+	ErrTooManyCandles  = fmt.Errorf("range spans more than %d candles", MaxCandles)
+)
+
+type Interval string
+
+const (
+	Minute Interval = "1m"
+	Hour   Interval = "1h"
+	Day    Interval = "1d"
+)
+
+func ParseInterval(value string) (Interval, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1m", "minute":
+		return Minute, nil
+	case "1h", "hour":
+		return Hour, nil
+	case "1d", "day":
+		return Day, nil
+	}
+
+	return "", fmt.Errorf("%w: %q (expected 1m, 1h or 1d)", ErrInvalidInterval, value)
+}
+
+func (i Interval) Duration() time.Duration {
+	switch i {
+	case Minute:
+		return time.Minute
+	case Hour:
+		return time.Hour
+	case Day:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// Candle resume os bids de um intervalo. Intervalos sem amostras têm Samples
+// igual a zero e não informam preços.
+type Candle struct {
+	Start   time.Time        `json:"start"`
+	End     time.Time        `json:"end"`
+	Open    *decimal.Decimal `json:"open,omitempty"`
+	High    *decimal.Decimal `json:"high,omitempty"`
+	Low     *decimal.Decimal `json:"low,omitempty"`
+	Close   *decimal.Decimal `json:"close,omitempty"`
+	Samples int              `json:"samples"`
+}
+
+// BuildCandles agrupa as cotações por create_date em intervalos de [from, to),
+// alinhados em UTC. As cotações devem estar em ordem cronológica.
+func BuildCandles(quotations []gateways.Quotation, interval Interval, from, to time.Time) ([]Candle, error) {
+	builder, err := NewCandleBuilder(interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+	builder.Add(quotations...)
+	return builder.Candles(), nil
+}
+
+// CandleBuilder monta os candles de [from, to) à medida que as cotações
+// chegam, sem guardá-las, para que o histórico possa ser lido página a página
+type CandleBuilder struct {
+	from    time.Time
+	to      time.Time
+	start   time.Time
+	step    time.Duration
+	candles []Candle
+}
+
+// NewCandleBuilder valida o intervalo e prepara os candles vazios de [from, to)
+func NewCandleBuilder(interval Interval, from, to time.Time) (*CandleBuilder, error) {
+	step := interval.Duration()
+	if step == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidInterval, interval)
+	}
+
+	builder := &CandleBuilder{
+		from:    from.UTC(),
+		to:      to.UTC(),
+		start:   from.UTC().Truncate(step),
+		step:    step,
+		candles: []Candle{},
+	}
+	if !builder.start.Before(builder.to) {
+		return builder, nil
+	}
+
+	count := int((builder.to.Sub(builder.start) + step - 1) / step)
+	if count > MaxCandles {
+		return nil, ErrTooManyCandles
+	}
+
+	builder.candles = make([]Candle, count)
+	for i := range builder.candles {
+		builder.candles[i].Start = builder.start.Add(time.Duration(i) * step)
+		builder.candles[i].End = builder.candles[i].Start.Add(step)
+	}
+	return builder, nil
+}
+
+// Add agrega as cotações, que devem chegar em ordem cronológica. Cotações
+// fora de [from, to) são ignoradas.
+func (b *CandleBuilder) Add(quotations ...gateways.Quotation) {
+	for _, quotation := range quotations {
+		createDate := quotation.CreateDate.UTC()
+		if createDate.Before(b.from) || !createDate.Before(b.to) {
+			continue
+		}
+
+		price := quotation.Bid
+		candle := &b.candles[int(createDate.Sub(b.start)/b.step)]
+
+		if candle.Samples == 0 {
+			candle.Open, candle.High, candle.Low = &price, &price, &price
+		}
+		if price.Cmp(*candle.High) > 0 {
+			candle.High = &price
+		}
+		if price.Cmp(*candle.Low) < 0 {
+			candle.Low = &price
+		}
+		candle.Close = &price
+		candle.Samples++
+	}
+}
+
+// Candles devolve os candles agregados até aqui
+func (b *CandleBuilder) Candles() []Candle {
+	return b.candles
+}
@@ -0,0 +1,156 @@
+package aggregations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func quotationAt(createDate time.Time, bid string) gateways.Quotation {
+	return gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse(bid), CreateDate: createDate}
+}
+
+func price(value string) *decimal.Decimal {
+	d := decimal.MustParse(value)
+	return &d
+}
+
+func TestBuildCandles(t *testing.T) {
+	start := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+
+	quotations := []gateways.Quotation{
+		quotationAt(start.Add(5*time.Second), "5.8500"),
+		quotationAt(start.Add(20*time.Second), "5.8620"),
+		quotationAt(start.Add(40*time.Second), "5.8410"),
+		quotationAt(start.Add(55*time.Second), "5.8550"),
+		// 17:01 has no samples
+		quotationAt(start.Add(2*time.Minute+10*time.Second), "5.8700"),
+	}
+
+	candles, err := BuildCandles(quotations, Minute, start, start.Add(3*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, candles, 3)
+
+	assert.Equal(t, Candle{
+		Start:   start,
+		End:     start.Add(time.Minute),
+		Open:    price("5.8500"),
+		High:    price("5.8620"),
+		Low:     price("5.8410"),
+		Close:   price("5.8550"),
+		Samples: 4,
+	}, candles[0])
+
+	assert.Equal(t, Candle{
+		Start:   start.Add(time.Minute),
+		End:     start.Add(2 * time.Minute),
+		Samples: 0,
+	}, candles[1])
+
+	assert.Equal(t, Candle{
+		Start:   start.Add(2 * time.Minute),
+		End:     start.Add(3 * time.Minute),
+		Open:    price("5.8700"),
+		High:    price("5.8700"),
+		Low:     price("5.8700"),
+		Close:   price("5.8700"),
+		Samples: 1,
+	}, candles[2])
+}
+
+func TestBuildCandlesAlignsBuckets(t *testing.T) {
+	from := time.Date(2023, 11, 29, 17, 30, 0, 0, time.UTC)
+	to := time.Date(2023, 11, 29, 19, 15, 0, 0, time.UTC)
+
+	quotations := []gateways.Quotation{
+		// Before from, inside the first aligned bucket: ignored
+		quotationAt(from.Add(-10*time.Minute), "5.8000"),
+		quotationAt(from.Add(10*time.Minute), "5.8500"),
+		quotationAt(to.Add(-time.Minute), "5.8600"),
+		// At to: ignored
+		quotationAt(to, "5.9000"),
+	}
+
+	candles, err := BuildCandles(quotations, Hour, from, to)
+	require.NoError(t, err)
+	require.Len(t, candles, 3)
+
+	assert.Equal(t, time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC), candles[0].Start)
+	assert.Equal(t, 1, candles[0].Samples)
+	assert.Equal(t, price("5.8500"), candles[0].Open)
+	assert.Equal(t, 0, candles[1].Samples)
+	assert.Equal(t, 1, candles[2].Samples)
+	assert.Equal(t, price("5.8600"), candles[2].Close)
+}
+
+func TestCandleBuilderAddsPageByPage(t *testing.T) {
+	from := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+
+	builder, err := NewCandleBuilder(Hour, from, from.Add(2*time.Hour))
+	require.NoError(t, err)
+
+	// Quotations of the same candle split across pages
+	builder.Add(quotationAt(from.Add(time.Minute), "5.8500"), quotationAt(from.Add(2*time.Minute), "5.8700"))
+	builder.Add(quotationAt(from.Add(3*time.Minute), "5.8400"))
+	builder.Add()
+	builder.Add(quotationAt(from.Add(time.Hour), "5.8600"))
+
+	candles := builder.Candles()
+	require.Len(t, candles, 2)
+	assert.Equal(t, Candle{
+		Start:   from,
+		End:     from.Add(time.Hour),
+		Open:    price("5.8500"),
+		High:    price("5.8700"),
+		Low:     price("5.8400"),
+		Close:   price("5.8400"),
+		Samples: 3,
+	}, candles[0])
+	assert.Equal(t, 1, candles[1].Samples)
+}
+
+func TestBuildCandlesLimits(t *testing.T) {
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := BuildCandles(nil, Minute, from, from.Add(MaxCandles*time.Minute+time.Minute))
+	assert.ErrorIs(t, err, ErrTooManyCandles)
+
+	candles, err := BuildCandles(nil, Day, from, from)
+	require.NoError(t, err)
+	assert.Empty(t, candles)
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Interval
+		wantErr  bool
+	}{
+		{value: "1m", expected: Minute},
+		{value: "minute", expected: Minute},
+		{value: "1h", expected: Hour},
+		{value: "HOUR", expected: Hour},
+		{value: "1d", expected: Day},
+		{value: "day", expected: Day},
+		{value: "1w", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			interval, err := ParseInterval(tt.value)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidInterval)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, interval)
+		})
+	}
+}
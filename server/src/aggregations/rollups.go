@@ -0,0 +1,204 @@
+package aggregations
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+)
+
+// AverageScale é a quantidade de casas decimais da média de um resumo. Médias
+// de preços muito grandes perdem casas para caber em decimal.MaxDigits.
+const AverageScale = 8
+
+// Rollup resume as cotações de um par em um intervalo de uma hora ou de um dia:
+// OHLC e média do bid, o último ask e a quantidade de amostras. Os resumos
+// substituem as cotações brutas mais antigas que o período de retenção.
+type Rollup struct {
+	Pair       gateways.Pair   `json:"pair"`
+	Resolution Interval        `json:"resolution"`
+	Start      time.Time       `json:"start"`
+	Open       decimal.Decimal `json:"open"`
+	High       decimal.Decimal `json:"high"`
+	Low        decimal.Decimal `json:"low"`
+	Close      decimal.Decimal `json:"close"`
+	Average    decimal.Decimal `json:"avg"`
+	CloseAsk   decimal.Decimal `json:"close_ask"`
+	Samples    int             `json:"samples"`
+}
+
+// BuildRollups agrupa as cotações por par e por intervalo da resolução,
+// alinhado em UTC. As cotações devem estar em ordem cronológica; o resultado
+// vem ordenado por par e início do intervalo.
+func BuildRollups(quotations []gateways.Quotation, resolution Interval) []Rollup {
+	step := resolution.Duration()
+	if step == 0 {
+		return nil
+	}
+
+	type key struct {
+		pair  gateways.Pair
+		start time.Time
+	}
+
+	sums := make(map[key]decimal.Decimal)
+	rollups := make(map[key]*Rollup)
+
+	for _, quotation := range quotations {
+		k := key{pair: quotation.Pair(), start: quotation.CreateDate.UTC().Truncate(step)}
+		price := quotation.Bid
+
+		rollup, ok := rollups[k]
+		if !ok {
+			rollup = &Rollup{Pair: k.pair, Resolution: resolution, Start: k.start, Open: price, High: price, Low: price}
+			rollups[k] = rollup
+		}
+
+		if price.Cmp(rollup.High) > 0 {
+			rollup.High = price
+		}
+		if price.Cmp(rollup.Low) < 0 {
+			rollup.Low = price
+		}
+		rollup.Close = price
+		rollup.CloseAsk = quotation.Ask
+		rollup.Samples++
+		sums[k] = sums[k].Add(price)
+	}
+
+	result := make([]Rollup, 0, len(rollups))
+	for k, rollup := range rollups {
+		rollup.Average = average(sums[k], rollup.Samples)
+		result = append(result, *rollup)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Pair != result[j].Pair {
+			return result[i].Pair < result[j].Pair
+		}
+		return result[i].Start.Before(result[j].Start)
+	})
+
+	return result
+}
+
+// Merge combina um resumo com outro do mesmo intervalo formado por cotações
+// mais recentes, como acontece quando parte do intervalo já havia sido resumida
+func (r Rollup) Merge(newer Rollup) Rollup {
+	if r.Samples == 0 {
+		return newer
+	}
+	if newer.Samples == 0 {
+		return r
+	}
+
+	merged := r
+	if newer.High.Cmp(merged.High) > 0 {
+		merged.High = newer.High
+	}
+	if newer.Low.Cmp(merged.Low) < 0 {
+		merged.Low = newer.Low
+	}
+	merged.Close = newer.Close
+	merged.CloseAsk = newer.CloseAsk
+	merged.Samples = r.Samples + newer.Samples
+
+	sum := r.Average.Mul(decimal.New(int64(r.Samples), 0)).Add(newer.Average.Mul(decimal.New(int64(newer.Samples), 0)))
+	merged.Average = average(sum, merged.Samples)
+
+	return merged
+}
+
+// Candle converte o resumo no candle do mesmo intervalo
+func (r Rollup) Candle() Candle {
+	open, high, low, close := r.Open, r.High, r.Low, r.Close
+	return Candle{
+		Start:   r.Start,
+		End:     r.Start.Add(r.Resolution.Duration()),
+		Open:    &open,
+		High:    &high,
+		Low:     &low,
+		Close:   &close,
+		Samples: r.Samples,
+	}
+}
+
+// RollupProvider identifica, no histórico, as entradas que vêm de resumos
+func RollupProvider(resolution Interval) string {
+	return "rollup-" + string(resolution)
+}
+
+// Quotation representa o resumo como uma entrada do histórico: bid e ask de
+// fechamento, máxima e mínima do intervalo e variação desde a abertura
+func (r Rollup) Quotation() gateways.Quotation {
+	variation := r.Close.Sub(r.Open)
+
+	var pctChange decimal.Decimal
+	if !r.Open.IsZero() {
+		pctChange, _ = variation.Mul(decimal.New(100, 0)).Div(r.Open, 2)
+	}
+
+	return gateways.Quotation{
+		Code:       r.Pair.Code(),
+		Codein:     r.Pair.Codein(),
+		High:       r.High,
+		Low:        r.Low,
+		VarBid:     variation,
+		PctChange:  pctChange,
+		Bid:        r.Close,
+		Ask:        r.CloseAsk,
+		CreateDate: r.Start,
+		Provider:   RollupProvider(r.Resolution),
+	}
+}
+
+// MergeRollupCandles completa os candles com os resumos da mesma resolução. Os
+// resumos cobrem o período anterior às cotações brutas, então fornecem a
+// abertura quando um candle tem as duas origens.
+func MergeRollupCandles(candles []Candle, rollups []Rollup) []Candle {
+	byStart := make(map[time.Time]Rollup, len(rollups))
+	for _, rollup := range rollups {
+		byStart[rollup.Start.UTC()] = rollup
+	}
+
+	for i, candle := range candles {
+		rollup, ok := byStart[candle.Start]
+		if !ok || rollup.Samples == 0 {
+			continue
+		}
+
+		fromRollup := rollup.Candle()
+		if candle.Samples == 0 {
+			candles[i] = fromRollup
+			continue
+		}
+
+		if candle.High.Cmp(*fromRollup.High) < 0 {
+			candles[i].High = fromRollup.High
+		}
+		if candle.Low.Cmp(*fromRollup.Low) > 0 {
+			candles[i].Low = fromRollup.Low
+		}
+		candles[i].Open = fromRollup.Open
+		candles[i].Samples += fromRollup.Samples
+	}
+
+	return candles
+}
+
+func average(sum decimal.Decimal, samples int) decimal.Decimal {
+	if samples == 0 {
+		return decimal.Decimal{}
+	}
+	avg, _ := sum.Div(decimal.New(int64(samples), 0), AverageScale)
+
+	// A média fica entre o menor e o maior preço, então a parte inteira sempre
+	// cabe; só as casas decimais podem passar do limite que o banco relê
+	digits := len(strings.Replace(strings.TrimPrefix(avg.String(), "-"), ".", "", 1))
+	if excess := digits - decimal.MaxDigits; excess > 0 {
+		avg = avg.Round(max(avg.Scale()-excess, 0))
+	}
+	return avg
+}
@@ -0,0 +1,156 @@
+package aggregations
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildRollups(t *testing.T) {
+	start := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+	quotations := []gateways.Quotation{
+		quotationAt(start.Add(5*time.Minute), "5.8500"),
+		quotationAt(start.Add(20*time.Minute), "5.8620"),
+		quotationAt(start.Add(40*time.Minute), "5.8410"),
+		{Code: "EUR", Codein: "BRL", Bid: decimal.MustParse("6.3894"), Ask: decimal.MustParse("6.3922"), CreateDate: start.Add(10 * time.Minute)},
+		quotationAt(start.Add(70*time.Minute), "5.8700"),
+	}
+	quotations[2].Ask = decimal.MustParse("5.8416")
+
+	rollups := BuildRollups(quotations, Hour)
+	require.Len(t, rollups, 3)
+
+	assert.Equal(t, Rollup{
+		Pair:       "EUR-BRL",
+		Resolution: Hour,
+		Start:      start,
+		Open:       decimal.MustParse("6.3894"),
+		High:       decimal.MustParse("6.3894"),
+		Low:        decimal.MustParse("6.3894"),
+		Close:      decimal.MustParse("6.3894"),
+		Average:    decimal.MustParse("6.38940000"),
+		CloseAsk:   decimal.MustParse("6.3922"),
+		Samples:    1,
+	}, rollups[0])
+
+	usd := rollups[1]
+	assert.Equal(t, gateways.Pair("USD-BRL"), usd.Pair)
+	assert.Equal(t, start, usd.Start)
+	assert.Equal(t, "5.8500", usd.Open.String())
+	assert.Equal(t, "5.8620", usd.High.String())
+	assert.Equal(t, "5.8410", usd.Low.String())
+	assert.Equal(t, "5.8410", usd.Close.String())
+	assert.Equal(t, "5.8416", usd.CloseAsk.String())
+	assert.Equal(t, "5.85100000", usd.Average.String())
+	assert.Equal(t, 3, usd.Samples)
+
+	assert.Equal(t, start.Add(time.Hour), rollups[2].Start)
+	assert.Equal(t, 1, rollups[2].Samples)
+
+	// A daily rollup covers both hours
+	daily := BuildRollups(quotations, Day)
+	require.Len(t, daily, 2)
+	assert.Equal(t, time.Date(2023, 11, 29, 0, 0, 0, 0, time.UTC), daily[1].Start)
+	assert.Equal(t, 4, daily[1].Samples)
+	assert.Equal(t, "5.8700", daily[1].High.String())
+	assert.Equal(t, "5.8700", daily[1].Close.String())
+}
+
+func TestRollupMerge(t *testing.T) {
+	start := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+	older := BuildRollups([]gateways.Quotation{
+		quotationAt(start.Add(5*time.Minute), "5.8500"),
+		quotationAt(start.Add(10*time.Minute), "5.8600"),
+	}, Hour)[0]
+	newer := BuildRollups([]gateways.Quotation{
+		quotationAt(start.Add(50*time.Minute), "5.8300"),
+	}, Hour)[0]
+
+	merged := older.Merge(newer)
+
+	assert.Equal(t, "5.8500", merged.Open.String())
+	assert.Equal(t, "5.8600", merged.High.String())
+	assert.Equal(t, "5.8300", merged.Low.String())
+	assert.Equal(t, "5.8300", merged.Close.String())
+	assert.Equal(t, "5.84666667", merged.Average.String())
+	assert.Equal(t, 3, merged.Samples)
+
+	assert.Equal(t, newer, Rollup{}.Merge(newer))
+}
+
+func TestBuildRollupsAverageFitsMaxDigits(t *testing.T) {
+	start := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+	large := strings.Repeat("9", decimal.MaxDigits-2)
+
+	rollups := BuildRollups([]gateways.Quotation{
+		quotationAt(start, large+".10"),
+		quotationAt(start.Add(time.Minute), large+".25"),
+		quotationAt(start.Add(2*time.Minute), large+".31"),
+	}, Hour)
+	require.Len(t, rollups, 1)
+
+	// The average loses decimal places instead of exceeding MaxDigits
+	average := rollups[0].Average
+	assert.Equal(t, large+".22", average.String())
+	_, err := decimal.Parse(average.String())
+	assert.NoError(t, err)
+
+	// Small prices keep the full AverageScale
+	rollups = BuildRollups([]gateways.Quotation{quotationAt(start, "5.85"), quotationAt(start.Add(time.Minute), "5.86")}, Hour)
+	assert.Equal(t, AverageScale, rollups[0].Average.Scale())
+}
+
+func TestRollupQuotation(t *testing.T) {
+	start := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+	rollup := Rollup{
+		Pair:       "USD-BRL",
+		Resolution: Hour,
+		Start:      start,
+		Open:       decimal.MustParse("5.8000"),
+		High:       decimal.MustParse("5.9000"),
+		Low:        decimal.MustParse("5.7900"),
+		Close:      decimal.MustParse("5.8580"),
+		CloseAsk:   decimal.MustParse("5.8590"),
+		Samples:    60,
+	}
+
+	quotation := rollup.Quotation()
+
+	assert.Equal(t, "USD", quotation.Code)
+	assert.Equal(t, "BRL", quotation.Codein)
+	assert.Equal(t, "5.8580", quotation.Bid.String())
+	assert.Equal(t, "5.8590", quotation.Ask.String())
+	assert.Equal(t, "0.0580", quotation.VarBid.String())
+	assert.Equal(t, "1.00", quotation.PctChange.String())
+	assert.Equal(t, start, quotation.CreateDate)
+	assert.Equal(t, "rollup-1h", quotation.Provider)
+}
+
+func TestMergeRollupCandles(t *testing.T) {
+	start := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+
+	// 17:00 was compacted, 18:00 has both origins, 19:00 is raw only
+	candles, err := BuildCandles([]gateways.Quotation{
+		quotationAt(start.Add(90*time.Minute), "5.8800"),
+		quotationAt(start.Add(130*time.Minute), "5.9000"),
+	}, Hour, start, start.Add(3*time.Hour))
+	require.NoError(t, err)
+
+	rollups := []Rollup{
+		{Pair: "USD-BRL", Resolution: Hour, Start: start, Open: decimal.MustParse("5.8500"), High: decimal.MustParse("5.8700"), Low: decimal.MustParse("5.8400"), Close: decimal.MustParse("5.8600"), Samples: 60},
+		{Pair: "USD-BRL", Resolution: Hour, Start: start.Add(time.Hour), Open: decimal.MustParse("5.8600"), High: decimal.MustParse("5.8900"), Low: decimal.MustParse("5.8500"), Close: decimal.MustParse("5.8700"), Samples: 30},
+	}
+
+	candles = MergeRollupCandles(candles, rollups)
+	require.Len(t, candles, 3)
+
+	assert.Equal(t, Candle{Start: start, End: start.Add(time.Hour), Open: price("5.8500"), High: price("5.8700"), Low: price("5.8400"), Close: price("5.8600"), Samples: 60}, candles[0])
+	assert.Equal(t, Candle{Start: start.Add(time.Hour), End: start.Add(2 * time.Hour), Open: price("5.8600"), High: price("5.8900"), Low: price("5.8500"), Close: price("5.8800"), Samples: 31}, candles[1])
+	assert.Equal(t, 1, candles[2].Samples)
+	assert.Equal(t, price("5.9000"), candles[2].Open)
+}
@@ -0,0 +1,104 @@
+package coalescing
+
+import (
+	"context"
+	"sync"
+)
+
+// call é uma execução em andamento, compartilhada por todos que pediram a mesma chave
+type call[T any] struct {
+	done    chan struct{}
+	value   T
+	err     error
+	waiters int // Chamadas ainda esperando pelo resultado
+	dups    int // Chamadas que se juntaram a esta execução
+	cancel  context.CancelFunc
+}
+
+// Group agrupa chamadas concorrentes com a mesma chave em uma única execução,
+// no estilo single-flight: quem chega enquanto há uma execução em andamento
+// espera por ela e recebe o mesmo resultado.
+type Group[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+// Do executa fn uma única vez por chave entre as chamadas simultâneas. O
+// booleano devolvido indica que o resultado foi compartilhado com outras chamadas.
+//
+// fn recebe um contexto com os valores e o prazo do contexto de quem iniciou a
+// execução, mas que só é cancelado antes do prazo quando todas as chamadas que
+// esperam por ela desistem; assim, um cliente que desconecta não derruba a
+// requisição dos demais. Quem se junta a uma execução em andamento espera no
+// máximo até o prazo dela, mesmo que o seu seja mais longo.
+func (g *Group[T]) Do(ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (T, bool, error) {
+	if err := ctx.Err(); err != nil {
+		var zero T
+		return zero, false, err
+	}
+
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call[T])
+	}
+
+	// Uma execução abandonada por todos já foi cancelada: começa outra
+	c, ok := g.calls[key]
+	if ok && c.waiters > 0 {
+		c.waiters++
+		c.dups++
+	} else {
+		callCtx, cancel := withDeadlineOf(ctx)
+		c = &call[T]{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.calls[key] = c
+		go g.run(callCtx, key, c, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		g.mu.Lock()
+		shared := c.dups > 0
+		g.mu.Unlock()
+		return c.value, shared, c.err
+	case <-ctx.Done():
+		g.leave(c)
+		var zero T
+		return zero, false, ctx.Err()
+	}
+}
+
+// withDeadlineOf devolve um contexto com os valores e o prazo de ctx, mas que
+// não é cancelado junto com ele
+func withDeadlineOf(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached, deadline)
+	}
+	return context.WithCancel(detached)
+}
+
+func (g *Group[T]) run(ctx context.Context, key string, c *call[T], fn func(ctx context.Context) (T, error)) {
+	defer c.cancel()
+
+	c.value, c.err = fn(ctx)
+
+	g.mu.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+
+	close(c.done)
+}
+
+// leave retira uma chamada que desistiu de esperar; a última a sair cancela a execução
+func (g *Group[T]) leave(c *call[T]) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c.waiters--
+	if c.waiters == 0 {
+		c.cancel()
+	}
+}
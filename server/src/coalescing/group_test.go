@@ -0,0 +1,197 @@
+package coalescing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupDoSharesInFlightCall(t *testing.T) {
+	var group Group[string]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	fn := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "5.8576", nil
+	}
+
+	const callers = 20
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	shared := make([]bool, callers)
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value, isShared, err := group.Do(context.Background(), "USD-BRL", fn)
+			assert.NoError(t, err)
+			results[i] = value
+			shared[i] = isShared
+		}(i)
+	}
+
+	// Let every caller join before the call finishes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for i := 0; i < callers; i++ {
+		assert.Equal(t, "5.8576", results[i])
+		assert.True(t, shared[i])
+	}
+}
+
+func TestGroupDoSeparateKeys(t *testing.T) {
+	var group Group[string]
+	var calls atomic.Int32
+
+	fn := func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		return "ok", nil
+	}
+
+	for _, key := range []string{"USD-BRL", "EUR-BRL", "USD-BRL"} {
+		value, shared, err := group.Do(context.Background(), key, fn)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", value)
+		assert.False(t, shared)
+	}
+
+	// Sequential calls are not coalesced
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestGroupDoSharesErrors(t *testing.T) {
+	var group Group[string]
+	expected := errors.New("upstream unavailable")
+
+	_, _, err := group.Do(context.Background(), "USD-BRL", func(ctx context.Context) (string, error) {
+		return "", expected
+	})
+
+	assert.ErrorIs(t, err, expected)
+}
+
+func TestGroupDoCallerCancellation(t *testing.T) {
+	var group Group[string]
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var callCtx context.Context
+
+	fn := func(ctx context.Context) (string, error) {
+		callCtx = ctx
+		close(started)
+		select {
+		case <-release:
+			return "5.8576", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	// The caller that started the call gives up
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstDone := make(chan error, 1)
+	go func() {
+		_, _, err := group.Do(firstCtx, "USD-BRL", fn)
+		firstDone <- err
+	}()
+	<-started
+
+	secondDone := make(chan string, 1)
+	go func() {
+		value, _, err := group.Do(context.Background(), "USD-BRL", fn)
+		assert.NoError(t, err)
+		secondDone <- value
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancelFirst()
+	assert.ErrorIs(t, <-firstDone, context.Canceled)
+
+	// The call keeps running for the caller that is still waiting
+	assert.NoError(t, callCtx.Err())
+	close(release)
+	assert.Equal(t, "5.8576", <-secondDone)
+}
+
+func TestGroupDoAllCallersCancel(t *testing.T) {
+	var group Group[string]
+	finished := make(chan error, 1)
+
+	fn := func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		finished <- ctx.Err()
+		return "", ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, _, err := group.Do(ctx, "USD-BRL", fn)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Once nobody is waiting, the call itself is cancelled
+	select {
+	case err := <-finished:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("call was not cancelled")
+	}
+}
+
+func TestGroupDoKeepsLeaderDeadline(t *testing.T) {
+	var group Group[string]
+	started := make(chan struct{})
+	var hasDeadline atomic.Bool
+
+	fn := func(ctx context.Context) (string, error) {
+		_, ok := ctx.Deadline()
+		hasDeadline.Store(ok)
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+
+	leaderCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	go group.Do(leaderCtx, "USD-BRL", fn)
+	<-started
+
+	// A waiter with a longer deadline is released when the shared call's deadline passes
+	waiterCtx, cancelWaiter := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelWaiter()
+
+	start := time.Now()
+	_, shared, err := group.Do(waiterCtx, "USD-BRL", fn)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, shared)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.NoError(t, waiterCtx.Err())
+	// The shared call sees the deadline, so the upstream budget can be derived from it
+	assert.True(t, hasDeadline.Load())
+}
+
+func TestGroupDoKeepsContextValues(t *testing.T) {
+	type key struct{}
+	var group Group[string]
+	ctx := context.WithValue(context.Background(), key{}, "request")
+
+	value, _, err := group.Do(ctx, "USD-BRL", func(ctx context.Context) (string, error) {
+		return ctx.Value(key{}).(string), nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "request", value)
+}
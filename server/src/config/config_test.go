@@ -0,0 +1,182 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/shared/configloader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile writes a config file to a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// env builds a lookupEnv function from a fixed set of variables
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	cfg := Default()
+
+	assert.NoError(t, cfg.Validate())
+	assert.Equal(t, 200*time.Millisecond, cfg.UpstreamTimeout)
+	assert.Equal(t, 10*time.Millisecond, cfg.DBTimeout)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(cfg *Config)
+		expected []string
+	}{
+		{
+			name:     "port out of range",
+			modify:   func(cfg *Config) { cfg.Port = "70000" },
+			expected: []string{"port: must be a number between 1 and 65535"},
+		},
+		{
+			name:     "non-positive timeouts",
+			modify:   func(cfg *Config) { cfg.UpstreamTimeout = 0; cfg.DBTimeout = -time.Millisecond },
+			expected: []string{"upstream-timeout: must be positive", "db-timeout: must be positive"},
+		},
+		{
+			name:     "negative server timeout",
+			modify:   func(cfg *Config) { cfg.WriteTimeout = -time.Second },
+			expected: []string{"write-timeout: must not be negative"},
+		},
+		{
+			name:     "shutdown delay longer than grace",
+			modify:   func(cfg *Config) { cfg.ShutdownDelay = cfg.ShutdownGrace },
+			expected: []string{"shutdown-delay: must be at least 0 and shorter than shutdown-grace"},
+		},
+		{
+			name:     "retention without interval",
+			modify:   func(cfg *Config) { cfg.Retention = 24 * time.Hour; cfg.RetentionInterval = 0 },
+			expected: []string{"retention-interval: must be positive when retention is enabled"},
+		},
+		{
+			name:     "provider URL without scheme",
+			modify:   func(cfg *Config) { cfg.AwesomeAPIURL = "economia.awesomeapi.com.br" },
+			expected: []string{"awesomeapi-url: must be an http or https URL"},
+		},
+		{
+			name:     "empty database",
+			modify:   func(cfg *Config) { cfg.DB = "" },
+			expected: []string{"db: must not be empty"},
+		},
+		{
+			name:     "queue sizes",
+			modify:   func(cfg *Config) { cfg.PersistBatch = 0; cfg.PersistRetries = -1 },
+			expected: []string{"persist-batch: must be at least 1", "persist-retries: must not be negative"},
+		},
+		{
+			name:     "stream options",
+			modify:   func(cfg *Config) { cfg.StreamHeartbeat = 0; cfg.StreamMaxPairs = 0; cfg.WSPingInterval = -time.Second },
+			expected: []string{"stream-heartbeat: must be positive", "stream-max-pairs: must be at least 1", "ws-ping-interval: must be positive"},
+		},
+		{
+			name: "values parsed by other packages",
+			modify: func(cfg *Config) {
+				cfg.Poll = "USD-BRL"
+				cfg.PersistPolicy = "wait"
+				cfg.LogFormat = "xml"
+				cfg.LogLevel = "loud"
+			},
+			expected: []string{"poll:", "persist-policy:", "log-format:", "log-level:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+
+			require.ErrorIs(t, err, ErrInvalidConfig)
+			for _, message := range tt.expected {
+				assert.Contains(t, err.Error(), message)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := writeFile(t, "server.yaml", "port: 9090\nupstream-timeout: 150ms\nawesomeapi-url: http://localhost:9999/json/last\n")
+
+	cfg, err := Load(
+		[]string{"-config", path, "-log-level", "debug"},
+		env(map[string]string{"QUOTATION_SERVER_PORT": "9191", "QUOTATION_SERVER_POLL": ""}),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "9191", cfg.Port)
+	assert.Equal(t, 150*time.Millisecond, cfg.UpstreamTimeout)
+	assert.Equal(t, "http://localhost:9999/json/last", cfg.AwesomeAPIURL)
+	assert.Equal(t, "", cfg.Poll)
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, path, cfg.ConfigFile)
+
+	assert.Equal(t, configloader.SourceEnv, cfg.Source("port"))
+	assert.Equal(t, configloader.SourceFile, cfg.Source("upstream-timeout"))
+	assert.Equal(t, configloader.SourceFlag, cfg.Source("log-level"))
+	assert.Equal(t, configloader.SourceDefault, cfg.Source("db"))
+}
+
+func TestLoadValidates(t *testing.T) {
+	_, err := Load([]string{"-upstream-timeout", "0s"}, env(nil))
+
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestLoadHelp(t *testing.T) {
+	_, err := Load([]string{"-h"}, env(nil))
+
+	assert.ErrorIs(t, err, flag.ErrHelp)
+}
+
+func TestPrint(t *testing.T) {
+	cfg, err := Load([]string{"-db", "postgres://app:s3cret@db:5432/quotations"}, env(map[string]string{"QUOTATION_SERVER_PORT": "9191"}))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, cfg.Print(&out))
+
+	assert.Contains(t, out.String(), "db: postgres://app:xxxxx@db:5432/quotations # flag\n")
+	assert.Contains(t, out.String(), "port: \"9191\" # env QUOTATION_SERVER_PORT\n")
+	assert.Contains(t, out.String(), "upstream-timeout: 200ms # default\n")
+	assert.NotContains(t, out.String(), "s3cret")
+	assert.NotContains(t, out.String(), "print-config")
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn      string
+		expected string
+	}{
+		{dsn: "./quotations.db", expected: "./quotations.db"},
+		{dsn: "sqlite://./quotations.db", expected: "sqlite://./quotations.db"},
+		{dsn: "postgres://app@db/quotations", expected: "postgres://app@db/quotations"},
+		{dsn: "postgres://app:s3cret@db:5432/quotations?sslmode=disable", expected: "postgres://app:xxxxx@db:5432/quotations?sslmode=disable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			assert.Equal(t, tt.expected, RedactDSN(tt.dsn))
+		})
+	}
+}
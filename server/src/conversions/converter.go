@@ -0,0 +1,215 @@
+package conversions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+)
+
+// DivisionScale é a quantidade de casas decimais usada quando a conversão exige
+// uma divisão (pares inversos); conversões só com pares diretos são exatas
+const DivisionScale = 8
+
+var (
+	ErrInvalidCurrency  = errors.New("invalid currency")
+	ErrInvalidSide      = errors.New("invalid side")
+	ErrNoConversionPath = errors.New("no conversion path")
+)
+
+// Interfaces para dependências
+type QuotationSource interface {
+	GetLatestQuotation(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error)
+}
+
+// Side escolhe qual preço da cotação é usado em cada perna da conversão
+type Side string
+
+const (
+	Bid Side = "bid"
+	Ask Side = "ask"
+)
+
+func ParseSide(value string) (Side, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "bid":
+		return Bid, nil
+	case "ask":
+		return Ask, nil
+	}
+	return "", fmt.Errorf("%w: %q (expected bid or ask)", ErrInvalidSide, value)
+}
+
+// Leg é uma cotação usada na conversão. Inverse indica que o par foi usado no
+// sentido contrário (BRL->USD a partir de USD-BRL), isto é, dividindo pelo preço.
+type Leg struct {
+	Pair      gateways.Pair   `json:"pair"`
+	Inverse   bool            `json:"inverse"`
+	Price     decimal.Decimal `json:"price"`
+	Provider  string          `json:"provider,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+type Conversion struct {
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Side      Side            `json:"side"`
+	Amount    decimal.Decimal `json:"amount"`
+	Rate      decimal.Decimal `json:"rate"`
+	Converted decimal.Decimal `json:"converted"`
+	// Timestamp é o create_date da cotação mais antiga usada
+	Timestamp time.Time `json:"timestamp"`
+	Legs      []Leg     `json:"legs"`
+}
+
+type Converter struct {
+	source QuotationSource
+	// Bases são as moedas usadas para triangular quando não existe par direto
+	// nem inverso, em ordem de preferência
+	Bases []string
+}
+
+func NewConverter(source QuotationSource) *Converter {
+	return &Converter{
+		source: source,
+		Bases:  []string{"BRL", "USD"},
+	}
+}
+
+// ParseCurrency valida um código de moeda de três letras
+func ParseCurrency(value string) (string, error) {
+	currency := strings.ToUpper(strings.TrimSpace(value))
+	if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, value)
+	}
+	return currency, nil
+}
+
+func (c *Converter) Convert(ctx context.Context, from, to string, amount decimal.Decimal, side Side) (Conversion, error) {
+	conversion := Conversion{From: from, To: to, Side: side, Amount: amount, Legs: []Leg{}}
+
+	if from == to {
+		conversion.Rate = decimal.MustParse("1")
+		conversion.Converted = amount
+		conversion.Timestamp = time.Now().UTC()
+		return conversion, nil
+	}
+
+	route, err := c.route(from, to)
+	if err != nil {
+		return Conversion{}, err
+	}
+
+	// Multiplica pelos preços diretos e divide pelos inversos uma única vez no
+	// final, para que só haja arredondamento quando houver divisão
+	numerator := decimal.MustParse("1")
+	denominator := decimal.MustParse("1")
+
+	for _, step := range route {
+		quotation, err := c.source.GetLatestQuotation(ctx, step.pair)
+		if err != nil {
+			return Conversion{}, fmt.Errorf("failed to get %s quotation: %w", step.pair, err)
+		}
+
+		price, err := priceFor(quotation, side)
+		if err != nil {
+			return Conversion{}, err
+		}
+
+		if step.inverse {
+			denominator = denominator.Mul(price)
+		} else {
+			numerator = numerator.Mul(price)
+		}
+
+		timestamp := quotation.CreateDate.UTC()
+		if conversion.Timestamp.IsZero() || timestamp.Before(conversion.Timestamp) {
+			conversion.Timestamp = timestamp
+		}
+
+		conversion.Legs = append(conversion.Legs, Leg{
+			Pair:      step.pair,
+			Inverse:   step.inverse,
+			Price:     price,
+			Provider:  quotation.Provider,
+			Timestamp: timestamp,
+		})
+	}
+
+	if denominator.Equal(decimal.MustParse("1")) {
+		conversion.Rate = numerator
+		conversion.Converted = amount.Mul(numerator)
+		return conversion, nil
+	}
+
+	conversion.Rate, err = numerator.Div(denominator, DivisionScale)
+	if err != nil {
+		return Conversion{}, err
+	}
+
+	conversion.Converted, err = amount.Mul(numerator).Div(denominator, DivisionScale)
+	if err != nil {
+		return Conversion{}, err
+	}
+
+	return conversion, nil
+}
+
+type step struct {
+	pair    gateways.Pair
+	inverse bool
+}
+
+// route encontra o caminho de from até to: par direto, par inverso ou
+// triangulação por uma das moedas base
+func (c *Converter) route(from, to string) ([]step, error) {
+	if leg, ok := directStep(from, to); ok {
+		return []step{leg}, nil
+	}
+
+	for _, base := range c.Bases {
+		if base == from || base == to {
+			continue
+		}
+
+		first, ok := directStep(from, base)
+		if !ok {
+			continue
+		}
+		second, ok := directStep(base, to)
+		if !ok {
+			continue
+		}
+
+		return []step{first, second}, nil
+	}
+
+	return nil, fmt.Errorf("%w from %s to %s", ErrNoConversionPath, from, to)
+}
+
+func directStep(from, to string) (step, bool) {
+	if pair, err := gateways.ParsePair(from + "-" + to); err == nil {
+		return step{pair: pair}, true
+	}
+	if pair, err := gateways.ParsePair(to + "-" + from); err == nil {
+		return step{pair: pair, inverse: true}, true
+	}
+	return step{}, false
+}
+
+func priceFor(quotation gateways.Quotation, side Side) (decimal.Decimal, error) {
+	price := quotation.Bid
+	if side == Ask {
+		price = quotation.Ask
+	}
+
+	if price.Sign() <= 0 {
+		return decimal.Decimal{}, fmt.Errorf("invalid %s for %s: must be positive", side, quotation.Pair())
+	}
+
+	return price, nil
+}
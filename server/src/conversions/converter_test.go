@@ -0,0 +1,200 @@
+package conversions
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Fake quotation source
+type fakeSource struct {
+	quotations map[gateways.Pair]gateways.Quotation
+	requested  []gateways.Pair
+	contexts   []context.Context
+}
+
+func (s *fakeSource) GetLatestQuotation(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error) {
+	s.requested = append(s.requested, pair)
+	s.contexts = append(s.contexts, ctx)
+
+	quotation, ok := s.quotations[pair]
+	if !ok {
+		return gateways.Quotation{}, errors.New("quotation not found")
+	}
+	return quotation, nil
+}
+
+var (
+	usdDate = time.Date(2023, 11, 29, 17, 55, 42, 0, time.UTC)
+	eurDate = time.Date(2023, 11, 29, 17, 50, 0, 0, time.UTC)
+)
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{quotations: map[gateways.Pair]gateways.Quotation{
+		"USD-BRL": {Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("5.8582"), CreateDate: usdDate, Provider: "awesomeapi"},
+		"EUR-BRL": {Code: "EUR", Codein: "BRL", Bid: decimal.MustParse("6.3894"), Ask: decimal.MustParse("6.3922"), CreateDate: eurDate, Provider: "awesomeapi"},
+		"GBP-BRL": {Code: "GBP", Codein: "BRL", Bid: decimal.MustParse("7.4010"), Ask: decimal.MustParse("7.4105"), CreateDate: usdDate, Provider: "awesomeapi"},
+	}}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name              string
+		from              string
+		to                string
+		amount            string
+		side              Side
+		expectedRate      string
+		expectedConverted string
+		expectedLegs      []gateways.Pair
+		expectedTimestamp time.Time
+	}{
+		{
+			name:              "direct pair",
+			from:              "USD",
+			to:                "BRL",
+			amount:            "123.45",
+			side:              Bid,
+			expectedRate:      "5.8576",
+			expectedConverted: "723.120720",
+			expectedLegs:      []gateways.Pair{"USD-BRL"},
+			expectedTimestamp: usdDate,
+		},
+		{
+			name:              "direct pair ask side",
+			from:              "USD",
+			to:                "BRL",
+			amount:            "100",
+			side:              Ask,
+			expectedRate:      "5.8582",
+			expectedConverted: "585.8200",
+			expectedLegs:      []gateways.Pair{"USD-BRL"},
+			expectedTimestamp: usdDate,
+		},
+		{
+			name:              "inverse pair",
+			from:              "BRL",
+			to:                "USD",
+			amount:            "585.76",
+			side:              Bid,
+			expectedRate:      "0.17071838",
+			expectedConverted: "100.00000000",
+			expectedLegs:      []gateways.Pair{"USD-BRL"},
+			expectedTimestamp: usdDate,
+		},
+		{
+			name:              "triangulation through BRL",
+			from:              "EUR",
+			to:                "GBP",
+			amount:            "100",
+			side:              Bid,
+			expectedRate:      "0.86331577",
+			expectedConverted: "86.33157681",
+			expectedLegs:      []gateways.Pair{"EUR-BRL", "GBP-BRL"},
+			expectedTimestamp: eurDate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newFakeSource()
+			converter := NewConverter(source)
+
+			conversion, err := converter.Convert(context.Background(), tt.from, tt.to, decimal.MustParse(tt.amount), tt.side)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedRate, conversion.Rate.String())
+			assert.Equal(t, tt.expectedConverted, conversion.Converted.String())
+			assert.Equal(t, tt.expectedTimestamp, conversion.Timestamp)
+			assert.Equal(t, tt.expectedLegs, source.requested)
+			require.Len(t, conversion.Legs, len(tt.expectedLegs))
+			assert.Equal(t, "awesomeapi", conversion.Legs[0].Provider)
+		})
+	}
+}
+
+func TestConvertUsesDirectPairOverTriangulation(t *testing.T) {
+	source := newFakeSource()
+	source.quotations["GBP-USD"] = gateways.Quotation{Code: "GBP", Codein: "USD", Bid: decimal.MustParse("1.2634"), Ask: decimal.MustParse("1.2636"), CreateDate: usdDate}
+	converter := NewConverter(source)
+
+	conversion, err := converter.Convert(context.Background(), "USD", "GBP", decimal.MustParse("10"), Bid)
+	require.NoError(t, err)
+
+	assert.Equal(t, []gateways.Pair{"GBP-USD"}, source.requested)
+	assert.True(t, conversion.Legs[0].Inverse)
+	assert.Equal(t, "7.91514960", conversion.Converted.String())
+}
+
+func TestConvertSameCurrency(t *testing.T) {
+	source := newFakeSource()
+	converter := NewConverter(source)
+
+	conversion, err := converter.Convert(context.Background(), "BRL", "BRL", decimal.MustParse("42.10"), Bid)
+	require.NoError(t, err)
+
+	assert.Equal(t, "1", conversion.Rate.String())
+	assert.Equal(t, "42.10", conversion.Converted.String())
+	assert.Empty(t, source.requested)
+}
+
+func TestConvertErrors(t *testing.T) {
+	source := newFakeSource()
+	converter := NewConverter(source)
+
+	_, err := converter.Convert(context.Background(), "JPY", "BRL", decimal.MustParse("1"), Bid)
+	assert.ErrorIs(t, err, ErrNoConversionPath)
+
+	// ARS-BRL is supported but missing from the source
+	_, err = converter.Convert(context.Background(), "ARS", "BRL", decimal.MustParse("1"), Bid)
+	assert.Error(t, err)
+
+	source.quotations["USD-BRL"] = gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("0")}
+	_, err = converter.Convert(context.Background(), "USD", "BRL", decimal.MustParse("1"), Bid)
+	assert.Error(t, err)
+}
+
+func TestParseCurrencyAndSide(t *testing.T) {
+	currency, err := ParseCurrency(" usd ")
+	require.NoError(t, err)
+	assert.Equal(t, "USD", currency)
+
+	for _, invalid := range []string{"", "US", "USDT", "U5D"} {
+		_, err := ParseCurrency(invalid)
+		assert.ErrorIs(t, err, ErrInvalidCurrency, invalid)
+	}
+
+	side, err := ParseSide("")
+	require.NoError(t, err)
+	assert.Equal(t, Bid, side)
+
+	side, err = ParseSide("ASK")
+	require.NoError(t, err)
+	assert.Equal(t, Ask, side)
+
+	_, err = ParseSide("mid")
+	assert.ErrorIs(t, err, ErrInvalidSide)
+}
+
+func TestConvertPassesContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "request")
+
+	source := newFakeSource()
+	converter := NewConverter(source)
+
+	_, err := converter.Convert(ctx, "EUR", "GBP", decimal.MustParse("1"), Bid)
+	require.NoError(t, err)
+
+	// Every leg is fetched with the caller's context
+	require.Len(t, source.contexts, 2)
+	for _, legCtx := range source.contexts {
+		assert.Equal(t, "request", legCtx.Value(key{}))
+	}
+}
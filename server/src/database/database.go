@@ -0,0 +1,100 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var ErrUnsupportedScheme = errors.New("unsupported database scheme")
+
+// Dialect identifica o banco por trás de um *sql.DB
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// DriverName é o nome do driver database/sql do dialeto
+func (d Dialect) DriverName() string {
+	if d == Postgres {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+// Rebind converte os placeholders "?" da consulta para o formato do dialeto
+// ($1, $2, ... no Postgres). As consultas do projeto não usam "?" em literais.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var builder strings.Builder
+	builder.Grow(len(query) + 8)
+
+	position := 0
+	for _, char := range query {
+		if char == '?' {
+			position++
+			builder.WriteByte('$')
+			builder.WriteString(strconv.Itoa(position))
+			continue
+		}
+		builder.WriteRune(char)
+	}
+
+	return builder.String()
+}
+
+// ParseDSN escolhe o dialeto pelo esquema do DSN e devolve o DSN esperado pelo
+// driver. Aceita postgres://, postgresql://, sqlite:// e sqlite:; um caminho
+// sem esquema (ex.: ./quotations.db) continua abrindo um arquivo SQLite.
+func ParseDSN(dsn string) (Dialect, string, error) {
+	scheme, rest, hasScheme := strings.Cut(dsn, ":")
+	if !hasScheme {
+		return SQLite, dsn, nil
+	}
+
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return Postgres, dsn, nil
+	case "sqlite", "sqlite3":
+		path := strings.TrimPrefix(rest, "//")
+		if path == "" {
+			return "", "", fmt.Errorf("%w: missing SQLite path in %q", ErrUnsupportedScheme, dsn)
+		}
+		return SQLite, path, nil
+	case "file":
+		// URI aceita diretamente pelo go-sqlite3 (ex.: file:test.db?cache=shared)
+		return SQLite, dsn, nil
+	}
+
+	if strings.HasPrefix(rest, "//") {
+		return "", "", fmt.Errorf("%w: %q (expected postgres:// or sqlite://)", ErrUnsupportedScheme, scheme)
+	}
+
+	// Sem "//" o ":" faz parte do caminho (ex.: C:\dados\quotations.db)
+	return SQLite, dsn, nil
+}
+
+// Open abre a conexão do dialeto indicado pelo DSN
+func Open(dsn string) (*sql.DB, Dialect, error) {
+	dialect, driverDSN, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	db, err := sql.Open(dialect.DriverName(), driverDSN)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return db, dialect, nil
+}
@@ -0,0 +1,329 @@
+package decimal
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Limites da entrada aceita, para que um valor vindo de provedores, do banco
+// ou da query string não faça o parser montar números gigantes
+const (
+	// MaxDigits limita os dígitos da parte inteira somados aos da fracionária
+	MaxDigits = 64
+	// MaxExponent limita o módulo do expoente de números JSON
+	MaxExponent = 64
+)
+
+var (
+	ErrInvalidDecimal = errors.New("invalid decimal")
+	ErrDivisionByZero = errors.New("division by zero")
+)
+
+// Decimal é um número decimal de ponto fixo exato. O valor é guardado na forma
+// canônica em texto, preservando as casas decimais informadas (5.8500 continua
+// 5.8500), o que mantém a precisão do provedor e permite comparar valores com ==.
+// O valor zero de Decimal representa 0.
+type Decimal struct {
+	value string
+}
+
+// Parse aceita números como "5.8576", "-0.0313" ou "+12", com até MaxDigits
+// dígitos. Notação científica, separadores de milhar e valores vazios são
+// rejeitados.
+func Parse(value string) (Decimal, error) {
+	return parse(value, MaxDigits)
+}
+
+// parse lê o decimal com no máximo maxDigits dígitos; zero não limita, para
+// os resultados de operações, que podem passar de MaxDigits
+func parse(value string, maxDigits int) (Decimal, error) {
+	s := strings.TrimSpace(value)
+
+	negative := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	integer, fraction, hasPoint := strings.Cut(s, ".")
+	if integer == "" || (hasPoint && fraction == "") || !isDigits(integer) || !isDigits(fraction) {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, value)
+	}
+	if maxDigits > 0 && len(integer)+len(fraction) > maxDigits {
+		return Decimal{}, fmt.Errorf("%w: more than %d digits", ErrInvalidDecimal, maxDigits)
+	}
+
+	integer = strings.TrimLeft(integer, "0")
+	if integer == "" {
+		integer = "0"
+	}
+
+	canonical := integer
+	if fraction != "" {
+		canonical += "." + fraction
+	}
+	if negative && strings.Trim(canonical, "0.") != "" {
+		canonical = "-" + canonical
+	}
+
+	return Decimal{value: canonical}, nil
+}
+
+// MustParse é como Parse, mas entra em pânico para valores inválidos. Útil em
+// constantes e testes.
+func MustParse(value string) Decimal {
+	d, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// New cria um Decimal igual a unscaled * 10^-scale
+func New(unscaled int64, scale int) Decimal {
+	return fromBig(big.NewInt(unscaled), scale)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (d Decimal) String() string {
+	if d.value == "" {
+		return "0"
+	}
+	return d.value
+}
+
+// Scale retorna a quantidade de casas decimais
+func (d Decimal) Scale() int {
+	_, fraction, _ := strings.Cut(d.String(), ".")
+	return len(fraction)
+}
+
+// unscaled retorna o inteiro sem a vírgula, de forma que d = unscaled * 10^-Scale()
+func (d Decimal) unscaled() *big.Int {
+	unscaled, _ := new(big.Int).SetString(strings.Replace(d.String(), ".", "", 1), 10)
+	return unscaled
+}
+
+func fromBig(unscaled *big.Int, scale int) Decimal {
+	negative := unscaled.Sign() < 0
+	digits := new(big.Int).Abs(unscaled).String()
+
+	if scale > 0 {
+		if len(digits) <= scale {
+			digits = strings.Repeat("0", scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+	}
+	if negative {
+		digits = "-" + digits
+	}
+
+	d, err := parse(digits, 0)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) Sign() int {
+	return d.unscaled().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Cmp compara numericamente, ignorando diferenças de escala (5.80 == 5.8)
+func (d Decimal) Cmp(other Decimal) int {
+	return d.Rat().Cmp(other.Rat())
+}
+
+// Equal compara numericamente, ignorando diferenças de escala
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// Rat converte para um racional exato
+func (d Decimal) Rat() *big.Rat {
+	denominator := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale())), nil)
+	return new(big.Rat).SetFrac(d.unscaled(), denominator)
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	scale := max(d.Scale(), other.Scale())
+	sum := new(big.Int).Add(d.rescale(scale), other.rescale(scale))
+	return fromBig(sum, scale)
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	scale := max(d.Scale(), other.Scale())
+	difference := new(big.Int).Sub(d.rescale(scale), other.rescale(scale))
+	return fromBig(difference, scale)
+}
+
+// Mul multiplica sem perda de precisão; a escala do resultado é a soma das escalas
+func (d Decimal) Mul(other Decimal) Decimal {
+	product := new(big.Int).Mul(d.unscaled(), other.unscaled())
+	return fromBig(product, d.Scale()+other.Scale())
+}
+
+// Div divide arredondando o resultado para scale casas decimais (half-even)
+func (d Decimal) Div(other Decimal, scale int) (Decimal, error) {
+	if other.IsZero() {
+		return Decimal{}, ErrDivisionByZero
+	}
+	return FromRat(new(big.Rat).Quo(d.Rat(), other.Rat()), scale), nil
+}
+
+// Round arredonda para scale casas decimais (half-even). Valores com menos casas
+// são devolvidos sem alteração.
+func (d Decimal) Round(scale int) Decimal {
+	if d.Scale() <= scale {
+		return d
+	}
+	return FromRat(d.Rat(), scale)
+}
+
+// FromRat converte um racional arredondando para scale casas decimais (half-even)
+func FromRat(r *big.Rat, scale int) Decimal {
+	factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(factor))
+
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+
+	// Compara 2*|resto| com o denominador para decidir o arredondamento
+	twice := new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2))
+	switch twice.Cmp(scaled.Denom()) {
+	case 1:
+		quotient.Add(quotient, big.NewInt(int64(scaled.Sign())))
+	case 0:
+		if quotient.Bit(0) == 1 {
+			quotient.Add(quotient, big.NewInt(int64(scaled.Sign())))
+		}
+	}
+
+	return fromBig(quotient, scale)
+}
+
+func (d Decimal) rescale(scale int) *big.Int {
+	factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.Scale())), nil)
+	return new(big.Int).Mul(d.unscaled(), factor)
+}
+
+// MarshalJSON serializa como string para não perder precisão em clientes que
+// leem números JSON como float
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON aceita tanto strings ("5.8576") quanto números (5.8576 ou 1.2e-05)
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	raw := string(data)
+	if raw == "null" {
+		*d = Decimal{}
+		return nil
+	}
+
+	parse := parseNumber
+	if strings.HasPrefix(raw, `"`) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		raw = s
+		parse = Parse
+	}
+
+	parsed, err := parse(raw)
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+// parseNumber aceita também a notação com expoente que números JSON podem usar,
+// com expoente de módulo até MaxExponent e resultado de até MaxDigits dígitos
+func parseNumber(value string) (Decimal, error) {
+	mantissa, rawExponent, hasExponent := strings.Cut(strings.ToLower(value), "e")
+
+	d, err := Parse(mantissa)
+	if err != nil || !hasExponent {
+		return d, err
+	}
+
+	exponent, err := strconv.Atoi(rawExponent)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, value)
+	}
+	if exponent > MaxExponent || exponent < -MaxExponent {
+		return Decimal{}, fmt.Errorf("%w: exponent %d outside ±%d", ErrInvalidDecimal, exponent, MaxExponent)
+	}
+
+	if exponent < 0 {
+		d = fromBig(d.unscaled(), d.Scale()-exponent)
+	} else {
+		factor := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+		d = fromBig(new(big.Int).Mul(d.unscaled(), factor), d.Scale())
+	}
+
+	if digits := len(strings.Replace(strings.TrimPrefix(d.value, "-"), ".", "", 1)); digits > MaxDigits {
+		return Decimal{}, fmt.Errorf("%w: more than %d digits", ErrInvalidDecimal, MaxDigits)
+	}
+	return d, nil
+}
+
+// Value grava o decimal na forma canônica em texto. O SQLite não tem um tipo
+// decimal exato, então guardar como texto é o que preserva todas as casas.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan lê valores gravados como texto ou, em bancos antigos/colunas numéricas,
+// como inteiro ou float
+func (d *Decimal) Scan(src any) error {
+	var raw string
+
+	switch value := src.(type) {
+	case nil:
+		*d = Decimal{}
+		return nil
+	case string:
+		raw = value
+	case []byte:
+		raw = string(value)
+	case int64:
+		raw = strconv.FormatInt(value, 10)
+	case float64:
+		raw = strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidDecimal, src)
+	}
+
+	if raw == "" {
+		*d = Decimal{}
+		return nil
+	}
+
+	parsed, err := Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
@@ -0,0 +1,176 @@
+package decimal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		wantErr  bool
+	}{
+		{value: "5.8576", expected: "5.8576"},
+		{value: "5.8500", expected: "5.8500"},
+		{value: "-0.0313", expected: "-0.0313"},
+		{value: "+12", expected: "12"},
+		{value: "007.10", expected: "7.10"},
+		{value: "-0.00", expected: "0.00"},
+		{value: " 350123.45678901 ", expected: "350123.45678901"},
+		{value: "", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "1e5", wantErr: true},
+		{value: "1.2.3", wantErr: true},
+		{value: "5.", wantErr: true},
+		{value: ".5", wantErr: true},
+		{value: "1,000.00", wantErr: true},
+		{value: "-", wantErr: true},
+		{value: "NaN", wantErr: true},
+		{value: strings.Repeat("9", 32) + "." + strings.Repeat("9", 32), expected: strings.Repeat("9", 32) + "." + strings.Repeat("9", 32)},
+		{value: strings.Repeat("9", 33) + "." + strings.Repeat("9", 32), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			d, err := Parse(tt.value)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidDecimal)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, d.String())
+		})
+	}
+}
+
+func TestZeroValue(t *testing.T) {
+	var d Decimal
+
+	assert.Equal(t, "0", d.String())
+	assert.True(t, d.IsZero())
+	assert.Equal(t, "5.8576", d.Add(MustParse("5.8576")).String())
+}
+
+func TestArithmetic(t *testing.T) {
+	a := MustParse("123.45")
+	b := MustParse("5.8576")
+
+	assert.Equal(t, "723.120720", a.Mul(b).String())
+	assert.Equal(t, "129.3076", a.Add(b).String())
+	assert.Equal(t, "117.5924", a.Sub(b).String())
+	assert.Equal(t, "-117.5924", b.Sub(a).String())
+	assert.Equal(t, "0.1", MustParse("0.3").Sub(MustParse("0.2")).String())
+
+	quotient, err := MustParse("1").Div(b, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "0.1707183830", quotient.String())
+
+	_, err = a.Div(Decimal{}, 2)
+	assert.ErrorIs(t, err, ErrDivisionByZero)
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		value    string
+		scale    int
+		expected string
+	}{
+		{value: "5.8576", scale: 2, expected: "5.86"},
+		{value: "5.855", scale: 2, expected: "5.86"},
+		{value: "5.845", scale: 2, expected: "5.84"},
+		{value: "-5.855", scale: 2, expected: "-5.86"},
+		{value: "-0.004", scale: 2, expected: "0.00"},
+		{value: "5.8", scale: 4, expected: "5.8"},
+		{value: "0.5", scale: 0, expected: "0"},
+		{value: "1.5", scale: 0, expected: "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MustParse(tt.value).Round(tt.scale).String())
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	assert.Equal(t, 0, MustParse("5.80").Cmp(MustParse("5.8")))
+	assert.True(t, MustParse("5.80").Equal(MustParse("5.8")))
+	assert.Equal(t, -1, MustParse("5.8576").Cmp(MustParse("5.8582")))
+	assert.Equal(t, 1, MustParse("10").Cmp(MustParse("9.99")))
+	assert.Equal(t, -1, MustParse("-1").Sign())
+	assert.Equal(t, "0.0042", New(42, 4).String())
+	assert.Equal(t, "-1.5", New(-15, 1).String())
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Bid Decimal `json:"bid"`
+	}{Bid: MustParse("5.8500")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"bid":"5.8500"}`, string(data))
+
+	var decoded struct {
+		Bid Decimal `json:"bid"`
+		Ask Decimal `json:"ask"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"bid":"5.8576","ask":5.8582}`), &decoded))
+	assert.Equal(t, "5.8576", decoded.Bid.String())
+	assert.Equal(t, "5.8582", decoded.Ask.String())
+
+	require.NoError(t, json.Unmarshal([]byte(`{"bid":1.25e-05,"ask":3.5E2}`), &decoded))
+	assert.Equal(t, "0.0000125", decoded.Bid.String())
+	assert.Equal(t, "350.0", decoded.Ask.String())
+
+	// Huge exponents are rejected before any big number is built
+	require.NoError(t, json.Unmarshal([]byte(`{"bid":1e63,"ask":1E-63}`), &decoded))
+	for _, number := range []string{"1e999999999", "1e-999999999", "1e65", "1E-65", "10e63"} {
+		err := json.Unmarshal([]byte(`{"bid":`+number+`}`), &decoded)
+		assert.ErrorIs(t, err, ErrInvalidDecimal, number)
+	}
+
+	// Exponents are only accepted in JSON numbers
+	assert.Error(t, json.Unmarshal([]byte(`{"bid":"1e5"}`), &decoded))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"bid":"abc"}`), &decoded))
+}
+
+func TestSQL(t *testing.T) {
+	value, err := MustParse("5.8500").Value()
+	require.NoError(t, err)
+	assert.Equal(t, "5.8500", value)
+
+	tests := []struct {
+		src      any
+		expected string
+		wantErr  bool
+	}{
+		{src: "5.8576", expected: "5.8576"},
+		{src: []byte("6.3894"), expected: "6.3894"},
+		{src: int64(42), expected: "42"},
+		{src: 5.8576, expected: "5.8576"},
+		{src: nil, expected: "0"},
+		{src: "", expected: "0"},
+		{src: "abc", wantErr: true},
+		{src: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var d Decimal
+		err := d.Scan(tt.src)
+
+		if tt.wantErr {
+			assert.Error(t, err, tt.src)
+			continue
+		}
+
+		require.NoError(t, err, tt.src)
+		assert.Equal(t, tt.expected, d.String())
+	}
+}
@@ -0,0 +1,136 @@
+package gateways
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+)
+
+// rawQuotation espelha o formato devolvido pela AwesomeAPI, onde todos os campos são strings
+type rawQuotation struct {
+	Code       string `json:"code"`
+	Codein     string `json:"codein"`
+	Name       string `json:"name"`
+	High       string `json:"high"`
+	Low        string `json:"low"`
+	VarBid     string `json:"varBid"`
+	PctChange  string `json:"pctChange"`
+	Bid        string `json:"bid"`
+	Ask        string `json:"ask"`
+	Timestamp  string `json:"timestamp"`
+	CreateDate string `json:"create_date"`
+}
+
+// AwesomeAPIProvider busca cotações em economia.awesomeapi.com.br
+type AwesomeAPIProvider struct {
+	URL string
+}
+
+func NewAwesomeAPIProvider() *AwesomeAPIProvider {
+	return &AwesomeAPIProvider{
+		URL: "https://economia.awesomeapi.com.br/json/last",
+	}
+}
+
+func (p *AwesomeAPIProvider) Name() string {
+	return "awesomeapi"
+}
+
+func (p *AwesomeAPIProvider) FetchQuotation(ctx context.Context, pair Pair) (Quotation, error) {
+	url := strings.TrimSuffix(p.URL, "/") + "/" + pair.String()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Erro ao criar requisição", "provider", p.Name(), "pair", pair, "error", err)
+		return Quotation{}, err
+	}
+
+	c := &http.Client{}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			slog.WarnContext(ctx, "Tempo excedido ao chamar provedor", "provider", p.Name(), "pair", pair, "error", err)
+		} else {
+			slog.WarnContext(ctx, "Erro ao chamar provedor", "provider", p.Name(), "pair", pair, "error", err)
+		}
+		return Quotation{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.WarnContext(ctx, "Provedor respondeu com status inesperado", "provider", p.Name(), "pair", pair, "status", resp.StatusCode)
+		return Quotation{}, fmt.Errorf("%w %d for %s", ErrUnexpectedStatus, resp.StatusCode, pair)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		slog.WarnContext(ctx, "Erro ao ler corpo da resposta", "provider", p.Name(), "pair", pair, "error", err)
+		return Quotation{}, err
+	}
+
+	var rawQuotations map[string]json.RawMessage
+	err = json.Unmarshal(body, &rawQuotations)
+	if err != nil {
+		slog.WarnContext(ctx, "Erro ao desserializar JSON", "provider", p.Name(), "pair", pair, "error", err)
+		return Quotation{}, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+
+	rawPair, ok := rawQuotations[pair.Key()]
+	if !ok {
+		slog.WarnContext(ctx, "Cotação ausente na resposta do provedor", "provider", p.Name(), "pair", pair)
+		return Quotation{}, fmt.Errorf("%w: %s", ErrQuotationNotFound, pair)
+	}
+
+	var raw rawQuotation
+	err = json.Unmarshal(rawPair, &raw)
+	if err != nil {
+		slog.WarnContext(ctx, "Erro ao desserializar cotação", "provider", p.Name(), "pair", pair, "error", err)
+		return Quotation{}, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+
+	createDate, err := time.Parse("2006-01-02 15:04:05", raw.CreateDate)
+	if err != nil {
+		slog.WarnContext(ctx, "Erro ao analisar create_date", "provider", p.Name(), "pair", pair, "error", err)
+		return Quotation{}, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+
+	quotation := Quotation{
+		Code:       raw.Code,
+		Codein:     raw.Codein,
+		Name:       raw.Name,
+		Timestamp:  raw.Timestamp,
+		CreateDate: createDate,
+		Provider:   p.Name(),
+	}
+
+	prices := []struct {
+		field string
+		raw   string
+		dest  *decimal.Decimal
+	}{
+		{"high", raw.High, &quotation.High},
+		{"low", raw.Low, &quotation.Low},
+		{"varBid", raw.VarBid, &quotation.VarBid},
+		{"pctChange", raw.PctChange, &quotation.PctChange},
+		{"bid", raw.Bid, &quotation.Bid},
+		{"ask", raw.Ask, &quotation.Ask},
+	}
+	for _, price := range prices {
+		*price.dest, err = decimal.Parse(price.raw)
+		if err != nil {
+			slog.WarnContext(ctx, "Erro ao analisar preço", "provider", p.Name(), "pair", pair, "field", price.field, "error", err)
+			return Quotation{}, fmt.Errorf("%w: %s: %w", ErrInvalidQuotation, price.field, err)
+		}
+	}
+
+	return quotation, nil
+}
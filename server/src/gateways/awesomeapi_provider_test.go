@@ -0,0 +1,160 @@
+package gateways
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAwesomeAPIProviderFetchQuotation(t *testing.T) {
+	tests := []struct {
+		name          string
+		pair          Pair
+		responseBody  string
+		statusCode    int
+		wantErr       bool
+		expectedBid   string
+		expectedClass string
+	}{
+		{
+			name:         "success",
+			pair:         "USD-BRL",
+			responseBody: `{"USDBRL":{"code":"USD","codein":"BRL","name":"Dólar Americano/Real Brasileiro","high":"5.8688","low":"5.8213","varBid":"0.0313","pctChange":"0.54","bid":"5.8576","ask":"5.8582","timestamp":"1701278942","create_date":"2023-11-29 17:55:42"}}`,
+			statusCode:   http.StatusOK,
+			wantErr:      false,
+			expectedBid:  "5.8576",
+		},
+		{
+			name:         "other pair",
+			pair:         "EUR-BRL",
+			responseBody: `{"EURBRL":{"code":"EUR","codein":"BRL","name":"Euro/Real Brasileiro","high":"6.4102","low":"6.3511","varBid":"0.0214","pctChange":"0.33","bid":"6.3894","ask":"6.3922","timestamp":"1701278942","create_date":"2023-11-29 17:55:42"}}`,
+			statusCode:   http.StatusOK,
+			wantErr:      false,
+			expectedBid:  "6.3894",
+		},
+		{
+			name:          "pair missing from response",
+			pair:          "EUR-BRL",
+			responseBody:  `{"USDBRL":{"code":"USD","codein":"BRL","name":"Dólar Americano/Real Brasileiro","high":"5.8688","low":"5.8213","varBid":"0.0313","pctChange":"0.54","bid":"5.8576","ask":"5.8582","timestamp":"1701278942","create_date":"2023-11-29 17:55:42"}}`,
+			statusCode:    http.StatusOK,
+			wantErr:       true,
+			expectedBid:   "",
+			expectedClass: "invalid",
+		},
+		{
+			name:          "malformed bid",
+			pair:          "USD-BRL",
+			responseBody:  `{"USDBRL":{"code":"USD","codein":"BRL","name":"Dólar Americano/Real Brasileiro","high":"5.8688","low":"5.8213","varBid":"0.0313","pctChange":"0.54","bid":"5,8576","ask":"5.8582","timestamp":"1701278942","create_date":"2023-11-29 17:55:42"}}`,
+			statusCode:    http.StatusOK,
+			wantErr:       true,
+			expectedBid:   "",
+			expectedClass: "invalid",
+		},
+		{
+			name:          "invalid json",
+			pair:          "USD-BRL",
+			responseBody:  `invalid json`,
+			statusCode:    http.StatusOK,
+			wantErr:       true,
+			expectedBid:   "",
+			expectedClass: "decode",
+		},
+		{
+			name:          "server error",
+			pair:          "USD-BRL",
+			responseBody:  ``,
+			statusCode:    http.StatusInternalServerError,
+			wantErr:       true,
+			expectedBid:   "",
+			expectedClass: "http",
+		},
+		{
+			name:          "unknown pair",
+			pair:          "XYZ-BRL",
+			responseBody:  `{"status":404,"code":"CoinNotExists","message":"moeda nao encontrada XYZ-BRL"}`,
+			statusCode:    http.StatusNotFound,
+			wantErr:       true,
+			expectedBid:   "",
+			expectedClass: "http",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create a test server
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/"+tt.pair.String(), r.URL.Path)
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.responseBody))
+			}))
+			defer server.Close()
+
+			// Create a provider with the test server URL
+			provider := &AwesomeAPIProvider{
+				URL: server.URL,
+			}
+
+			// Call the function
+			quotation, err := provider.FetchQuotation(context.Background(), tt.pair)
+
+			// Check if we expected an error
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, tt.expectedClass, ErrorClass(err))
+				return
+			}
+
+			// Otherwise check the results
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedBid, quotation.Bid.String())
+			assert.Equal(t, tt.pair, quotation.Pair())
+			assert.Equal(t, "awesomeapi", quotation.Provider)
+		})
+	}
+}
+
+func TestAwesomeAPIProviderTimeout(t *testing.T) {
+	// Create a test server that delays its response
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond) // Sleep longer than the timeout
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"USDBRL":{"code":"USD","codein":"BRL","name":"Dólar Americano/Real Brasileiro","high":"5.8688","low":"5.8213","varBid":"0.0313","pctChange":"0.54","bid":"5.8576","ask":"5.8582","timestamp":"1701278942","create_date":"2023-11-29 17:55:42"}}`))
+	}))
+	defer server.Close()
+
+	// Create a provider with the test server URL
+	provider := &AwesomeAPIProvider{
+		URL: server.URL,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// Call the function
+	_, err := provider.FetchQuotation(ctx, DefaultPair)
+
+	// Check that we got a timeout error
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "context deadline exceeded")
+	assert.Equal(t, ErrorClassTimeout, ErrorClass(err))
+}
+
+func TestExternalURLIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	provider := NewAwesomeAPIProvider()
+	quotation, err := provider.FetchQuotation(context.Background(), DefaultPair)
+
+	require.NoError(t, err)
+	assert.Positive(t, quotation.Bid.Sign())
+	assert.NotEmpty(t, quotation.Code)
+	assert.Equal(t, "USD", quotation.Code)
+	assert.Equal(t, "BRL", quotation.Codein)
+}
@@ -0,0 +1,155 @@
+package gateways
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+const (
+	DefaultFailureThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// BreakerState é o estado do circuit breaker de um provedor
+type BreakerState string
+
+const (
+	// BreakerClosed deixa todas as chamadas passarem, contando falhas seguidas
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejeita as chamadas sem consultar o provedor até o fim do cool-down
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen deixa passar uma única chamada de teste após o cool-down
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStatus é uma fotografia do breaker exposta pelo endpoint de status
+type BreakerStatus struct {
+	Provider            string       `json:"provider"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	FailureThreshold    int          `json:"failure_threshold"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	RetryAt             *time.Time   `json:"retry_at,omitempty"`
+}
+
+// CircuitBreaker abre depois de FailureThreshold falhas seguidas e, passado o
+// Cooldown, deixa uma chamada de teste decidir se volta a fechar ou se abre de novo
+type CircuitBreaker struct {
+	FailureThreshold int
+	Cooldown         time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, cooldown time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = DefaultFailureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		Cooldown:         cooldown,
+		state:            BreakerClosed,
+		now:              time.Now,
+	}
+}
+
+// Allow informa se uma chamada pode ser feita. Com o breaker aberto devolve
+// ErrCircuitOpen; toda chamada permitida deve ser seguida de Success, Failure
+// ou Release.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.Cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		// Apenas uma chamada de teste por vez
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Success fecha o breaker e zera a contagem de falhas
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure conta uma falha; a chamada de teste que falha reabre o breaker
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.state == BreakerHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Release libera uma chamada permitida que não chegou a um resultado (ex.:
+// quem chamou cancelou), sem contar sucesso nem falha
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// Status devolve o estado atual do breaker do provedor informado
+func (b *CircuitBreaker) Status(provider string) BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Provider:            provider,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		FailureThreshold:    b.FailureThreshold,
+	}
+
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt.UTC()
+		retryAt := openedAt.Add(b.Cooldown)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+
+	return status
+}
@@ -0,0 +1,124 @@
+package gateways
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBreaker returns a breaker driven by a fake clock
+func newTestBreaker(threshold int, cooldown time.Duration) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker(threshold, cooldown)
+	breaker.now = func() time.Time { return now }
+	return breaker, &now
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	breaker, _ := newTestBreaker(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, breaker.Allow())
+		breaker.Failure()
+		assert.Equal(t, BreakerClosed, breaker.State())
+	}
+
+	require.NoError(t, breaker.Allow())
+	breaker.Failure()
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	breaker, _ := newTestBreaker(2, time.Minute)
+
+	require.NoError(t, breaker.Allow())
+	breaker.Failure()
+	require.NoError(t, breaker.Allow())
+	breaker.Success()
+	require.NoError(t, breaker.Allow())
+	breaker.Failure()
+
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name     string
+		probeOK  bool
+		expected BreakerState
+	}{
+		{name: "probe succeeds", probeOK: true, expected: BreakerClosed},
+		{name: "probe fails", probeOK: false, expected: BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breaker, now := newTestBreaker(1, time.Minute)
+
+			require.NoError(t, breaker.Allow())
+			breaker.Failure()
+			require.Equal(t, BreakerOpen, breaker.State())
+
+			// Still cooling down
+			*now = now.Add(59 * time.Second)
+			assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+
+			// After the cool-down a single trial call goes through
+			*now = now.Add(time.Second)
+			require.NoError(t, breaker.Allow())
+			assert.Equal(t, BreakerHalfOpen, breaker.State())
+			assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+
+			if tt.probeOK {
+				breaker.Success()
+			} else {
+				breaker.Failure()
+			}
+			assert.Equal(t, tt.expected, breaker.State())
+		})
+	}
+}
+
+func TestCircuitBreakerRelease(t *testing.T) {
+	breaker, now := newTestBreaker(1, time.Minute)
+
+	require.NoError(t, breaker.Allow())
+	breaker.Failure()
+	*now = now.Add(time.Minute)
+	require.NoError(t, breaker.Allow())
+
+	// A trial call that ended without a result frees the slot for another one
+	breaker.Release()
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	assert.NoError(t, breaker.Allow())
+}
+
+func TestCircuitBreakerStatus(t *testing.T) {
+	breaker, now := newTestBreaker(1, time.Minute)
+
+	status := breaker.Status("awesomeapi")
+	assert.Equal(t, BreakerStatus{Provider: "awesomeapi", State: BreakerClosed, FailureThreshold: 1}, status)
+
+	openedAt := *now
+	require.NoError(t, breaker.Allow())
+	breaker.Failure()
+
+	status = breaker.Status("awesomeapi")
+	assert.Equal(t, BreakerOpen, status.State)
+	assert.Equal(t, 1, status.ConsecutiveFailures)
+	require.NotNil(t, status.OpenedAt)
+	require.NotNil(t, status.RetryAt)
+	assert.Equal(t, openedAt, *status.OpenedAt)
+	assert.Equal(t, openedAt.Add(time.Minute), *status.RetryAt)
+}
+
+func TestNewCircuitBreakerDefaults(t *testing.T) {
+	breaker := NewCircuitBreaker(0, 0)
+
+	assert.Equal(t, DefaultFailureThreshold, breaker.FailureThreshold)
+	assert.Equal(t, DefaultBreakerCooldown, breaker.Cooldown)
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
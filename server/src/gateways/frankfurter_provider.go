@@ -0,0 +1,124 @@
+package gateways
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+)
+
+// frankfurterResponse espelha o corpo de GET /latest da Frankfurter
+type frankfurterResponse struct {
+	Amount decimal.Decimal            `json:"amount"`
+	Base   string                     `json:"base"`
+	Date   string                     `json:"date"`
+	Rates  map[string]decimal.Decimal `json:"rates"`
+}
+
+// frankfurterUnsupported são as moedas dos pares suportados que o BCE não publica
+var frankfurterUnsupported = []string{"ARS", "BTC"}
+
+// FrankfurterProvider busca taxas de referência do BCE em api.frankfurter.app.
+// A API publica uma única taxa diária, então bid, ask, high e low recebem o
+// mesmo valor e a variação não é informada. Pares com ARS ou BTC devolvem
+// ErrUnsupportedPair sem consultar a API.
+type FrankfurterProvider struct {
+	URL string
+}
+
+func NewFrankfurterProvider() *FrankfurterProvider {
+	return &FrankfurterProvider{
+		URL: "https://api.frankfurter.app",
+	}
+}
+
+func (p *FrankfurterProvider) Name() string {
+	return "frankfurter"
+}
+
+func (p *FrankfurterProvider) FetchQuotation(ctx context.Context, pair Pair) (Quotation, error) {
+	if slices.Contains(frankfurterUnsupported, pair.Code()) || slices.Contains(frankfurterUnsupported, pair.Codein()) {
+		return Quotation{}, fmt.Errorf("%w: %s is not published by %s", ErrUnsupportedPair, pair, p.Name())
+	}
+
+	query := url.Values{}
+	query.Set("from", pair.Code())
+	query.Set("to", pair.Codein())
+	endpoint := strings.TrimSuffix(p.URL, "/") + "/latest?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Erro ao criar requisição", "provider", p.Name(), "pair", pair, "error", err)
+		return Quotation{}, err
+	}
+
+	c := &http.Client{}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			slog.WarnContext(ctx, "Tempo excedido ao chamar provedor", "provider", p.Name(), "pair", pair, "error", err)
+		} else {
+			slog.WarnContext(ctx, "Erro ao chamar provedor", "provider", p.Name(), "pair", pair, "error", err)
+		}
+		return Quotation{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		slog.WarnContext(ctx, "Provedor respondeu com status inesperado", "provider", p.Name(), "pair", pair, "status", resp.StatusCode)
+		return Quotation{}, fmt.Errorf("%w %d for %s", ErrUnexpectedStatus, resp.StatusCode, pair)
+	}
+
+	// Decodifica números como texto para não perder precisão em float64
+	var body frankfurterResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		slog.WarnContext(ctx, "Erro ao desserializar JSON", "provider", p.Name(), "pair", pair, "error", err)
+		return Quotation{}, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+
+	rate, ok := body.Rates[pair.Codein()]
+	if !ok || body.Amount.IsZero() {
+		slog.WarnContext(ctx, "Cotação ausente na resposta do provedor", "provider", p.Name(), "pair", pair)
+		return Quotation{}, fmt.Errorf("%w: %s", ErrQuotationNotFound, pair)
+	}
+
+	createDate, err := time.Parse("2006-01-02", body.Date)
+	if err != nil {
+		slog.WarnContext(ctx, "Erro ao analisar date", "provider", p.Name(), "pair", pair, "error", err)
+		return Quotation{}, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+
+	price, err := rate.Div(body.Amount, max(rate.Scale(), 8))
+	if err != nil {
+		return Quotation{}, err
+	}
+	if body.Amount.Equal(decimal.MustParse("1")) {
+		price = rate
+	}
+
+	quotation := Quotation{
+		Code:       body.Base,
+		Codein:     pair.Codein(),
+		Name:       pair.String(),
+		High:       price,
+		Low:        price,
+		Bid:        price,
+		Ask:        price,
+		Timestamp:  strconv.FormatInt(createDate.Unix(), 10),
+		CreateDate: createDate,
+		Provider:   p.Name(),
+	}
+
+	return quotation, nil
+}
@@ -0,0 +1,106 @@
+package gateways
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrankfurterProviderFetchQuotation(t *testing.T) {
+	tests := []struct {
+		name          string
+		pair          Pair
+		responseBody  string
+		statusCode    int
+		wantErr       bool
+		expectedBid   string
+		expectedClass string
+	}{
+		{
+			name:         "success",
+			pair:         "USD-BRL",
+			responseBody: `{"amount":1.0,"base":"USD","date":"2023-11-29","rates":{"BRL":4.8921}}`,
+			statusCode:   http.StatusOK,
+			expectedBid:  "4.8921",
+		},
+		{
+			name:         "high precision rate",
+			pair:         "GBP-USD",
+			responseBody: `{"amount":1.0,"base":"GBP","date":"2023-11-29","rates":{"USD":1.268123456789012}}`,
+			statusCode:   http.StatusOK,
+			expectedBid:  "1.268123456789012",
+		},
+		{
+			name:          "rate missing from response",
+			pair:          "USD-BRL",
+			responseBody:  `{"amount":1.0,"base":"USD","date":"2023-11-29","rates":{"EUR":0.9121}}`,
+			statusCode:    http.StatusOK,
+			wantErr:       true,
+			expectedClass: "invalid",
+		},
+		{
+			name:          "invalid json",
+			pair:          "USD-BRL",
+			responseBody:  `invalid json`,
+			statusCode:    http.StatusOK,
+			wantErr:       true,
+			expectedClass: "decode",
+		},
+		{
+			name:          "unpublished currency is not requested",
+			pair:          "ARS-BRL",
+			wantErr:       true,
+			expectedClass: "unsupported",
+		},
+		{
+			name:          "unpublished base currency is not requested",
+			pair:          "BTC-USD",
+			wantErr:       true,
+			expectedClass: "unsupported",
+		},
+		{
+			name:          "not found",
+			pair:          "USD-BRL",
+			responseBody:  `{"message":"not found"}`,
+			statusCode:    http.StatusNotFound,
+			wantErr:       true,
+			expectedClass: "http",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.NotEqual(t, "unsupported", tt.expectedClass, "unpublished pairs must not reach the API")
+				assert.Equal(t, "/latest", r.URL.Path)
+				assert.Equal(t, tt.pair.Code(), r.URL.Query().Get("from"))
+				assert.Equal(t, tt.pair.Codein(), r.URL.Query().Get("to"))
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.responseBody))
+			}))
+			defer server.Close()
+
+			provider := &FrankfurterProvider{
+				URL: server.URL,
+			}
+
+			quotation, err := provider.FetchQuotation(context.Background(), tt.pair)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, tt.expectedClass, ErrorClass(err))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedBid, quotation.Bid.String())
+			assert.Equal(t, tt.expectedBid, quotation.Ask.String())
+			assert.Equal(t, tt.pair, quotation.Pair())
+			assert.Equal(t, "frankfurter", quotation.Provider)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package gateways
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultPair é o par servido por GET /cotacao quando nenhum par é informado
+const DefaultPair Pair = "USD-BRL"
+
+var ErrUnsupportedPair = errors.New("unsupported currency pair")
+
+// Pair representa um par de moedas no formato "CODE-CODEIN" (ex.: USD-BRL)
+type Pair string
+
+// SupportedPairs lista os pares aceitos pelo servidor
+var SupportedPairs = []Pair{
+	"USD-BRL",
+	"EUR-BRL",
+	"GBP-BRL",
+	"ARS-BRL",
+	"BTC-BRL",
+	"USD-EUR",
+	"EUR-USD",
+	"GBP-USD",
+	"BTC-USD",
+}
+
+func ParsePair(value string) (Pair, error) {
+	pair := Pair(strings.ToUpper(strings.TrimSpace(value)))
+
+	for _, supported := range SupportedPairs {
+		if pair == supported {
+			return pair, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnsupportedPair, value)
+}
+
+// NewPair monta um par a partir dos códigos das moedas (ex.: "USD", "BRL")
+func NewPair(code, codein string) Pair {
+	return Pair(strings.ToUpper(code) + "-" + strings.ToUpper(codein))
+}
+
+// Code retorna a moeda base do par (ex.: USD em USD-BRL)
+func (p Pair) Code() string {
+	code, _, _ := strings.Cut(string(p), "-")
+	return code
+}
+
+// Codein retorna a moeda de cotação do par (ex.: BRL em USD-BRL)
+func (p Pair) Codein() string {
+	_, codein, _ := strings.Cut(string(p), "-")
+	return codein
+}
+
+// Key retorna a chave usada pela API externa no corpo da resposta (ex.: USDBRL)
+func (p Pair) Key() string {
+	return p.Code() + p.Codein()
+}
+
+func (p Pair) String() string {
+	return string(p)
+}
@@ -0,0 +1,46 @@
+package gateways
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePair(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected Pair
+		wantErr  bool
+	}{
+		{name: "supported pair", value: "USD-BRL", expected: "USD-BRL"},
+		{name: "lower case", value: "eur-brl", expected: "EUR-BRL"},
+		{name: "surrounding spaces", value: " usd-eur ", expected: "USD-EUR"},
+		{name: "unsupported pair", value: "XYZ-BRL", wantErr: true},
+		{name: "malformed pair", value: "USDBRL", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pair, err := ParsePair(tt.value)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrUnsupportedPair)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, pair)
+		})
+	}
+}
+
+func TestPairParts(t *testing.T) {
+	pair := NewPair("usd", "brl")
+
+	assert.Equal(t, Pair("USD-BRL"), pair)
+	assert.Equal(t, "USD", pair.Code())
+	assert.Equal(t, "BRL", pair.Codein())
+	assert.Equal(t, "USDBRL", pair.Key())
+}
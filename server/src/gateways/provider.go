@@ -0,0 +1,75 @@
+package gateways
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+var (
+	ErrInvalidQuotation = errors.New("invalid quotation")
+	ErrUnexpectedStatus = errors.New("unexpected status code")
+	ErrDecode           = errors.New("invalid response body")
+)
+
+// Classes de erro de uma consulta a um provedor, usadas nas métricas
+const (
+	ErrorClassTimeout     = "timeout"
+	ErrorClassCanceled    = "canceled"
+	ErrorClassNetwork     = "network"
+	ErrorClassHTTP        = "http"
+	ErrorClassDecode      = "decode"
+	ErrorClassInvalid     = "invalid"
+	ErrorClassUnsupported = "unsupported"
+	ErrorClassOther       = "other"
+)
+
+// Provider é uma fonte externa de cotações. Implementações devem respeitar o
+// contexto recebido, que carrega o prazo de cada tentativa, e devolver
+// ErrUnsupportedPair para pares que não publicam.
+type Provider interface {
+	Name() string
+	FetchQuotation(ctx context.Context, pair Pair) (Quotation, error)
+}
+
+// validateQuotation rejeita respostas que não correspondem ao par pedido ou
+// cujos preços não são positivos
+func validateQuotation(pair Pair, quotation Quotation) error {
+	if quotation.Pair() != pair {
+		return fmt.Errorf("%w: expected %s, got %s", ErrInvalidQuotation, pair, quotation.Pair())
+	}
+
+	return quotation.Validate()
+}
+
+// ErrorClass agrupa o erro de uma consulta a um provedor: prazo excedido,
+// falha de rede, status HTTP inesperado, corpo ilegível ou cotação inválida.
+// Devolve "" para err nil.
+func ErrorClass(err error) string {
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.Is(err, ErrUnexpectedStatus):
+		return ErrorClassHTTP
+	case errors.Is(err, ErrDecode):
+		return ErrorClassDecode
+	case errors.Is(err, ErrInvalidQuotation), errors.Is(err, ErrQuotationNotFound):
+		return ErrorClassInvalid
+	case errors.Is(err, ErrUnsupportedPair):
+		return ErrorClassUnsupported
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ErrorClassTimeout
+		}
+		return ErrorClassNetwork
+	default:
+		return ErrorClassOther
+	}
+}
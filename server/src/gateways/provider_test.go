@@ -0,0 +1,37 @@
+package gateways
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "no error", err: nil, expected: ""},
+		{name: "deadline", err: fmt.Errorf("awesomeapi: %w", context.DeadlineExceeded), expected: ErrorClassTimeout},
+		{name: "canceled", err: context.Canceled, expected: ErrorClassCanceled},
+		{name: "client timeout", err: &url.Error{Op: "Get", URL: "http://example.com", Err: &net.DNSError{IsTimeout: true}}, expected: ErrorClassTimeout},
+		{name: "connection refused", err: &url.Error{Op: "Get", URL: "http://example.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, expected: ErrorClassNetwork},
+		{name: "status", err: fmt.Errorf("%w %d for %s", ErrUnexpectedStatus, 500, DefaultPair), expected: ErrorClassHTTP},
+		{name: "decode", err: fmt.Errorf("%w: unexpected EOF", ErrDecode), expected: ErrorClassDecode},
+		{name: "invalid quotation", err: fmt.Errorf("%w: bid 0 is not a positive number", ErrInvalidQuotation), expected: ErrorClassInvalid},
+		{name: "missing quotation", err: ErrQuotationNotFound, expected: ErrorClassInvalid},
+		{name: "other", err: errors.New("boom"), expected: ErrorClassOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ErrorClass(tt.err))
+		})
+	}
+}
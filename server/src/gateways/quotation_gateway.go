@@ -2,100 +2,227 @@ package gateways
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"io"
-	"log"
-	"net/http"
+	"fmt"
+	"log/slog"
+	"sync"
 	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+)
+
+var (
+	ErrQuotationNotFound   = errors.New("quotation not found in provider response")
+	ErrAllProvidersFailed  = errors.New("all quotation providers failed")
+	DefaultProviderTimeout = 200 * time.Millisecond
 )
 
-type USDBRL struct {
-	Code       string    `json:"code"`
-	Codein     string    `json:"codein"`
-	Name       string    `json:"name"`
-	High       string    `json:"high"`
-	Low        string    `json:"low"`
-	VarBid     string    `json:"varBid"`
-	PctChange  string    `json:"pctChange"`
-	Bid        string    `json:"bid"`
-	Ask        string    `json:"ask"`
-	Timestamp  string    `json:"timestamp"`
-	CreateDate time.Time `json:"create_date"`
+// FetchObserver é notificado de cada consulta a um provedor, com sua duração e
+// o erro, se houver, e de cada cotação devolvida pelo gateway
+type FetchObserver interface {
+	ObserveFetch(provider string, pair Pair, duration time.Duration, err error)
+	ObserveQuotation(quotation Quotation)
+}
+
+// FetchObservers repassa cada notificação a todos os observadores, em ordem
+type FetchObservers []FetchObserver
+
+func (o FetchObservers) ObserveFetch(provider string, pair Pair, duration time.Duration, err error) {
+	for _, observer := range o {
+		observer.ObserveFetch(provider, pair, duration, err)
+	}
+}
+
+func (o FetchObservers) ObserveQuotation(quotation Quotation) {
+	for _, observer := range o {
+		observer.ObserveQuotation(quotation)
+	}
 }
 
 type Quotation struct {
-	USDBRL `json:"USDBRL"`
+	Code       string          `json:"code"`
+	Codein     string          `json:"codein"`
+	Name       string          `json:"name"`
+	High       decimal.Decimal `json:"high"`
+	Low        decimal.Decimal `json:"low"`
+	VarBid     decimal.Decimal `json:"varBid"`
+	PctChange  decimal.Decimal `json:"pctChange"`
+	Bid        decimal.Decimal `json:"bid"`
+	Ask        decimal.Decimal `json:"ask"`
+	Timestamp  string          `json:"timestamp"`
+	CreateDate time.Time       `json:"create_date"`
+	Provider   string          `json:"provider"`
+	FetchedAt  time.Time       `json:"fetched_at"`
+}
+
+// Pair retorna o par de moedas da cotação (ex.: USD-BRL)
+func (q Quotation) Pair() Pair {
+	return NewPair(q.Code, q.Codein)
+}
+
+// Validate rejeita cotações de pares não suportados ou cujos preços não são
+// positivos, as mesmas que um provedor nunca devolve
+func (q Quotation) Validate() error {
+	if _, err := ParsePair(q.Pair().String()); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidQuotation, err)
+	}
+
+	for field, price := range map[string]decimal.Decimal{"bid": q.Bid, "ask": q.Ask} {
+		if price.Sign() <= 0 {
+			return fmt.Errorf("%w: %s %s is not a positive number", ErrInvalidQuotation, field, price)
+		}
+	}
+
+	return nil
 }
 
+// QuotationGateway consulta os provedores na ordem de prioridade e devolve a
+// primeira cotação válida. Cada provedor tem seu próprio prazo (Timeout),
+// limitado pelo prazo do contexto recebido, e seu próprio circuit breaker, que
+// pula o provedor depois de FailureThreshold falhas seguidas por BreakerCooldown.
 type QuotationGateway struct {
-	URL string
+	Providers        []Provider
+	Timeout          time.Duration
+	FailureThreshold int
+	BreakerCooldown  time.Duration
+	// Observer, quando definido, recebe a duração e o resultado de cada consulta
+	Observer FetchObserver
+
+	mu          sync.Mutex
+	breakers    map[string]*CircuitBreaker
+	lastSuccess time.Time
 }
 
-func NewQuotationGateway() *QuotationGateway {
+// NewQuotationGateway cria o gateway com os provedores informados, em ordem de
+// prioridade. Sem argumentos, usa AwesomeAPI com fallback para Frankfurter.
+func NewQuotationGateway(providers ...Provider) *QuotationGateway {
+	if len(providers) == 0 {
+		providers = []Provider{NewAwesomeAPIProvider(), NewFrankfurterProvider()}
+	}
+
 	return &QuotationGateway{
-		URL: "https://economia.awesomeapi.com.br/json/last/USD-BRL",
+		Providers:        providers,
+		Timeout:          DefaultProviderTimeout,
+		FailureThreshold: DefaultFailureThreshold,
+		BreakerCooldown:  DefaultBreakerCooldown,
 	}
 }
 
-func (g *QuotationGateway) GetQuotation() (Quotation, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(time.Millisecond*200))
-	defer cancel()
+func (g *QuotationGateway) GetQuotation(ctx context.Context, pair Pair) (Quotation, error) {
+	var errs []error
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.URL, nil)
-	if err != nil {
-		log.Printf("Erro ao criar requisição: %v", err)
-		return Quotation{}, err
-	}
+	for _, provider := range g.Providers {
+		breaker := g.breakerFor(provider)
+		if err := breaker.Allow(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			continue
+		}
 
-	c := &http.Client{}
+		quotation, err := g.fetch(ctx, provider, pair)
+		if err == nil {
+			breaker.Success()
+			g.recordSuccess(quotation.FetchedAt)
+			if g.Observer != nil {
+				g.Observer.ObserveQuotation(quotation)
+			}
+			return quotation, nil
+		}
 
-	resp, err := c.Do(req)
-	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			log.Printf("Tempo excedido ao chamar API externa: %v", err)
-		} else {
-			log.Printf("Erro ao chamar API externa: %v", err)
+		// Quem chamou desistiu: não adianta tentar os demais provedores, e a
+		// falha não é culpa do provedor
+		if ctx.Err() != nil {
+			breaker.Release()
+			return Quotation{}, fmt.Errorf("%s: %w", provider.Name(), ctx.Err())
 		}
-		return Quotation{}, err
+
+		// Um par que o provedor não publica não indica que ele está fora: o
+		// breaker não muda e o provedor continua disponível para os demais pares.
+		// O erro não é embrulhado para que a falha de todos os provedores não
+		// pareça um par inválido do cliente.
+		if errors.Is(err, ErrUnsupportedPair) {
+			breaker.Release()
+			slog.DebugContext(ctx, "Provedor não publica o par, tentando o próximo", "provider", provider.Name(), "pair", pair)
+			errs = append(errs, fmt.Errorf("%s: does not publish %s", provider.Name(), pair))
+			continue
+		}
+
+		breaker.Failure()
+		if breaker.State() == BreakerOpen {
+			slog.WarnContext(ctx, "Circuit breaker do provedor aberto", "provider", provider.Name(), "cooldown", breaker.Cooldown)
+		}
+
+		slog.WarnContext(ctx, "Provedor falhou, tentando o próximo", "provider", provider.Name(), "pair", pair, "class", ErrorClass(err), "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Erro ao ler corpo da resposta: %v", err)
-		return Quotation{}, err
+	return Quotation{}, fmt.Errorf("%w: %w", ErrAllProvidersFailed, errors.Join(errs...))
+}
+
+// BreakerStatuses devolve o estado do circuit breaker de cada provedor, na
+// ordem de prioridade
+func (g *QuotationGateway) BreakerStatuses() []BreakerStatus {
+	statuses := make([]BreakerStatus, 0, len(g.Providers))
+	for _, provider := range g.Providers {
+		statuses = append(statuses, g.breakerFor(provider).Status(provider.Name()))
 	}
+	return statuses
+}
 
-	var rawQuotation map[string]map[string]interface{}
-	err = json.Unmarshal(body, &rawQuotation)
-	if err != nil {
-		log.Printf("Erro ao desserializar JSON: %v", err)
-		return Quotation{}, err
+// LastSuccess devolve quando um provedor respondeu com sucesso pela última vez,
+// ou o instante zero se nenhum respondeu ainda
+func (g *QuotationGateway) LastSuccess() time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lastSuccess
+}
+
+func (g *QuotationGateway) recordSuccess(at time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if at.After(g.lastSuccess) {
+		g.lastSuccess = at
 	}
+}
 
-	usdbrl := rawQuotation["USDBRL"]
-	createDate, err := time.Parse("2006-01-02 15:04:05", usdbrl["create_date"].(string))
-	if err != nil {
-		log.Printf("Erro ao analisar create_date: %v", err)
-		return Quotation{}, err
+func (g *QuotationGateway) breakerFor(provider Provider) *CircuitBreaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.breakers == nil {
+		g.breakers = make(map[string]*CircuitBreaker)
+	}
+
+	breaker, ok := g.breakers[provider.Name()]
+	if !ok {
+		breaker = NewCircuitBreaker(g.FailureThreshold, g.BreakerCooldown)
+		g.breakers[provider.Name()] = breaker
+	}
+	return breaker
+}
+
+func (g *QuotationGateway) fetch(ctx context.Context, provider Provider, pair Pair) (Quotation, error) {
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = DefaultProviderTimeout
 	}
 
-	quotation := Quotation{
-		USDBRL: USDBRL{
-			Code:       usdbrl["code"].(string),
-			Codein:     usdbrl["codein"].(string),
-			Name:       usdbrl["name"].(string),
-			High:       usdbrl["high"].(string),
-			Low:        usdbrl["low"].(string),
-			VarBid:     usdbrl["varBid"].(string),
-			PctChange:  usdbrl["pctChange"].(string),
-			Bid:        usdbrl["bid"].(string),
-			Ask:        usdbrl["ask"].(string),
-			Timestamp:  usdbrl["timestamp"].(string),
-			CreateDate: createDate,
-		},
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	quotation, err := provider.FetchQuotation(ctx, pair)
+	if err == nil {
+		err = validateQuotation(pair, quotation)
+	}
+	// Pares não publicados são recusados sem chamar o provedor
+	if g.Observer != nil && !errors.Is(err, ErrUnsupportedPair) {
+		g.Observer.ObserveFetch(provider.Name(), pair, time.Since(start), err)
+	}
+	if err != nil {
+		return Quotation{}, err
 	}
 
+	quotation.Provider = provider.Name()
+	quotation.FetchedAt = time.Now().UTC()
 	return quotation, nil
 }
@@ -1,114 +1,354 @@
 package gateways
 
 import (
-	"net/http"
-	"net/http/httptest"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func TestGetQuotation(t *testing.T) {
+// Fake provider
+type fakeProvider struct {
+	name      string
+	quotation Quotation
+	err       error
+	delay     time.Duration
+	calls     int
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) FetchQuotation(ctx context.Context, pair Pair) (Quotation, error) {
+	p.calls++
+
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return Quotation{}, ctx.Err()
+	}
+
+	return p.quotation, p.err
+}
+
+func validQuotation() Quotation {
+	return Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("5.8582")}
+}
+
+func TestQuotationValidate(t *testing.T) {
 	tests := []struct {
-		name         string
-		responseBody string
-		statusCode   int
-		wantErr      bool
-		expectedBid  string
+		name      string
+		quotation Quotation
+		valid     bool
+	}{
+		{name: "valid", quotation: Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("5.8582")}, valid: true},
+		{name: "unsupported pair", quotation: Quotation{Code: "XYZ", Codein: "BRL", Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("5.8582")}},
+		{name: "missing pair", quotation: Quotation{Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("5.8582")}},
+		{name: "zero bid", quotation: Quotation{Code: "USD", Codein: "BRL", Ask: decimal.MustParse("5.8582")}},
+		{name: "negative ask", quotation: Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("-5.8582")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.quotation.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidQuotation)
+			}
+		})
+	}
+}
+
+func TestGetQuotationFailover(t *testing.T) {
+	tests := []struct {
+		name             string
+		primary          *fakeProvider
+		secondary        *fakeProvider
+		wantErr          bool
+		expectedProvider string
+		secondaryCalls   int
 	}{
 		{
-			name:         "success",
-			responseBody: `{"USDBRL":{"code":"USD","codein":"BRL","name":"Dólar Americano/Real Brasileiro","high":"5.8688","low":"5.8213","varBid":"0.0313","pctChange":"0.54","bid":"5.8576","ask":"5.8582","timestamp":"1701278942","create_date":"2023-11-29 17:55:42"}}`,
-			statusCode:   http.StatusOK,
-			wantErr:      false,
-			expectedBid:  "5.8576",
+			name:             "primary succeeds",
+			primary:          &fakeProvider{name: "primary", quotation: validQuotation()},
+			secondary:        &fakeProvider{name: "secondary", quotation: validQuotation()},
+			expectedProvider: "primary",
+			secondaryCalls:   0,
 		},
 		{
-			name:         "invalid json",
-			responseBody: `invalid json`,
-			statusCode:   http.StatusOK,
-			wantErr:      true,
-			expectedBid:  "",
+			name:             "primary fails",
+			primary:          &fakeProvider{name: "primary", err: errors.New("connection refused")},
+			secondary:        &fakeProvider{name: "secondary", quotation: validQuotation()},
+			expectedProvider: "secondary",
+			secondaryCalls:   1,
 		},
 		{
-			name:         "server error",
-			responseBody: ``,
-			statusCode:   http.StatusInternalServerError,
-			wantErr:      false, // No error because we still get a response, just with no body
-			expectedBid:  "",
+			name:             "primary times out",
+			primary:          &fakeProvider{name: "primary", quotation: validQuotation(), delay: 300 * time.Millisecond},
+			secondary:        &fakeProvider{name: "secondary", quotation: validQuotation()},
+			expectedProvider: "secondary",
+			secondaryCalls:   1,
+		},
+		{
+			name:             "primary returns non positive bid",
+			primary:          &fakeProvider{name: "primary", quotation: Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("0"), Ask: decimal.MustParse("5.8582")}},
+			secondary:        &fakeProvider{name: "secondary", quotation: validQuotation()},
+			expectedProvider: "secondary",
+			secondaryCalls:   1,
+		},
+		{
+			name:             "primary returns another pair",
+			primary:          &fakeProvider{name: "primary", quotation: Quotation{Code: "EUR", Codein: "BRL", Bid: decimal.MustParse("6.3894"), Ask: decimal.MustParse("6.3922")}},
+			secondary:        &fakeProvider{name: "secondary", quotation: validQuotation()},
+			expectedProvider: "secondary",
+			secondaryCalls:   1,
+		},
+		{
+			name:           "all providers fail",
+			primary:        &fakeProvider{name: "primary", err: errors.New("connection refused")},
+			secondary:      &fakeProvider{name: "secondary", quotation: Quotation{}},
+			wantErr:        true,
+			secondaryCalls: 1,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create a test server
-			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(tt.statusCode)
-				w.Write([]byte(tt.responseBody))
-			}))
-			defer server.Close()
-
-			// Create a gateway with the test server URL
-			gateway := &QuotationGateway{
-				URL: server.URL,
-			}
+			gateway := NewQuotationGateway(tt.primary, tt.secondary)
 
-			// Call the function
-			quotation, err := gateway.GetQuotation()
+			quotation, err := gateway.GetQuotation(context.Background(), DefaultPair)
 
-			// Check if we expected an error
-			if tt.wantErr {
-				assert.Error(t, err)
-				return
-			}
+			assert.Equal(t, 1, tt.primary.calls)
+			assert.Equal(t, tt.secondaryCalls, tt.secondary.calls)
 
-			// For server error cases, we might get empty quotation but no error
-			if tt.statusCode != http.StatusOK {
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrAllProvidersFailed)
 				return
 			}
 
-			// Otherwise check the results
 			require.NoError(t, err)
-			assert.Equal(t, tt.expectedBid, quotation.Bid)
+			assert.Equal(t, "5.8576", quotation.Bid.String())
+			assert.Equal(t, tt.expectedProvider, quotation.Provider)
+			assert.WithinDuration(t, time.Now(), quotation.FetchedAt, time.Second)
 		})
 	}
 }
 
 func TestGetQuotationTimeout(t *testing.T) {
-	// Create a test server that delays its response
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		time.Sleep(300 * time.Millisecond) // Sleep longer than the timeout
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"USDBRL":{"code":"USD","codein":"BRL","name":"Dólar Americano/Real Brasileiro","high":"5.8688","low":"5.8213","varBid":"0.0313","pctChange":"0.54","bid":"5.8576","ask":"5.8582","timestamp":"1701278942","create_date":"2023-11-29 17:55:42"}}`))
-	}))
-	defer server.Close()
-
-	// Create a gateway with the test server URL
-	gateway := &QuotationGateway{
-		URL: server.URL,
-	}
+	provider := &fakeProvider{name: "slow", quotation: validQuotation(), delay: 300 * time.Millisecond}
+	gateway := NewQuotationGateway(provider)
 
-	// Call the function
-	_, err := gateway.GetQuotation()
+	start := time.Now()
+	_, err := gateway.GetQuotation(context.Background(), DefaultPair)
 
-	// Check that we got a timeout error
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "context deadline exceeded")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 300*time.Millisecond)
 }
 
-func TestExternalURLIntegration(t *testing.T) {
-	if testing.Short() {
-		t.Skip("Skipping integration test in short mode")
-	}
+func TestGetQuotationCallerDeadline(t *testing.T) {
+	primary := &fakeProvider{name: "primary", quotation: validQuotation(), delay: 150 * time.Millisecond}
+	secondary := &fakeProvider{name: "secondary", quotation: validQuotation()}
+	gateway := NewQuotationGateway(primary, secondary)
+
+	// The caller's deadline is shorter than the provider timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := gateway.GetQuotation(ctx, DefaultPair)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, 0, secondary.calls, "no fallback once the caller has given up")
+}
+
+func TestGetQuotationCanceled(t *testing.T) {
+	primary := &fakeProvider{name: "primary", quotation: validQuotation(), delay: 150 * time.Millisecond}
+	secondary := &fakeProvider{name: "secondary", quotation: validQuotation()}
+	gateway := NewQuotationGateway(primary, secondary)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, err := gateway.GetQuotation(ctx, DefaultPair)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, primary.calls)
+	assert.Equal(t, 0, secondary.calls)
+}
+
+func TestNewQuotationGatewayDefaults(t *testing.T) {
 	gateway := NewQuotationGateway()
-	quotation, err := gateway.GetQuotation()
 
+	require.Len(t, gateway.Providers, 2)
+	assert.Equal(t, "awesomeapi", gateway.Providers[0].Name())
+	assert.Equal(t, "frankfurter", gateway.Providers[1].Name())
+	assert.Equal(t, DefaultProviderTimeout, gateway.Timeout)
+	assert.Equal(t, DefaultFailureThreshold, gateway.FailureThreshold)
+	assert.Equal(t, DefaultBreakerCooldown, gateway.BreakerCooldown)
+}
+
+func TestGetQuotationCircuitBreaker(t *testing.T) {
+	primary := &fakeProvider{name: "primary", err: errors.New("connection refused")}
+	secondary := &fakeProvider{name: "secondary", quotation: validQuotation()}
+	gateway := NewQuotationGateway(primary, secondary)
+	gateway.FailureThreshold = 2
+	gateway.BreakerCooldown = time.Minute
+
+	for i := 0; i < 4; i++ {
+		quotation, err := gateway.GetQuotation(context.Background(), DefaultPair)
+		require.NoError(t, err)
+		assert.Equal(t, "secondary", quotation.Provider)
+	}
+
+	// After two failures the primary is skipped without being called
+	assert.Equal(t, 2, primary.calls)
+	assert.Equal(t, 4, secondary.calls)
+
+	statuses := gateway.BreakerStatuses()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "primary", statuses[0].Provider)
+	assert.Equal(t, BreakerOpen, statuses[0].State)
+	assert.Equal(t, "secondary", statuses[1].Provider)
+	assert.Equal(t, BreakerClosed, statuses[1].State)
+}
+
+func TestGetQuotationUnsupportedPairDoesNotTripBreaker(t *testing.T) {
+	primary := &fakeProvider{name: "primary", err: errors.New("connection refused")}
+	fallback := &fakeProvider{name: "fallback", err: fmt.Errorf("%w: BTC-BRL", ErrUnsupportedPair)}
+	observer := &recordingObserver{}
+	gateway := NewQuotationGateway(primary, fallback)
+	gateway.FailureThreshold = 2
+	gateway.Observer = observer
+
+	for i := 0; i < 3; i++ {
+		_, err := gateway.GetQuotation(context.Background(), "BTC-BRL")
+		assert.ErrorIs(t, err, ErrAllProvidersFailed)
+		// Every provider failing is an upstream failure, not an invalid pair
+		assert.NotErrorIs(t, err, ErrUnsupportedPair)
+	}
+
+	statuses := gateway.BreakerStatuses()
+	assert.Equal(t, BreakerOpen, statuses[0].State)
+	assert.Equal(t, BreakerClosed, statuses[1].State)
+	assert.Equal(t, 0, statuses[1].ConsecutiveFailures)
+	assert.Equal(t, 3, fallback.calls)
+
+	// The fallback still serves the pairs it publishes
+	fallback.err = nil
+	fallback.quotation = validQuotation()
+	quotation, err := gateway.GetQuotation(context.Background(), DefaultPair)
 	require.NoError(t, err)
-	assert.NotEmpty(t, quotation.Bid)
-	assert.NotEmpty(t, quotation.Code)
-	assert.Equal(t, "USD", quotation.Code)
-	assert.Equal(t, "BRL", quotation.Codein)
+	assert.Equal(t, "fallback", quotation.Provider)
+
+	// Unpublished pairs were not observed as provider calls
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	assert.Equal(t, []string{"primary BTC-BRL other", "primary BTC-BRL other", "fallback USD-BRL "}, observer.fetches)
+}
+
+func TestGetQuotationAllBreakersOpen(t *testing.T) {
+	provider := &fakeProvider{name: "primary", err: errors.New("connection refused")}
+	gateway := NewQuotationGateway(provider)
+	gateway.FailureThreshold = 1
+
+	_, err := gateway.GetQuotation(context.Background(), DefaultPair)
+	assert.ErrorIs(t, err, ErrAllProvidersFailed)
+	assert.NotErrorIs(t, err, ErrCircuitOpen)
+
+	_, err = gateway.GetQuotation(context.Background(), DefaultPair)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 1, provider.calls)
+}
+
+func TestGetQuotationCancelDoesNotTripBreaker(t *testing.T) {
+	provider := &fakeProvider{name: "primary", quotation: validQuotation(), delay: 150 * time.Millisecond}
+	gateway := NewQuotationGateway(provider)
+	gateway.FailureThreshold = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := gateway.GetQuotation(ctx, DefaultPair)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, BreakerClosed, gateway.BreakerStatuses()[0].State)
+}
+
+func TestLastSuccess(t *testing.T) {
+	provider := &fakeProvider{name: "primary", err: errors.New("connection refused")}
+	gateway := NewQuotationGateway(provider)
+
+	// Failures do not count as a successful fetch
+	_, err := gateway.GetQuotation(context.Background(), DefaultPair)
+	require.Error(t, err)
+	assert.True(t, gateway.LastSuccess().IsZero())
+
+	provider.err = nil
+	provider.quotation = validQuotation()
+	before := time.Now()
+
+	quotation, err := gateway.GetQuotation(context.Background(), DefaultPair)
+	require.NoError(t, err)
+	assert.Equal(t, quotation.FetchedAt, gateway.LastSuccess())
+	assert.False(t, gateway.LastSuccess().Before(before.Truncate(time.Second)))
+}
+
+type recordingObserver struct {
+	mu         sync.Mutex
+	fetches    []string
+	quotations []Quotation
+}
+
+func (o *recordingObserver) ObserveQuotation(quotation Quotation) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.quotations = append(o.quotations, quotation)
+}
+
+func (o *recordingObserver) ObserveFetch(provider string, pair Pair, duration time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.fetches = append(o.fetches, provider+" "+pair.String()+" "+ErrorClass(err))
+}
+
+func TestGetQuotationObserver(t *testing.T) {
+	primary := &fakeProvider{name: "primary", quotation: Quotation{Code: "EUR", Codein: "BRL", Bid: decimal.MustParse("6.3894"), Ask: decimal.MustParse("6.3922")}}
+	secondary := &fakeProvider{name: "secondary", quotation: validQuotation()}
+	observer := &recordingObserver{}
+
+	gateway := NewQuotationGateway(primary, secondary)
+	gateway.Observer = observer
+
+	_, err := gateway.GetQuotation(context.Background(), DefaultPair)
+	require.NoError(t, err)
+
+	// Quotations rejected by validation are observed as failures
+	assert.Equal(t, []string{"primary USD-BRL invalid", "secondary USD-BRL "}, observer.fetches)
+	require.Len(t, observer.quotations, 1)
+	assert.Equal(t, "secondary", observer.quotations[0].Provider)
+}
+
+func TestFetchObservers(t *testing.T) {
+	first := &recordingObserver{}
+	second := &recordingObserver{}
+
+	gateway := NewQuotationGateway(&fakeProvider{name: "primary", quotation: validQuotation()})
+	gateway.Observer = FetchObservers{first, second}
+
+	_, err := gateway.GetQuotation(context.Background(), DefaultPair)
+	require.NoError(t, err)
+
+	for _, observer := range []*recordingObserver{first, second} {
+		assert.Equal(t, []string{"primary USD-BRL "}, observer.fetches)
+		assert.Len(t, observer.quotations, 1)
+	}
 }
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/aggregations"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/repositories"
+)
+
+// ErrTooManyQuotations indica um intervalo com mais cotações brutas do que
+// CandlesHandler.MaxRows
+var ErrTooManyQuotations = errors.New("range has too many quotations")
+
+type CandlesRepository interface {
+	HistoryRepository
+	FindRollups(ctx context.Context, pair gateways.Pair, resolution aggregations.Interval, from, to time.Time) ([]aggregations.Rollup, error)
+}
+
+type CandlesResponse struct {
+	Pair     gateways.Pair         `json:"pair"`
+	Interval aggregations.Interval `json:"interval"`
+	From     time.Time             `json:"from"`
+	To       time.Time             `json:"to"`
+	Samples  int                   `json:"samples"`
+	Candles  []aggregations.Candle `json:"candles"`
+}
+
+type CandlesHandler struct {
+	repository CandlesRepository
+	// QueryTimeout limita o tempo total de leitura das cotações do intervalo
+	QueryTimeout time.Duration
+	// MaxRows limita as cotações brutas lidas por consulta; pode ser excedido
+	// em no máximo uma página
+	MaxRows int
+}
+
+func NewCandlesHandler(repository CandlesRepository) *CandlesHandler {
+	return &CandlesHandler{
+		repository:   repository,
+		QueryTimeout: 2 * time.Second,
+		MaxRows:      100 * repositories.MaxHistoryLimit,
+	}
+}
+
+// HandleGetCandles atende GET /cotacao/candles?pair=&interval=&from=&to=
+func (h *CandlesHandler) HandleGetCandles(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	pair := gateways.DefaultPair
+	if value := query.Get("pair"); value != "" {
+		parsed, err := gateways.ParsePair(value)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		pair = parsed
+	}
+
+	interval, err := aggregations.ParseInterval(query.Get("interval"))
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	from, to, err := parseRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	builder, err := aggregations.NewCandleBuilder(interval, from, to)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.QueryTimeout)
+	defer cancel()
+
+	if err := h.aggregateQuotations(ctx, builder, pair, from, to); err != nil {
+		if errors.Is(err, ErrTooManyQuotations) {
+			writeBadRequest(w, err)
+			return
+		}
+		slog.ErrorContext(ctx, "Erro ao buscar cotações para candles", "pair", pair, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrorCodeInternal, err.Error(), true)
+		return
+	}
+	candles := builder.Candles()
+
+	// Cotações além do período de retenção só existem nos resumos
+	if isRollupResolution(interval) {
+		rollups, err := h.repository.FindRollups(ctx, pair, interval, from.Truncate(interval.Duration()), to)
+		if err != nil {
+			slog.ErrorContext(ctx, "Erro ao buscar resumos para candles", "pair", pair, "error", err)
+			writeError(w, http.StatusInternalServerError, ErrorCodeInternal, err.Error(), true)
+			return
+		}
+		candles = aggregations.MergeRollupCandles(candles, rollups)
+	}
+
+	samples := 0
+	for _, candle := range candles {
+		samples += candle.Samples
+	}
+
+	writeJSON(w, CandlesResponse{
+		Pair:     pair,
+		Interval: interval,
+		From:     from,
+		To:       to,
+		Samples:  samples,
+		Candles:  candles,
+	})
+}
+
+// aggregateQuotations percorre as páginas do histórico bruto no intervalo,
+// agregando cada uma antes de ler a próxima, até MaxRows cotações
+func (h *CandlesHandler) aggregateQuotations(ctx context.Context, builder *aggregations.CandleBuilder, pair gateways.Pair, from, to time.Time) error {
+	filter := repositories.HistoryFilter{
+		Pair:    pair,
+		From:    from,
+		To:      to,
+		Limit:   repositories.MaxHistoryLimit,
+		RawOnly: true,
+	}
+
+	rows := 0
+	for {
+		page, err := h.repository.FindHistory(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		builder.Add(page.Quotations...)
+		if page.NextCursor == "" {
+			return nil
+		}
+
+		rows += len(page.Quotations)
+		if h.MaxRows > 0 && rows >= h.MaxRows {
+			return fmt.Errorf("%w: more than %d, use a shorter range or a coarser interval", ErrTooManyQuotations, h.MaxRows)
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+func isRollupResolution(interval aggregations.Interval) bool {
+	for _, resolution := range repositories.RollupResolutions {
+		if interval == resolution {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRange exige from e to, com from anterior a to
+func parseRange(rawFrom, rawTo string) (time.Time, time.Time, error) {
+	if rawFrom == "" || rawTo == "" {
+		return time.Time{}, time.Time{}, errors.New("from and to are required")
+	}
+
+	from, err := parseTimeParam(rawFrom)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+	}
+	to, err := parseTimeParam(rawTo)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+
+	return from.UTC(), to.UTC(), nil
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/aggregations"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockCandlesRepository struct {
+	MockHistoryRepository
+}
+
+func (m *MockCandlesRepository) FindRollups(ctx context.Context, pair gateways.Pair, resolution aggregations.Interval, from, to time.Time) ([]aggregations.Rollup, error) {
+	args := m.Called(ctx, pair, resolution, from, to)
+	return args.Get(0).([]aggregations.Rollup), args.Error(1)
+}
+
+func TestHandleGetCandles(t *testing.T) {
+	from := time.Date(2023, 11, 29, 0, 0, 0, 0, time.UTC)
+	to := from.Add(3 * time.Hour)
+
+	firstPage := repositories.HistoryPage{
+		Quotations: []gateways.Quotation{
+			{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8500"), CreateDate: from.Add(10 * time.Minute)},
+			{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8700"), CreateDate: from.Add(20 * time.Minute)},
+		},
+		NextCursor: "page-2",
+	}
+	secondPage := repositories.HistoryPage{
+		Quotations: []gateways.Quotation{
+			{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8600"), CreateDate: from.Add(2*time.Hour + 5*time.Minute)},
+		},
+	}
+
+	mockRepository := new(MockCandlesRepository)
+	filter := repositories.HistoryFilter{Pair: "USD-BRL", From: from, To: to, Limit: repositories.MaxHistoryLimit, RawOnly: true}
+	mockRepository.On("FindHistory", mock.Anything, filter).Return(firstPage, nil).Once()
+	filter.Cursor = "page-2"
+	mockRepository.On("FindHistory", mock.Anything, filter).Return(secondPage, nil).Once()
+	mockRepository.On("FindRollups", mock.Anything, gateways.Pair("USD-BRL"), aggregations.Hour, from, to).Return([]aggregations.Rollup{}, nil).Once()
+
+	handler := NewCandlesHandler(mockRepository)
+
+	req := httptest.NewRequest(http.MethodGet, "/cotacao/candles?pair=USD-BRL&interval=1h&from=2023-11-29T00:00:00Z&to=2023-11-29T03:00:00Z", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.HandleGetCandles(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	mockRepository.AssertExpectations(t)
+
+	var response CandlesResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, gateways.Pair("USD-BRL"), response.Pair)
+	assert.Equal(t, aggregations.Hour, response.Interval)
+	assert.Equal(t, 3, response.Samples)
+	require.Len(t, response.Candles, 3)
+
+	assert.Equal(t, "5.8500", response.Candles[0].Open.String())
+	assert.Equal(t, "5.8700", response.Candles[0].High.String())
+	assert.Equal(t, "5.8500", response.Candles[0].Low.String())
+	assert.Equal(t, "5.8700", response.Candles[0].Close.String())
+	assert.Equal(t, 2, response.Candles[0].Samples)
+
+	assert.Equal(t, 0, response.Candles[1].Samples)
+	assert.Empty(t, response.Candles[1].Open)
+
+	assert.Equal(t, 1, response.Candles[2].Samples)
+	assert.Equal(t, "5.8600", response.Candles[2].Close.String())
+}
+
+func TestHandleGetCandlesWithRollups(t *testing.T) {
+	from := time.Date(2023, 11, 27, 0, 0, 0, 0, time.UTC)
+	to := from.Add(3 * 24 * time.Hour)
+
+	// The 27th was compacted, the 28th is empty and the 29th is still raw
+	page := repositories.HistoryPage{
+		Quotations: []gateways.Quotation{
+			{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8600"), CreateDate: from.Add(2*24*time.Hour + time.Hour)},
+		},
+	}
+	rollups := []aggregations.Rollup{
+		{Pair: "USD-BRL", Resolution: aggregations.Day, Start: from, Open: decimal.MustParse("5.8000"), High: decimal.MustParse("5.9000"), Low: decimal.MustParse("5.7900"), Close: decimal.MustParse("5.8580"), Samples: 1440},
+	}
+
+	mockRepository := new(MockCandlesRepository)
+	mockRepository.On("FindHistory", mock.Anything, repositories.HistoryFilter{Pair: "USD-BRL", From: from, To: to, Limit: repositories.MaxHistoryLimit, RawOnly: true}).Return(page, nil).Once()
+	mockRepository.On("FindRollups", mock.Anything, gateways.Pair("USD-BRL"), aggregations.Day, from, to).Return(rollups, nil).Once()
+
+	handler := NewCandlesHandler(mockRepository)
+
+	req := httptest.NewRequest(http.MethodGet, "/cotacao/candles?interval=1d&from=2023-11-27&to=2023-11-30", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.HandleGetCandles(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	mockRepository.AssertExpectations(t)
+
+	var response CandlesResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, 1441, response.Samples)
+	require.Len(t, response.Candles, 3)
+
+	assert.Equal(t, "5.8000", response.Candles[0].Open.String())
+	assert.Equal(t, "5.8580", response.Candles[0].Close.String())
+	assert.Equal(t, 1440, response.Candles[0].Samples)
+	assert.Equal(t, 0, response.Candles[1].Samples)
+	assert.Equal(t, "5.8600", response.Candles[2].Close.String())
+}
+
+func TestHandleGetCandlesTooManyQuotations(t *testing.T) {
+	from := time.Date(2023, 11, 29, 0, 0, 0, 0, time.UTC)
+	page := repositories.HistoryPage{
+		Quotations: []gateways.Quotation{
+			{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8500"), CreateDate: from.Add(10 * time.Minute)},
+			{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8700"), CreateDate: from.Add(20 * time.Minute)},
+		},
+		NextCursor: "next",
+	}
+
+	mockRepository := new(MockCandlesRepository)
+	mockRepository.On("FindHistory", mock.Anything, mock.Anything).Return(page, nil)
+
+	handler := NewCandlesHandler(mockRepository)
+	handler.MaxRows = 3
+
+	req := httptest.NewRequest(http.MethodGet, "/cotacao/candles?interval=1d&from=2023-11-29&to=2023-11-30", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.HandleGetCandles(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), ErrTooManyQuotations.Error())
+	// Reading stops at the page that reaches the limit
+	mockRepository.AssertNumberOfCalls(t, "FindHistory", 2)
+	mockRepository.AssertNotCalled(t, "FindRollups", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleGetCandlesErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		repositoryErr  error
+		rollupsErr     error
+		expectedStatus int
+	}{
+		{name: "missing interval", query: "?from=2023-11-29&to=2023-11-30", expectedStatus: http.StatusBadRequest},
+		{name: "invalid interval", query: "?interval=1w&from=2023-11-29&to=2023-11-30", expectedStatus: http.StatusBadRequest},
+		{name: "missing range", query: "?interval=1h", expectedStatus: http.StatusBadRequest},
+		{name: "inverted range", query: "?interval=1h&from=2023-11-30&to=2023-11-29", expectedStatus: http.StatusBadRequest},
+		{name: "unsupported pair", query: "?pair=XYZ-BRL&interval=1h&from=2023-11-29&to=2023-11-30", expectedStatus: http.StatusBadRequest},
+		{name: "too many candles", query: "?interval=1m&from=2023-11-01&to=2023-11-30", expectedStatus: http.StatusBadRequest},
+		{name: "repository error", query: "?interval=1h&from=2023-11-29&to=2023-11-30", repositoryErr: errors.New("database is locked"), expectedStatus: http.StatusInternalServerError},
+		{name: "rollups error", query: "?interval=1d&from=2023-11-29&to=2023-11-30", rollupsErr: errors.New("database is locked"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepository := new(MockCandlesRepository)
+			mockRepository.On("FindHistory", mock.Anything, mock.Anything).Return(repositories.HistoryPage{}, tt.repositoryErr).Maybe()
+			mockRepository.On("FindRollups", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]aggregations.Rollup{}, tt.rollupsErr).Maybe()
+
+			handler := NewCandlesHandler(mockRepository)
+
+			req := httptest.NewRequest(http.MethodGet, "/cotacao/candles"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+
+			handler.HandleGetCandles(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+		})
+	}
+}
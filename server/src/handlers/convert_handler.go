@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/conversions"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+)
+
+type CurrencyConverter interface {
+	Convert(ctx context.Context, from, to string, amount decimal.Decimal, side conversions.Side) (conversions.Conversion, error)
+}
+
+type ConvertHandler struct {
+	converter CurrencyConverter
+}
+
+func NewConvertHandler(converter CurrencyConverter) *ConvertHandler {
+	return &ConvertHandler{converter: converter}
+}
+
+// HandleConvert atende GET /convert?from=USD&to=BRL&amount=123.45&side=bid
+func (h *ConvertHandler) HandleConvert(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from, err := conversions.ParseCurrency(query.Get("from"))
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	to, err := conversions.ParseCurrency(query.Get("to"))
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	amount, err := decimal.Parse(query.Get("amount"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorCodeInvalidRequest, fmt.Sprintf("invalid amount: %v", err), false)
+		return
+	}
+
+	side, err := conversions.ParseSide(query.Get("side"))
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	conversion, err := h.converter.Convert(r.Context(), from, to, amount, side)
+	if err != nil {
+		if r.Context().Err() != nil {
+			slog.InfoContext(r.Context(), "Cliente cancelou a conversão", "from", from, "to", to, "error", err)
+			return
+		}
+		if errors.Is(err, conversions.ErrNoConversionPath) {
+			writeBadRequest(w, err)
+			return
+		}
+		if errors.Is(err, ErrUpstreamUnavailable) {
+			writeError(w, http.StatusBadGateway, ErrorCodeUpstreamUnavailable, err.Error(), true)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Erro ao converter", "from", from, "to", to, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrorCodeInternal, err.Error(), true)
+		return
+	}
+
+	writeJSON(w, conversion)
+}
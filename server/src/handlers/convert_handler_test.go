@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/conversions"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Mock converter
+type MockCurrencyConverter struct {
+	mock.Mock
+}
+
+func (m *MockCurrencyConverter) Convert(ctx context.Context, from, to string, amount decimal.Decimal, side conversions.Side) (conversions.Conversion, error) {
+	args := m.Called(ctx, from, to, amount, side)
+	return args.Get(0).(conversions.Conversion), args.Error(1)
+}
+
+func TestHandleConvert(t *testing.T) {
+	timestamp := time.Date(2023, 11, 29, 17, 55, 42, 0, time.UTC)
+	conversion := conversions.Conversion{
+		From:      "USD",
+		To:        "BRL",
+		Side:      conversions.Ask,
+		Amount:    decimal.MustParse("123.45"),
+		Rate:      decimal.MustParse("5.8582"),
+		Converted: decimal.MustParse("723.194790"),
+		Timestamp: timestamp,
+		Legs:      []conversions.Leg{{Pair: "USD-BRL", Price: decimal.MustParse("5.8582"), Timestamp: timestamp}},
+	}
+
+	mockConverter := new(MockCurrencyConverter)
+	mockConverter.On("Convert", mock.Anything, "USD", "BRL", decimal.MustParse("123.45"), conversions.Ask).Return(conversion, nil)
+
+	handler := NewConvertHandler(mockConverter)
+
+	req := httptest.NewRequest(http.MethodGet, "/convert?from=usd&to=BRL&amount=123.45&side=ask", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.HandleConvert(recorder, req)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	mockConverter.AssertExpectations(t)
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "5.8582", response["rate"])
+	assert.Equal(t, "723.194790", response["converted"])
+	assert.Equal(t, "123.45", response["amount"])
+	assert.Equal(t, "2023-11-29T17:55:42Z", response["timestamp"])
+}
+
+func TestHandleConvertErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		converterErr   error
+		expectedStatus int
+	}{
+		{name: "missing from", query: "?to=BRL&amount=1", expectedStatus: http.StatusBadRequest},
+		{name: "invalid to", query: "?from=USD&to=REAL&amount=1", expectedStatus: http.StatusBadRequest},
+		{name: "missing amount", query: "?from=USD&to=BRL", expectedStatus: http.StatusBadRequest},
+		{name: "malformed amount", query: "?from=USD&to=BRL&amount=1e3", expectedStatus: http.StatusBadRequest},
+		{name: "invalid side", query: "?from=USD&to=BRL&amount=1&side=mid", expectedStatus: http.StatusBadRequest},
+		{name: "no conversion path", query: "?from=JPY&to=BRL&amount=1", converterErr: fmt.Errorf("%w from JPY to BRL", conversions.ErrNoConversionPath), expectedStatus: http.StatusBadRequest},
+		{name: "quotation unavailable", query: "?from=USD&to=BRL&amount=1", converterErr: errors.New("all quotation providers failed"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockConverter := new(MockCurrencyConverter)
+			mockConverter.On("Convert", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(conversions.Conversion{}, tt.converterErr).Maybe()
+
+			handler := NewConvertHandler(mockConverter)
+
+			req := httptest.NewRequest(http.MethodGet, "/convert"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+
+			handler.HandleConvert(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Interfaces para dependências
+type DatabasePinger interface {
+	PingContext(ctx context.Context) error
+}
+
+type MigrationReporter interface {
+	Pending(ctx context.Context) ([]int, error)
+}
+
+type UpstreamReporter interface {
+	LastSuccess() time.Time
+}
+
+// Situação de uma verificação e do serviço como um todo
+const (
+	CheckOK     = "ok"
+	CheckFailed = "fail"
+)
+
+// HealthCheck é o resultado da verificação de uma dependência
+type HealthCheck struct {
+	Name      string         `json:"name"`
+	Status    string         `json:"status"`
+	LatencyMs float64        `json:"latency_ms"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+type HealthResponse struct {
+	Version int           `json:"version"`
+	Status  string        `json:"status"`
+	Checks  []HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthHandler responde às sondas do orquestrador: /healthz indica apenas que
+// o processo atende requisições e /readyz que o banco responde, as migrações
+// estão aplicadas e algum provedor respondeu há no máximo MaxUpstreamAge.
+// Depois de Drain, /readyz responde 503 para que o balanceador pare de enviar
+// tráfego enquanto as requisições em andamento terminam.
+type HealthHandler struct {
+	db         DatabasePinger
+	migrations MigrationReporter
+	upstream   UpstreamReporter
+	// MaxUpstreamAge é o tempo máximo desde a última cotação obtida de um
+	// provedor; zero desativa essa condição
+	MaxUpstreamAge time.Duration
+	// Polling indica que o poller busca cotações em segundo plano. Sem ele, só
+	// as requisições acionam os provedores, então nenhuma cotação obtida ainda
+	// não impede a prontidão.
+	Polling bool
+	// CheckTimeout limita cada verificação
+	CheckTimeout time.Duration
+	now          func() time.Time
+	draining     atomic.Bool
+}
+
+func NewHealthHandler(db DatabasePinger, migrations MigrationReporter, upstream UpstreamReporter) *HealthHandler {
+	return &HealthHandler{
+		db:             db,
+		migrations:     migrations,
+		upstream:       upstream,
+		MaxUpstreamAge: 2 * time.Minute,
+		Polling:        true,
+		CheckTimeout:   time.Second,
+		now:            time.Now,
+	}
+}
+
+// HandleGetHealthz atende GET /healthz; não consulta nenhuma dependência
+func (h *HealthHandler) HandleGetHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, HealthResponse{Version: ResponseVersion, Status: CheckOK})
+}
+
+// HandleGetReadyz atende GET /readyz com 200 quando todas as verificações
+// passam e 503 caso contrário
+func (h *HealthHandler) HandleGetReadyz(w http.ResponseWriter, r *http.Request) {
+	status, checks := h.Check(r.Context())
+
+	code := http.StatusOK
+	if status != CheckOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSONStatus(w, code, HealthResponse{Version: ResponseVersion, Status: status, Checks: checks})
+}
+
+// Drain marca o serviço como em desligamento; as verificações seguintes falham
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Check executa as verificações em paralelo e devolve a situação geral, que só
+// é CheckOK quando todas passam
+func (h *HealthHandler) Check(ctx context.Context) (string, []HealthCheck) {
+	probes := []struct {
+		name string
+		run  func(ctx context.Context) (map[string]any, error)
+	}{
+		{name: "database", run: h.checkDatabase},
+		{name: "migrations", run: h.checkMigrations},
+		{name: "upstream", run: h.checkUpstream},
+	}
+
+	checks := make([]HealthCheck, len(probes))
+	var wg sync.WaitGroup
+
+	for i, probe := range probes {
+		wg.Add(1)
+		go func(i int, name string, run func(ctx context.Context) (map[string]any, error)) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, h.CheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			details, err := run(ctx)
+
+			check := HealthCheck{
+				Name:      name,
+				Status:    CheckOK,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Details:   details,
+			}
+			if err != nil {
+				check.Status = CheckFailed
+				check.Error = err.Error()
+			}
+			checks[i] = check
+		}(i, probe.name, probe.run)
+	}
+
+	wg.Wait()
+
+	if h.draining.Load() {
+		checks = append(checks, HealthCheck{Name: "shutdown", Status: CheckFailed, Error: "server is shutting down"})
+	}
+
+	status := CheckOK
+	for _, check := range checks {
+		if check.Status != CheckOK {
+			status = CheckFailed
+		}
+	}
+
+	return status, checks
+}
+
+func (h *HealthHandler) checkDatabase(ctx context.Context) (map[string]any, error) {
+	return nil, h.db.PingContext(ctx)
+}
+
+func (h *HealthHandler) checkMigrations(ctx context.Context) (map[string]any, error) {
+	pending, err := h.migrations.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]any{"pending": pending}
+	if len(pending) > 0 {
+		return details, fmt.Errorf("%d pending migration(s)", len(pending))
+	}
+	return details, nil
+}
+
+func (h *HealthHandler) checkUpstream(ctx context.Context) (map[string]any, error) {
+	details := map[string]any{"max_age_seconds": h.MaxUpstreamAge.Seconds()}
+
+	lastSuccess := h.upstream.LastSuccess()
+	if lastSuccess.IsZero() {
+		if h.MaxUpstreamAge > 0 && h.Polling {
+			return details, fmt.Errorf("no successful upstream fetch yet")
+		}
+		return details, nil
+	}
+
+	age := h.now().Sub(lastSuccess)
+	details["last_success"] = lastSuccess.UTC()
+	details["age_seconds"] = age.Truncate(time.Second).Seconds()
+
+	if h.MaxUpstreamAge > 0 && age > h.MaxUpstreamAge {
+		return details, fmt.Errorf("last successful upstream fetch was %s ago", age.Truncate(time.Second))
+	}
+	return details, nil
+}
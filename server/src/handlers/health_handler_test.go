@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Mock database pinger
+type MockDatabasePinger struct {
+	mock.Mock
+}
+
+func (m *MockDatabasePinger) PingContext(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+// Mock migration reporter
+type MockMigrationReporter struct {
+	mock.Mock
+}
+
+func (m *MockMigrationReporter) Pending(ctx context.Context) ([]int, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]int), args.Error(1)
+}
+
+// Mock upstream reporter
+type MockUpstreamReporter struct {
+	mock.Mock
+}
+
+func (m *MockUpstreamReporter) LastSuccess() time.Time {
+	args := m.Called()
+	return args.Get(0).(time.Time)
+}
+
+func TestHandleGetHealthz(t *testing.T) {
+	// No dependency is consulted
+	handler := NewHealthHandler(new(MockDatabasePinger), new(MockMigrationReporter), new(MockUpstreamReporter))
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.HandleGetHealthz(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"version": 1, "status": "ok"}`, recorder.Body.String())
+}
+
+func TestHandleGetReadyz(t *testing.T) {
+	now := time.Date(2023, 11, 29, 17, 55, 42, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		pingErr        error
+		pending        []int
+		pendingErr     error
+		lastSuccess    time.Time
+		maxUpstreamAge time.Duration
+		pollingOff     bool
+		expectedStatus int
+		expectedFailed []string
+	}{
+		{
+			name:           "ready",
+			pending:        []int{},
+			lastSuccess:    now.Add(-30 * time.Second),
+			maxUpstreamAge: time.Minute,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "database down",
+			pingErr:        errors.New("database is locked"),
+			pending:        []int{},
+			lastSuccess:    now,
+			maxUpstreamAge: time.Minute,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedFailed: []string{"database"},
+		},
+		{
+			name:           "pending migrations",
+			pending:        []int{5},
+			lastSuccess:    now,
+			maxUpstreamAge: time.Minute,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedFailed: []string{"migrations"},
+		},
+		{
+			name:           "unreadable migrations",
+			pending:        []int{},
+			pendingErr:     errors.New("no such table: schema_migrations"),
+			lastSuccess:    now,
+			maxUpstreamAge: time.Minute,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedFailed: []string{"migrations"},
+		},
+		{
+			name:           "stale upstream",
+			pending:        []int{},
+			lastSuccess:    now.Add(-2 * time.Minute),
+			maxUpstreamAge: time.Minute,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedFailed: []string{"upstream"},
+		},
+		{
+			name:           "no upstream fetch yet",
+			pending:        []int{},
+			maxUpstreamAge: time.Minute,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedFailed: []string{"upstream"},
+		},
+		{
+			name:           "no upstream fetch yet without polling",
+			pending:        []int{},
+			maxUpstreamAge: time.Minute,
+			pollingOff:     true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "stale upstream without polling",
+			pending:        []int{},
+			lastSuccess:    now.Add(-2 * time.Minute),
+			maxUpstreamAge: time.Minute,
+			pollingOff:     true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedFailed: []string{"upstream"},
+		},
+		{
+			name:           "upstream check disabled",
+			pending:        []int{},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDatabase := new(MockDatabasePinger)
+			mockDatabase.On("PingContext", mock.Anything).Return(tt.pingErr)
+			mockMigrations := new(MockMigrationReporter)
+			mockMigrations.On("Pending", mock.Anything).Return(tt.pending, tt.pendingErr)
+			mockUpstream := new(MockUpstreamReporter)
+			mockUpstream.On("LastSuccess").Return(tt.lastSuccess)
+
+			handler := NewHealthHandler(mockDatabase, mockMigrations, mockUpstream)
+			handler.MaxUpstreamAge = tt.maxUpstreamAge
+			handler.Polling = !tt.pollingOff
+			handler.now = func() time.Time { return now }
+
+			req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			recorder := httptest.NewRecorder()
+
+			handler.HandleGetReadyz(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+
+			var response HealthResponse
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			require.Len(t, response.Checks, 3)
+
+			var failed []string
+			for _, check := range response.Checks {
+				if check.Status != CheckOK {
+					failed = append(failed, check.Name)
+					assert.NotEmpty(t, check.Error)
+				}
+			}
+			assert.Equal(t, tt.expectedFailed, failed)
+
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, CheckOK, response.Status)
+			} else {
+				assert.Equal(t, CheckFailed, response.Status)
+			}
+		})
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	mockDatabase := new(MockDatabasePinger)
+	mockDatabase.On("PingContext", mock.Anything).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(context.DeadlineExceeded)
+	mockMigrations := new(MockMigrationReporter)
+	mockMigrations.On("Pending", mock.Anything).Return([]int{}, nil)
+	mockUpstream := new(MockUpstreamReporter)
+	mockUpstream.On("LastSuccess").Return(time.Now())
+
+	handler := NewHealthHandler(mockDatabase, mockMigrations, mockUpstream)
+	handler.CheckTimeout = 20 * time.Millisecond
+
+	status, checks := handler.Check(context.Background())
+
+	// A hanging dependency fails its own check instead of blocking the probe
+	assert.Equal(t, CheckFailed, status)
+	assert.Equal(t, "database", checks[0].Name)
+	assert.Equal(t, CheckFailed, checks[0].Status)
+	assert.GreaterOrEqual(t, checks[0].LatencyMs, float64(20))
+	assert.Equal(t, CheckOK, checks[1].Status)
+	assert.Equal(t, CheckOK, checks[2].Status)
+}
+
+func TestHandleGetReadyzWhileDraining(t *testing.T) {
+	mockDatabase := new(MockDatabasePinger)
+	mockDatabase.On("PingContext", mock.Anything).Return(nil)
+	mockMigrations := new(MockMigrationReporter)
+	mockMigrations.On("Pending", mock.Anything).Return([]int{}, nil)
+	mockUpstream := new(MockUpstreamReporter)
+	mockUpstream.On("LastSuccess").Return(time.Now())
+
+	handler := NewHealthHandler(mockDatabase, mockMigrations, mockUpstream)
+	handler.Drain()
+
+	recorder := httptest.NewRecorder()
+	handler.HandleGetReadyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	// Every dependency is fine, but a draining server must leave the load balancer
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	var response HealthResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, CheckFailed, response.Status)
+	require.Len(t, response.Checks, 4)
+	assert.Equal(t, "shutdown", response.Checks[3].Name)
+	assert.Equal(t, CheckFailed, response.Checks[3].Status)
+
+	// Liveness is unaffected
+	recorder = httptest.NewRecorder()
+	handler.HandleGetHealthz(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/repositories"
+)
+
+type HistoryRepository interface {
+	FindHistory(ctx context.Context, filter repositories.HistoryFilter) (repositories.HistoryPage, error)
+}
+
+type HistoryResponse struct {
+	Pair       gateways.Pair        `json:"pair"`
+	Quotations []gateways.Quotation `json:"quotations"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+type HistoryHandler struct {
+	repository HistoryRepository
+	// QueryTimeout limita o tempo de cada consulta ao banco
+	QueryTimeout time.Duration
+}
+
+func NewHistoryHandler(repository HistoryRepository) *HistoryHandler {
+	return &HistoryHandler{
+		repository:   repository,
+		QueryTimeout: 500 * time.Millisecond,
+	}
+}
+
+// HandleGetHistory atende GET /cotacao/history?pair=&from=&to=&limit=&cursor=
+func (h *HistoryHandler) HandleGetHistory(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		slog.InfoContext(r.Context(), "Parâmetros de histórico inválidos", "error", err)
+		writeBadRequest(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.QueryTimeout)
+	defer cancel()
+
+	page, err := h.repository.FindHistory(ctx, filter)
+	if err != nil {
+		if errors.Is(err, repositories.ErrInvalidCursor) {
+			writeBadRequest(w, err)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Erro ao buscar histórico de cotações", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrorCodeInternal, err.Error(), true)
+		return
+	}
+
+	writeJSON(w, HistoryResponse{
+		Pair:       filter.Pair,
+		Quotations: page.Quotations,
+		NextCursor: page.NextCursor,
+	})
+}
+
+func parseHistoryFilter(r *http.Request) (repositories.HistoryFilter, error) {
+	query := r.URL.Query()
+	filter := repositories.HistoryFilter{
+		Pair:   gateways.DefaultPair,
+		Cursor: query.Get("cursor"),
+	}
+
+	if value := query.Get("pair"); value != "" {
+		pair, err := gateways.ParsePair(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.Pair = pair
+	}
+
+	from, err := parseTimeParam(query.Get("from"))
+	if err != nil {
+		return filter, fmt.Errorf("invalid from: %w", err)
+	}
+	to, err := parseTimeParam(query.Get("to"))
+	if err != nil {
+		return filter, fmt.Errorf("invalid to: %w", err)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return filter, errors.New("from must be before to")
+	}
+	filter.From, filter.To = from, to
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > repositories.MaxHistoryLimit {
+			return filter, fmt.Errorf("invalid limit: must be between 1 and %d", repositories.MaxHistoryLimit)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam aceita datas RFC 3339 (2023-11-29T17:55:42Z) ou apenas o dia (2023-11-29)
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Mock history repository
+type MockHistoryRepository struct {
+	mock.Mock
+}
+
+func (m *MockHistoryRepository) FindHistory(ctx context.Context, filter repositories.HistoryFilter) (repositories.HistoryPage, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(repositories.HistoryPage), args.Error(1)
+}
+
+func TestHandleGetHistory(t *testing.T) {
+	createDate := time.Date(2023, 11, 29, 17, 55, 42, 0, time.UTC)
+	page := repositories.HistoryPage{
+		Quotations: []gateways.Quotation{
+			{Code: "EUR", Codein: "BRL", High: decimal.MustParse("6.4102"), Low: decimal.MustParse("6.3511"), PctChange: decimal.MustParse("0.33"), Bid: decimal.MustParse("6.3894"), Ask: decimal.MustParse("6.3922"), CreateDate: createDate},
+		},
+		NextCursor: "next",
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedFilter *repositories.HistoryFilter
+		repositoryErr  error
+		expectedStatus int
+	}{
+		{
+			name:  "all parameters",
+			query: "?pair=eur-brl&from=2023-11-29&to=2023-11-30T00:00:00Z&limit=10&cursor=abc",
+			expectedFilter: &repositories.HistoryFilter{
+				Pair:   "EUR-BRL",
+				From:   time.Date(2023, 11, 29, 0, 0, 0, 0, time.UTC),
+				To:     time.Date(2023, 11, 30, 0, 0, 0, 0, time.UTC),
+				Limit:  10,
+				Cursor: "abc",
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "defaults",
+			query:          "",
+			expectedFilter: &repositories.HistoryFilter{Pair: gateways.DefaultPair},
+			expectedStatus: http.StatusOK,
+		},
+		{name: "unsupported pair", query: "?pair=XYZ-BRL", expectedStatus: http.StatusBadRequest},
+		{name: "invalid from", query: "?from=yesterday", expectedStatus: http.StatusBadRequest},
+		{name: "from after to", query: "?from=2023-11-30&to=2023-11-29", expectedStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=0", expectedStatus: http.StatusBadRequest},
+		{name: "limit above maximum", query: "?limit=5000", expectedStatus: http.StatusBadRequest},
+		{
+			name:           "invalid cursor",
+			query:          "?cursor=bad",
+			expectedFilter: &repositories.HistoryFilter{Pair: gateways.DefaultPair, Cursor: "bad"},
+			repositoryErr:  repositories.ErrInvalidCursor,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "repository error",
+			query:          "",
+			expectedFilter: &repositories.HistoryFilter{Pair: gateways.DefaultPair},
+			repositoryErr:  errors.New("database is locked"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepository := new(MockHistoryRepository)
+			if tt.expectedFilter != nil {
+				mockRepository.On("FindHistory", mock.Anything, *tt.expectedFilter).Return(page, tt.repositoryErr)
+			}
+
+			handler := NewHistoryHandler(mockRepository)
+
+			req := httptest.NewRequest(http.MethodGet, "/cotacao/history"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+
+			handler.HandleGetHistory(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			mockRepository.AssertExpectations(t)
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response HistoryResponse
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedFilter.Pair, response.Pair)
+			assert.Equal(t, "next", response.NextCursor)
+			require.Len(t, response.Quotations, 1)
+			assert.Equal(t, "6.3894", response.Quotations[0].Bid.String())
+			assert.Equal(t, "0.33", response.Quotations[0].PctChange.String())
+			assert.Equal(t, createDate, response.Quotations[0].CreateDate)
+		})
+	}
+}
@@ -3,59 +3,280 @@ package handlers
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/coalescing"
 	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
 )
 
+var (
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrPersistenceFailed   = errors.New("persistence failed")
+)
+
+// DefaultDBTimeout é o prazo padrão de cada acesso ao banco feito por /cotacao
+const DefaultDBTimeout = 10 * time.Millisecond
+
 // Interfaces para dependências
 type QuotationGateway interface {
-	GetQuotation() (gateways.Quotation, error)
+	GetQuotation(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error)
+}
+
+// PersistenceQueue grava as cotações em segundo plano
+type PersistenceQueue interface {
+	Enqueue(ctx context.Context, quotation gateways.Quotation) error
 }
 
 type QuotationRepository interface {
 	Create(quotation gateways.Quotation) error
 	CreateWithContext(ctx context.Context, quotation gateways.Quotation) error
+	FindLatest(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error)
 }
 
 type QuotationHandler struct {
 	gateway    QuotationGateway
 	repository QuotationRepository
+	// MaxAge é a idade máxima de uma cotação armazenada para ser servida sem
+	// consultar o provedor. Zero desativa a leitura do banco.
+	MaxAge time.Duration
+	// CacheTTL é por quanto tempo uma cotação obtida do provedor é servida da
+	// memória, sem consultar banco nem provedor. Zero desativa o cache.
+	CacheTTL time.Duration
+	// DBTimeout limita cada leitura do banco e cada gravação ou enfileiramento
+	// de uma cotação obtida do provedor
+	DBTimeout time.Duration
+	// Queue, quando definida, recebe as cotações obtidas do provedor para
+	// gravação em segundo plano: a resposta não espera o banco e falhas de
+	// persistência são apenas registradas. Sem ela a gravação é síncrona.
+	Queue PersistenceQueue
+
+	// Requisições simultâneas do mesmo par compartilham uma única busca no
+	// provedor e uma única linha persistida
+	flights coalescing.Group[ServedQuotation]
+
+	cacheMu sync.Mutex
+	cache   map[gateways.Pair]cachedQuotation
+}
+
+type cachedQuotation struct {
+	served    ServedQuotation
+	expiresAt time.Time
 }
 
 func NewQuotationHandler(gateway QuotationGateway, repository QuotationRepository) *QuotationHandler {
 	return &QuotationHandler{
 		gateway:    gateway,
 		repository: repository,
+		DBTimeout:  DefaultDBTimeout,
 	}
 }
 
 func (h *QuotationHandler) HandleGetQuotation(w http.ResponseWriter, r *http.Request) {
-	quotation, err := h.gateway.GetQuotation()
+	pair := gateways.DefaultPair
+	if value := r.PathValue("pair"); value != "" {
+		parsed, err := gateways.ParsePair(value)
+		if err != nil {
+			slog.InfoContext(r.Context(), "Par de moedas inválido", "error", err)
+			writeError(w, http.StatusBadRequest, ErrorCodeUnsupportedPair, err.Error(), false)
+			return
+		}
+		pair = parsed
+	}
+
+	served, err := h.latest(r.Context(), pair)
 	if err != nil {
-		log.Printf("Erro ao obter cotação da API: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if r.Context().Err() != nil {
+			slog.InfoContext(r.Context(), "Cliente cancelou a requisição", "pair", pair, "error", err)
+			return
+		}
+
+		switch {
+		case errors.Is(err, ErrUpstreamUnavailable):
+			writeError(w, http.StatusBadGateway, ErrorCodeUpstreamUnavailable, err.Error(), true)
+		case errors.Is(err, ErrPersistenceFailed):
+			writeError(w, http.StatusInternalServerError, ErrorCodePersistenceFailed, err.Error(), true)
+		default:
+			writeError(w, http.StatusInternalServerError, ErrorCodeInternal, err.Error(), true)
+		}
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(time.Millisecond*10))
+	writeQuotation(w, served)
+}
+
+// GetServedQuotation devolve a cotação do par exatamente como GET /cotacao a
+// serviria, com sua origem (cache, banco ou provedor)
+func (h *QuotationHandler) GetServedQuotation(ctx context.Context, pair gateways.Pair) (ServedQuotation, error) {
+	return h.latest(ctx, pair)
+}
+
+// GetLatestQuotation devolve a cotação armazenada do par se ela ainda estiver
+// dentro de MaxAge; caso contrário consulta os provedores e persiste o resultado.
+// Os prazos do provedor e do banco são derivados de ctx.
+func (h *QuotationHandler) GetLatestQuotation(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error) {
+	served, err := h.latest(ctx, pair)
+	return served.Quotation, err
+}
+
+// ServedQuotation é a cotação junto com a informação de onde ela veio
+type ServedQuotation struct {
+	gateways.Quotation
+	Source string
+	// Stale indica uma cotação armazenada servida além de MaxAge porque o
+	// circuit breaker dos provedores está aberto
+	Stale bool
+}
+
+// Response monta o corpo JSON de GET /cotacao
+func (s ServedQuotation) Response() QuotationResponse {
+	response := NewQuotationResponse(s.Quotation, s.Source)
+	response.Stale = s.Stale
+	return response
+}
+
+// latest implementa GetLatestQuotation informando também a origem da cotação
+func (h *QuotationHandler) latest(ctx context.Context, pair gateways.Pair) (ServedQuotation, error) {
+	if served, ok := h.cached(pair); ok {
+		return served, nil
+	}
+
+	if quotation, ok := h.findFresh(ctx, pair); ok {
+		return ServedQuotation{Quotation: quotation, Source: SourceStored}, nil
+	}
+
+	served, shared, err := h.flights.Do(ctx, pair.String(), func(ctx context.Context) (ServedQuotation, error) {
+		return h.fetch(ctx, pair)
+	})
+	if shared && err == nil {
+		slog.DebugContext(ctx, "Busca compartilhada entre requisições simultâneas", "pair", pair)
+	}
+	return served, err
+}
+
+// fetch consulta os provedores e persiste a cotação obtida. É executado uma
+// única vez por par entre requisições simultâneas.
+func (h *QuotationHandler) fetch(ctx context.Context, pair gateways.Pair) (ServedQuotation, error) {
+	quotation, err := h.gateway.GetQuotation(ctx, pair)
+	if err != nil {
+		slog.ErrorContext(ctx, "Erro ao obter cotação dos provedores", "pair", pair, "error", err)
+
+		if errors.Is(err, gateways.ErrCircuitOpen) {
+			if stored, ok := h.findStored(ctx, pair); ok {
+				slog.WarnContext(ctx, "Circuit breaker aberto, servindo cotação armazenada", "pair", pair)
+				return ServedQuotation{Quotation: stored, Source: SourceStored, Stale: true}, nil
+			}
+		}
+
+		return ServedQuotation{}, fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+	}
+
+	dbCtx, cancel := context.WithTimeout(ctx, h.dbTimeout())
 	defer cancel()
 
-	err = h.repository.CreateWithContext(ctx, quotation)
+	if h.Queue != nil {
+		if err := h.Queue.Enqueue(dbCtx, quotation); err != nil {
+			slog.WarnContext(ctx, "Cotação não enfileirada para persistência", "pair", pair, "error", err)
+		}
+		served := ServedQuotation{Quotation: quotation, Source: SourceLive}
+		h.store(pair, served)
+		return served, nil
+	}
+
+	err = h.repository.CreateWithContext(dbCtx, quotation)
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
-			log.Printf("Tempo excedido ao persistir cotação no banco de dados: %v", err)
+			slog.ErrorContext(ctx, "Tempo excedido ao persistir cotação no banco de dados", "pair", pair, "error", err)
 		} else {
-			log.Printf("Erro ao persistir cotação no banco de dados: %v", err)
+			slog.ErrorContext(ctx, "Erro ao persistir cotação no banco de dados", "pair", pair, "error", err)
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return ServedQuotation{}, fmt.Errorf("%w: %w", ErrPersistenceFailed, err)
+	}
+
+	served := ServedQuotation{Quotation: quotation, Source: SourceLive}
+	h.store(pair, served)
+	return served, nil
+}
+
+// cached devolve a cotação guardada em memória enquanto ela estiver dentro de CacheTTL
+func (h *QuotationHandler) cached(pair gateways.Pair) (ServedQuotation, bool) {
+	if h.CacheTTL <= 0 {
+		return ServedQuotation{}, false
+	}
+
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+
+	entry, ok := h.cache[pair]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return ServedQuotation{}, false
+	}
+
+	served := entry.served
+	served.Source = SourceCached
+	return served, true
+}
+
+func (h *QuotationHandler) store(pair gateways.Pair, served ServedQuotation) {
+	if h.CacheTTL <= 0 {
 		return
 	}
 
-	bid := quotation.Bid
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+
+	if h.cache == nil {
+		h.cache = make(map[gateways.Pair]cachedQuotation)
+	}
+	h.cache[pair] = cachedQuotation{served: served, expiresAt: time.Now().Add(h.CacheTTL)}
+}
+
+// findFresh busca a última cotação armazenada do par, desde que não seja mais
+// antiga que MaxAge
+func (h *QuotationHandler) findFresh(ctx context.Context, pair gateways.Pair) (gateways.Quotation, bool) {
+	if h.MaxAge <= 0 {
+		return gateways.Quotation{}, false
+	}
+
+	quotation, ok := h.findStored(ctx, pair)
+	if !ok || time.Since(quotation.FetchedAt) > h.MaxAge {
+		return gateways.Quotation{}, false
+	}
+
+	return quotation, true
+}
+
+func (h *QuotationHandler) dbTimeout() time.Duration {
+	if h.DBTimeout <= 0 {
+		return DefaultDBTimeout
+	}
+	return h.DBTimeout
+}
+
+// findStored busca a última cotação armazenada do par, qualquer que seja a idade
+func (h *QuotationHandler) findStored(ctx context.Context, pair gateways.Pair) (gateways.Quotation, bool) {
+	ctx, cancel := context.WithTimeout(ctx, h.dbTimeout())
+	defer cancel()
+
+	quotation, err := h.repository.FindLatest(ctx, pair)
+	if err != nil {
+		slog.WarnContext(ctx, "Cotação armazenada indisponível", "pair", pair, "error", err)
+		return gateways.Quotation{}, false
+	}
+
+	return quotation, true
+}
+
+// writeQuotation escreve a cotação informando a idade dela nos cabeçalhos
+func writeQuotation(w http.ResponseWriter, served ServedQuotation) {
+	if !served.FetchedAt.IsZero() {
+		age := max(time.Since(served.FetchedAt), 0)
+		w.Header().Set("Age", fmt.Sprint(int(age.Seconds())))
+		w.Header().Set("X-Quotation-Fetched-At", served.FetchedAt.UTC().Format(time.RFC3339))
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(bid))
+	writeJSON(w, served.Response())
 }
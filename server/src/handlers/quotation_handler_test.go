@@ -2,14 +2,21 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
 	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // Mock gateway
@@ -17,8 +24,8 @@ type MockQuotationGateway struct {
 	mock.Mock
 }
 
-func (m *MockQuotationGateway) GetQuotation() (gateways.Quotation, error) {
-	args := m.Called()
+func (m *MockQuotationGateway) GetQuotation(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error) {
+	args := m.Called(ctx, pair)
 	return args.Get(0).(gateways.Quotation), args.Error(1)
 }
 
@@ -37,6 +44,39 @@ func (m *MockQuotationsRepository) CreateWithContext(ctx context.Context, quotat
 	return args.Error(0)
 }
 
+func (m *MockQuotationsRepository) FindLatest(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error) {
+	args := m.Called(ctx, pair)
+	return args.Get(0).(gateways.Quotation), args.Error(1)
+}
+
+// Mock persistence queue
+type MockPersistenceQueue struct {
+	mock.Mock
+}
+
+func (m *MockPersistenceQueue) Enqueue(ctx context.Context, quotation gateways.Quotation) error {
+	args := m.Called(ctx, quotation)
+	return args.Error(0)
+}
+
+func usdBrlQuotation() gateways.Quotation {
+	return gateways.Quotation{
+		Code:       "USD",
+		Codein:     "BRL",
+		Name:       "Dólar Americano/Real Brasileiro",
+		High:       decimal.MustParse("5.8688"),
+		Low:        decimal.MustParse("5.8213"),
+		VarBid:     decimal.MustParse("0.0313"),
+		PctChange:  decimal.MustParse("0.54"),
+		Bid:        decimal.MustParse("5.8576"),
+		Ask:        decimal.MustParse("5.8582"),
+		Timestamp:  "1701278942",
+		CreateDate: time.Date(2023, 11, 29, 17, 55, 42, 0, time.UTC),
+		Provider:   "awesomeapi",
+		FetchedAt:  time.Date(2023, 11, 29, 17, 55, 43, 0, time.UTC),
+	}
+}
+
 func TestHandleGetQuotation(t *testing.T) {
 	// Test cases
 	tests := []struct {
@@ -49,53 +89,51 @@ func TestHandleGetQuotation(t *testing.T) {
 		isContextDeadlineEx bool
 	}{
 		{
-			name:             "success",
-			gatewayError:     nil,
-			repositoryError:  nil,
-			expectedStatus:   http.StatusOK,
-			expectedResponse: "5.8576",
-			quotationToReturn: gateways.Quotation{
-				USDBRL: gateways.USDBRL{
-					Bid: "5.8576",
-				},
-			},
+			name:            "success",
+			gatewayError:    nil,
+			repositoryError: nil,
+			expectedStatus:  http.StatusOK,
+			expectedResponse: `{
+				"version": 1,
+				"pair": "USD-BRL",
+				"bid": "5.8576",
+				"ask": "5.8582",
+				"high": "5.8688",
+				"low": "5.8213",
+				"variation": {"absolute": "0.0313", "percent": "0.54"},
+				"provider_timestamp": "2023-11-29T17:29:02Z",
+				"fetched_at": "2023-11-29T17:55:43Z",
+				"source": "live",
+				"provider": "awesomeapi"
+			}`,
+			quotationToReturn:   usdBrlQuotation(),
 			isContextDeadlineEx: false,
 		},
 		{
-			name:             "gateway error",
-			gatewayError:     errors.New("gateway error"),
-			repositoryError:  nil,
-			expectedStatus:   http.StatusInternalServerError,
-			expectedResponse: "gateway error\n",
-			quotationToReturn: gateways.Quotation{
-				USDBRL: gateways.USDBRL{},
-			},
+			name:                "gateway error",
+			gatewayError:        errors.New("gateway error"),
+			repositoryError:     nil,
+			expectedStatus:      http.StatusBadGateway,
+			expectedResponse:    `{"version": 1, "error": {"code": "upstream_unavailable", "message": "upstream unavailable: gateway error", "retryable": true}}`,
+			quotationToReturn:   gateways.Quotation{},
 			isContextDeadlineEx: false,
 		},
 		{
-			name:             "repository error",
-			gatewayError:     nil,
-			repositoryError:  errors.New("repository error"),
-			expectedStatus:   http.StatusInternalServerError,
-			expectedResponse: "repository error\n",
-			quotationToReturn: gateways.Quotation{
-				USDBRL: gateways.USDBRL{
-					Bid: "5.8576",
-				},
-			},
+			name:                "repository error",
+			gatewayError:        nil,
+			repositoryError:     errors.New("repository error"),
+			expectedStatus:      http.StatusInternalServerError,
+			expectedResponse:    `{"version": 1, "error": {"code": "persistence_failed", "message": "persistence failed: repository error", "retryable": true}}`,
+			quotationToReturn:   usdBrlQuotation(),
 			isContextDeadlineEx: false,
 		},
 		{
-			name:             "context deadline exceeded",
-			gatewayError:     nil,
-			repositoryError:  context.DeadlineExceeded,
-			expectedStatus:   http.StatusInternalServerError,
-			expectedResponse: "context deadline exceeded\n",
-			quotationToReturn: gateways.Quotation{
-				USDBRL: gateways.USDBRL{
-					Bid: "5.8576",
-				},
-			},
+			name:                "context deadline exceeded",
+			gatewayError:        nil,
+			repositoryError:     context.DeadlineExceeded,
+			expectedStatus:      http.StatusInternalServerError,
+			expectedResponse:    `{"version": 1, "error": {"code": "persistence_failed", "message": "persistence failed: context deadline exceeded", "retryable": true}}`,
+			quotationToReturn:   usdBrlQuotation(),
 			isContextDeadlineEx: true,
 		},
 	}
@@ -107,7 +145,7 @@ func TestHandleGetQuotation(t *testing.T) {
 			mockRepository := new(MockQuotationsRepository)
 
 			// Setup expectations
-			mockGateway.On("GetQuotation").Return(tt.quotationToReturn, tt.gatewayError)
+			mockGateway.On("GetQuotation", mock.Anything, gateways.DefaultPair).Return(tt.quotationToReturn, tt.gatewayError)
 
 			// We only mock the repository call if the gateway call succeeds
 			if tt.gatewayError == nil {
@@ -126,7 +164,8 @@ func TestHandleGetQuotation(t *testing.T) {
 
 			// Assert the results
 			assert.Equal(t, tt.expectedStatus, recorder.Code)
-			assert.Equal(t, tt.expectedResponse, recorder.Body.String())
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.expectedResponse, recorder.Body.String())
 
 			// Verify the expectations
 			mockGateway.AssertExpectations(t)
@@ -137,6 +176,371 @@ func TestHandleGetQuotation(t *testing.T) {
 	}
 }
 
+// decodeQuotation decodes a successful /cotacao body
+func decodeQuotation(t *testing.T, recorder *httptest.ResponseRecorder) QuotationResponse {
+	t.Helper()
+
+	var response QuotationResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	return response
+}
+
+func TestHandleGetQuotationPair(t *testing.T) {
+	tests := []struct {
+		name             string
+		pathValue        string
+		expectedPair     gateways.Pair
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:             "supported pair",
+			pathValue:        "EUR-BRL",
+			expectedPair:     "EUR-BRL",
+			expectedStatus:   http.StatusOK,
+			expectedResponse: "6.3894",
+		},
+		{
+			name:             "lower case pair",
+			pathValue:        "gbp-brl",
+			expectedPair:     "GBP-BRL",
+			expectedStatus:   http.StatusOK,
+			expectedResponse: "6.3894",
+		},
+		{
+			name:             "unsupported pair",
+			pathValue:        "XYZ-BRL",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"version": 1, "error": {"code": "unsupported_pair", "message": "unsupported currency pair: \"XYZ-BRL\"", "retryable": false}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGateway := new(MockQuotationGateway)
+			mockRepository := new(MockQuotationsRepository)
+
+			if tt.expectedPair != "" {
+				quotation := gateways.Quotation{Code: tt.expectedPair.Code(), Codein: tt.expectedPair.Codein(), Bid: decimal.MustParse("6.3894")}
+				mockGateway.On("GetQuotation", mock.Anything, tt.expectedPair).Return(quotation, nil)
+				mockRepository.On("CreateWithContext", mock.Anything, quotation).Return(nil)
+			}
+
+			handler := NewQuotationHandler(mockGateway, mockRepository)
+
+			req := httptest.NewRequest(http.MethodGet, "/cotacao/"+tt.pathValue, nil)
+			req.SetPathValue("pair", tt.pathValue)
+			recorder := httptest.NewRecorder()
+
+			handler.HandleGetQuotation(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			if tt.expectedStatus == http.StatusOK {
+				response := decodeQuotation(t, recorder)
+				assert.Equal(t, tt.expectedPair, response.Pair)
+				assert.Equal(t, tt.expectedResponse, response.Bid.String())
+			} else {
+				assert.JSONEq(t, tt.expectedResponse, recorder.Body.String())
+			}
+
+			mockGateway.AssertExpectations(t)
+			mockRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleGetQuotationMaxAge(t *testing.T) {
+	tests := []struct {
+		name             string
+		stored           gateways.Quotation
+		storedError      error
+		expectLiveFetch  bool
+		expectedResponse string
+		expectedSource   string
+		expectedAge      string
+	}{
+		{
+			name:             "fresh stored quotation",
+			stored:           gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8500"), FetchedAt: time.Now().Add(-5 * time.Second)},
+			expectLiveFetch:  false,
+			expectedResponse: "5.8500",
+			expectedSource:   SourceStored,
+			expectedAge:      "5",
+		},
+		{
+			name:             "stale stored quotation",
+			stored:           gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8500"), FetchedAt: time.Now().Add(-2 * time.Minute)},
+			expectLiveFetch:  true,
+			expectedResponse: "5.8576",
+			expectedSource:   SourceLive,
+			expectedAge:      "0",
+		},
+		{
+			name:             "no stored quotation",
+			storedError:      errors.New("quotation not found"),
+			expectLiveFetch:  true,
+			expectedResponse: "5.8576",
+			expectedSource:   SourceLive,
+			expectedAge:      "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGateway := new(MockQuotationGateway)
+			mockRepository := new(MockQuotationsRepository)
+
+			mockRepository.On("FindLatest", mock.Anything, gateways.DefaultPair).Return(tt.stored, tt.storedError)
+
+			live := gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576"), FetchedAt: time.Now()}
+			if tt.expectLiveFetch {
+				mockGateway.On("GetQuotation", mock.Anything, gateways.DefaultPair).Return(live, nil)
+				mockRepository.On("CreateWithContext", mock.Anything, live).Return(nil)
+			}
+
+			handler := NewQuotationHandler(mockGateway, mockRepository)
+			handler.MaxAge = time.Minute
+
+			req := httptest.NewRequest(http.MethodGet, "/cotacao", nil)
+			recorder := httptest.NewRecorder()
+
+			handler.HandleGetQuotation(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			response := decodeQuotation(t, recorder)
+			assert.Equal(t, tt.expectedResponse, response.Bid.String())
+			assert.Equal(t, tt.expectedSource, response.Source)
+			assert.Equal(t, tt.expectedAge, recorder.Header().Get("Age"))
+			assert.NotEmpty(t, recorder.Header().Get("X-Quotation-Fetched-At"))
+
+			mockGateway.AssertExpectations(t)
+			mockRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleGetQuotationPropagatesContext(t *testing.T) {
+	type key struct{}
+	fromRequest := mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Value(key{}) == "request"
+	})
+	// The database write gets its own short deadline derived from the request
+	withDeadline := mock.MatchedBy(func(ctx context.Context) bool {
+		deadline, ok := ctx.Deadline()
+		return ctx.Value(key{}) == "request" && ok && time.Until(deadline) <= 10*time.Millisecond
+	})
+
+	quotation := usdBrlQuotation()
+	mockGateway := new(MockQuotationGateway)
+	mockRepository := new(MockQuotationsRepository)
+	mockRepository.On("FindLatest", fromRequest, gateways.DefaultPair).Return(gateways.Quotation{}, errors.New("not found"))
+	mockGateway.On("GetQuotation", fromRequest, gateways.DefaultPair).Return(quotation, nil)
+	mockRepository.On("CreateWithContext", withDeadline, quotation).Return(nil)
+
+	handler := NewQuotationHandler(mockGateway, mockRepository)
+	handler.MaxAge = time.Minute
+
+	req := httptest.NewRequest(http.MethodGet, "/cotacao", nil)
+	req = req.WithContext(context.WithValue(req.Context(), key{}, "request"))
+	recorder := httptest.NewRecorder()
+
+	handler.HandleGetQuotation(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	mockGateway.AssertExpectations(t)
+	mockRepository.AssertExpectations(t)
+}
+
+func TestHandleGetQuotationClientCanceled(t *testing.T) {
+	mockGateway := new(MockQuotationGateway)
+	mockRepository := new(MockQuotationsRepository)
+
+	// The upstream call only returns once its context is cancelled
+	upstreamErr := make(chan error, 1)
+	mockGateway.On("GetQuotation", mock.Anything, gateways.DefaultPair).
+		Run(func(args mock.Arguments) {
+			ctx := args.Get(0).(context.Context)
+			select {
+			case <-ctx.Done():
+				upstreamErr <- ctx.Err()
+			case <-time.After(time.Second):
+				upstreamErr <- nil
+			}
+		}).
+		Return(gateways.Quotation{}, context.Canceled)
+
+	handler := NewQuotationHandler(mockGateway, mockRepository)
+
+	// The client disconnects while the upstream call is in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	req := httptest.NewRequest(http.MethodGet, "/cotacao", nil).WithContext(ctx)
+	recorder := httptest.NewRecorder()
+
+	handler.HandleGetQuotation(recorder, req)
+
+	// The cancellation reaches the gateway, nothing is persisted and nothing is written back
+	assert.ErrorIs(t, <-upstreamErr, context.Canceled)
+	mockRepository.AssertNotCalled(t, "CreateWithContext", mock.Anything, mock.Anything)
+	assert.Empty(t, recorder.Body.String())
+}
+
+func TestHandleGetQuotationCoalescesConcurrentRequests(t *testing.T) {
+	quotation := usdBrlQuotation()
+	quotation.FetchedAt = time.Now().UTC()
+
+	mockGateway := new(MockQuotationGateway)
+	mockRepository := new(MockQuotationsRepository)
+	mockGateway.On("GetQuotation", mock.Anything, gateways.DefaultPair).
+		Run(func(args mock.Arguments) { time.Sleep(50 * time.Millisecond) }).
+		Return(quotation, nil)
+	mockRepository.On("CreateWithContext", mock.Anything, quotation).Return(nil)
+
+	handler := NewQuotationHandler(mockGateway, mockRepository)
+	handler.CacheTTL = 200 * time.Millisecond
+
+	// burst fires n simultaneous requests and returns the source of each response
+	burst := func(n int) []string {
+		var wg sync.WaitGroup
+		sources := make([]string, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				req := httptest.NewRequest(http.MethodGet, "/cotacao", nil)
+				recorder := httptest.NewRecorder()
+				handler.HandleGetQuotation(recorder, req)
+				if assert.Equal(t, http.StatusOK, recorder.Code) {
+					sources[i] = decodeQuotation(t, recorder).Source
+				}
+			}(i)
+		}
+		wg.Wait()
+		return sources
+	}
+
+	// One upstream call and one insert for the whole burst
+	for _, source := range burst(50) {
+		assert.Equal(t, SourceLive, source)
+	}
+	mockGateway.AssertNumberOfCalls(t, "GetQuotation", 1)
+	mockRepository.AssertNumberOfCalls(t, "CreateWithContext", 1)
+
+	// Within the TTL the quotation is served from memory
+	for _, source := range burst(50) {
+		assert.Equal(t, SourceCached, source)
+	}
+	mockGateway.AssertNumberOfCalls(t, "GetQuotation", 1)
+
+	// Once the TTL expires the next window hits upstream exactly once more
+	time.Sleep(250 * time.Millisecond)
+	burst(50)
+	mockGateway.AssertNumberOfCalls(t, "GetQuotation", 2)
+	mockRepository.AssertNumberOfCalls(t, "CreateWithContext", 2)
+}
+
+func TestHandleGetQuotationWithQueue(t *testing.T) {
+	tests := []struct {
+		name       string
+		queueError error
+	}{
+		{name: "enqueued"},
+		{name: "queue full", queueError: errors.New("persistence queue full")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotation := usdBrlQuotation()
+			mockGateway := new(MockQuotationGateway)
+			mockRepository := new(MockQuotationsRepository)
+			mockQueue := new(MockPersistenceQueue)
+			mockGateway.On("GetQuotation", mock.Anything, gateways.DefaultPair).Return(quotation, nil)
+			mockQueue.On("Enqueue", mock.Anything, quotation).Return(tt.queueError)
+
+			handler := NewQuotationHandler(mockGateway, mockRepository)
+			handler.Queue = mockQueue
+
+			req := httptest.NewRequest(http.MethodGet, "/cotacao", nil)
+			recorder := httptest.NewRecorder()
+
+			handler.HandleGetQuotation(recorder, req)
+
+			// Persistence problems never reach the client
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, "5.8576", decodeQuotation(t, recorder).Bid.String())
+
+			mockQueue.AssertExpectations(t)
+			mockRepository.AssertNotCalled(t, "CreateWithContext", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestHandleGetQuotationCircuitOpen(t *testing.T) {
+	breakerErr := fmt.Errorf("%w: awesomeapi: %w", gateways.ErrAllProvidersFailed, gateways.ErrCircuitOpen)
+
+	stored := usdBrlQuotation()
+	stored.FetchedAt = time.Now().UTC().Add(-10 * time.Minute)
+
+	tests := []struct {
+		name           string
+		gatewayError   error
+		stored         gateways.Quotation
+		storedError    error
+		expectedStatus int
+		expectStale    bool
+	}{
+		{
+			name:           "serves stale stored quotation",
+			gatewayError:   breakerErr,
+			stored:         stored,
+			expectedStatus: http.StatusOK,
+			expectStale:    true,
+		},
+		{
+			name:           "nothing stored",
+			gatewayError:   breakerErr,
+			storedError:    sql.ErrNoRows,
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "provider failure with closed breaker",
+			gatewayError:   fmt.Errorf("%w: awesomeapi: timeout", gateways.ErrAllProvidersFailed),
+			expectedStatus: http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGateway := new(MockQuotationGateway)
+			mockRepository := new(MockQuotationsRepository)
+			mockGateway.On("GetQuotation", mock.Anything, gateways.DefaultPair).Return(gateways.Quotation{}, tt.gatewayError)
+			if errors.Is(tt.gatewayError, gateways.ErrCircuitOpen) {
+				mockRepository.On("FindLatest", mock.Anything, gateways.DefaultPair).Return(tt.stored, tt.storedError)
+			}
+
+			// MaxAge is zero, so the stored quotation is only used as a fallback
+			handler := NewQuotationHandler(mockGateway, mockRepository)
+
+			req := httptest.NewRequest(http.MethodGet, "/cotacao", nil)
+			recorder := httptest.NewRecorder()
+
+			handler.HandleGetQuotation(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			if tt.expectStale {
+				response := decodeQuotation(t, recorder)
+				assert.True(t, response.Stale)
+				assert.Equal(t, SourceStored, response.Source)
+				assert.Equal(t, "5.8576", response.Bid.String())
+				assert.Equal(t, "600", recorder.Header().Get("Age"))
+			}
+
+			mockGateway.AssertExpectations(t)
+			mockRepository.AssertExpectations(t)
+		})
+	}
+}
+
 func TestHandleGetQuotationIntegration(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -161,7 +565,9 @@ func TestHandleGetQuotationIntegration(t *testing.T) {
 
 	// Assert the results
 	assert.Equal(t, http.StatusOK, recorder.Code)
-	assert.NotEmpty(t, recorder.Body.String())
+	response := decodeQuotation(t, recorder)
+	assert.Equal(t, gateways.DefaultPair, response.Pair)
+	assert.Positive(t, response.Bid.Sign())
 
 	// Verify the expectations
 	mockRepository.AssertExpectations(t)
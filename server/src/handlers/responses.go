@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+)
+
+// ResponseVersion é a versão do contrato JSON das respostas. Deve ser
+// incrementada em qualquer mudança incompatível nos corpos abaixo.
+const ResponseVersion = 1
+
+// Códigos de erro devolvidos em ErrorDetail.Code
+const (
+	ErrorCodeInvalidRequest      = "invalid_request"
+	ErrorCodeUnsupportedPair     = "unsupported_pair"
+	ErrorCodeUpstreamUnavailable = "upstream_unavailable"
+	ErrorCodePersistenceFailed   = "persistence_failed"
+	ErrorCodeInternal            = "internal_error"
+)
+
+// Origem da cotação servida
+const (
+	SourceLive   = "live"
+	SourceStored = "stored"
+	SourceCached = "cached"
+)
+
+type Variation struct {
+	Absolute decimal.Decimal `json:"absolute"`
+	Percent  decimal.Decimal `json:"percent"`
+}
+
+type QuotationResponse struct {
+	Version           int             `json:"version"`
+	Pair              gateways.Pair   `json:"pair"`
+	Bid               decimal.Decimal `json:"bid"`
+	Ask               decimal.Decimal `json:"ask"`
+	High              decimal.Decimal `json:"high"`
+	Low               decimal.Decimal `json:"low"`
+	Variation         Variation       `json:"variation"`
+	ProviderTimestamp time.Time       `json:"provider_timestamp"`
+	FetchedAt         time.Time       `json:"fetched_at"`
+	Source            string          `json:"source"`
+	Provider          string          `json:"provider,omitempty"`
+	// Stale indica que os provedores estão indisponíveis (circuit breaker
+	// aberto) e a cotação armazenada foi servida mesmo sendo mais antiga que o
+	// permitido
+	Stale bool `json:"stale,omitempty"`
+}
+
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Retryable bool   `json:"retryable"`
+}
+
+type ErrorResponse struct {
+	Version int         `json:"version"`
+	Error   ErrorDetail `json:"error"`
+}
+
+func NewQuotationResponse(quotation gateways.Quotation, source string) QuotationResponse {
+	return QuotationResponse{
+		Version: ResponseVersion,
+		Pair:    quotation.Pair(),
+		Bid:     quotation.Bid,
+		Ask:     quotation.Ask,
+		High:    quotation.High,
+		Low:     quotation.Low,
+		Variation: Variation{
+			Absolute: quotation.VarBid,
+			Percent:  quotation.PctChange,
+		},
+		ProviderTimestamp: providerTimestamp(quotation),
+		FetchedAt:         quotation.FetchedAt.UTC(),
+		Source:            source,
+		Provider:          quotation.Provider,
+	}
+}
+
+// providerTimestamp usa o timestamp Unix informado pelo provedor e, na falta
+// dele, o create_date
+func providerTimestamp(quotation gateways.Quotation) time.Time {
+	seconds, err := strconv.ParseInt(quotation.Timestamp, 10, 64)
+	if err != nil {
+		return quotation.CreateDate.UTC()
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	writeJSONStatus(w, http.StatusOK, body)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		slog.Error("Erro ao serializar resposta", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string, retryable bool) {
+	writeJSONStatus(w, status, ErrorResponse{
+		Version: ResponseVersion,
+		Error: ErrorDetail{
+			Code:      code,
+			Message:   message,
+			Retryable: retryable,
+		},
+	})
+}
+
+// writeBadRequest responde 400, distinguindo pares não suportados dos demais
+// parâmetros inválidos
+func writeBadRequest(w http.ResponseWriter, err error) {
+	code := ErrorCodeInvalidRequest
+	if errors.Is(err, gateways.ErrUnsupportedPair) {
+		code = ErrorCodeUnsupportedPair
+	}
+	writeError(w, http.StatusBadRequest, code, err.Error(), false)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/persistence"
+)
+
+// Interfaces para dependências
+type BreakerReporter interface {
+	BreakerStatuses() []gateways.BreakerStatus
+}
+
+type QueueReporter interface {
+	Stats() persistence.QueueStats
+}
+
+type HealthChecker interface {
+	Check(ctx context.Context) (string, []HealthCheck)
+}
+
+type BreakersResponse struct {
+	Version  int                      `json:"version"`
+	Breakers []gateways.BreakerStatus `json:"breakers"`
+}
+
+type PersistenceResponse struct {
+	Version int                    `json:"version"`
+	Queue   persistence.QueueStats `json:"queue"`
+}
+
+// StatusResponse reúne as verificações de prontidão e os contadores de cada
+// componente em uma única resposta
+type StatusResponse struct {
+	Version       int                      `json:"version"`
+	Status        string                   `json:"status"`
+	StartedAt     time.Time                `json:"started_at"`
+	UptimeSeconds int64                    `json:"uptime_seconds"`
+	Checks        []HealthCheck            `json:"checks"`
+	Breakers      []gateways.BreakerStatus `json:"breakers"`
+	Persistence   persistence.QueueStats   `json:"persistence"`
+}
+
+type StatusHandler struct {
+	breakers BreakerReporter
+	queue    QueueReporter
+	// Health fornece as verificações de dependências de GET /status; sem ele
+	// a resposta não traz verificações
+	Health    HealthChecker
+	startedAt time.Time
+	now       func() time.Time
+}
+
+func NewStatusHandler(breakers BreakerReporter, queue QueueReporter) *StatusHandler {
+	return &StatusHandler{
+		breakers:  breakers,
+		queue:     queue,
+		startedAt: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// HandleGetStatus atende GET /status com as verificações de cada dependência,
+// suas latências e os contadores dos demais componentes. Responde 200 mesmo
+// com verificações falhando; a prontidão é decidida por /readyz.
+func (h *StatusHandler) HandleGetStatus(w http.ResponseWriter, r *http.Request) {
+	status, checks := CheckOK, []HealthCheck{}
+	if h.Health != nil {
+		status, checks = h.Health.Check(r.Context())
+	}
+
+	writeJSON(w, StatusResponse{
+		Version:       ResponseVersion,
+		Status:        status,
+		StartedAt:     h.startedAt.UTC(),
+		UptimeSeconds: int64(h.now().Sub(h.startedAt).Seconds()),
+		Checks:        checks,
+		Breakers:      h.breakers.BreakerStatuses(),
+		Persistence:   h.queue.Stats(),
+	})
+}
+
+// HandleGetBreakers atende GET /status/breakers com o estado do circuit
+// breaker de cada provedor
+func (h *StatusHandler) HandleGetBreakers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, BreakersResponse{
+		Version:  ResponseVersion,
+		Breakers: h.breakers.BreakerStatuses(),
+	})
+}
+
+// HandleGetPersistence atende GET /status/persistence com os contadores da
+// fila de gravação em segundo plano
+func (h *StatusHandler) HandleGetPersistence(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, PersistenceResponse{
+		Version: ResponseVersion,
+		Queue:   h.queue.Stats(),
+	})
+}
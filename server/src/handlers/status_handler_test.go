@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/persistence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Mock breaker reporter
+type MockBreakerReporter struct {
+	mock.Mock
+}
+
+func (m *MockBreakerReporter) BreakerStatuses() []gateways.BreakerStatus {
+	args := m.Called()
+	return args.Get(0).([]gateways.BreakerStatus)
+}
+
+// Mock queue reporter
+type MockQueueReporter struct {
+	mock.Mock
+}
+
+func (m *MockQueueReporter) Stats() persistence.QueueStats {
+	args := m.Called()
+	return args.Get(0).(persistence.QueueStats)
+}
+
+// Mock health checker
+type MockHealthChecker struct {
+	mock.Mock
+}
+
+func (m *MockHealthChecker) Check(ctx context.Context) (string, []HealthCheck) {
+	args := m.Called(ctx)
+	return args.String(0), args.Get(1).([]HealthCheck)
+}
+
+func TestHandleGetBreakers(t *testing.T) {
+	openedAt := time.Date(2023, 11, 29, 17, 55, 42, 0, time.UTC)
+	retryAt := openedAt.Add(30 * time.Second)
+
+	mockReporter := new(MockBreakerReporter)
+	mockReporter.On("BreakerStatuses").Return([]gateways.BreakerStatus{
+		{Provider: "awesomeapi", State: gateways.BreakerOpen, ConsecutiveFailures: 5, FailureThreshold: 5, OpenedAt: &openedAt, RetryAt: &retryAt},
+		{Provider: "frankfurter", State: gateways.BreakerClosed, FailureThreshold: 5},
+	})
+
+	handler := NewStatusHandler(mockReporter, new(MockQueueReporter))
+
+	req := httptest.NewRequest(http.MethodGet, "/status/breakers", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.HandleGetBreakers(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"version": 1,
+		"breakers": [
+			{
+				"provider": "awesomeapi",
+				"state": "open",
+				"consecutive_failures": 5,
+				"failure_threshold": 5,
+				"opened_at": "2023-11-29T17:55:42Z",
+				"retry_at": "2023-11-29T17:56:12Z"
+			},
+			{
+				"provider": "frankfurter",
+				"state": "closed",
+				"consecutive_failures": 0,
+				"failure_threshold": 5
+			}
+		]
+	}`, recorder.Body.String())
+
+	mockReporter.AssertExpectations(t)
+}
+
+func TestHandleGetPersistence(t *testing.T) {
+	mockQueue := new(MockQueueReporter)
+	mockQueue.On("Stats").Return(persistence.QueueStats{Enqueued: 10, Persisted: 6, Duplicates: 1, Dropped: 1, Failed: 1, Pending: 1})
+
+	handler := NewStatusHandler(new(MockBreakerReporter), mockQueue)
+
+	req := httptest.NewRequest(http.MethodGet, "/status/persistence", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.HandleGetPersistence(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{
+		"version": 1,
+		"queue": {"enqueued": 10, "persisted": 6, "duplicates": 1, "dropped": 1, "failed": 1, "pending": 1}
+	}`, recorder.Body.String())
+
+	mockQueue.AssertExpectations(t)
+}
+
+func TestHandleGetStatus(t *testing.T) {
+	startedAt := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+
+	mockBreakers := new(MockBreakerReporter)
+	mockBreakers.On("BreakerStatuses").Return([]gateways.BreakerStatus{
+		{Provider: "awesomeapi", State: gateways.BreakerClosed, FailureThreshold: 5},
+	})
+	mockQueue := new(MockQueueReporter)
+	mockQueue.On("Stats").Return(persistence.QueueStats{Enqueued: 2, Persisted: 2})
+	mockHealth := new(MockHealthChecker)
+	mockHealth.On("Check", mock.Anything).Return(CheckFailed, []HealthCheck{
+		{Name: "database", Status: CheckOK, LatencyMs: 0.25},
+		{Name: "migrations", Status: CheckOK, LatencyMs: 0.5, Details: map[string]any{"pending": []int{}}},
+		{Name: "upstream", Status: CheckFailed, Error: "no successful upstream fetch yet", Details: map[string]any{"max_age_seconds": 120}},
+	})
+
+	handler := NewStatusHandler(mockBreakers, mockQueue)
+	handler.Health = mockHealth
+	handler.startedAt = startedAt
+	handler.now = func() time.Time { return startedAt.Add(90 * time.Second) }
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	recorder := httptest.NewRecorder()
+
+	handler.HandleGetStatus(recorder, req)
+
+	// Failing checks are reported, not turned into an error status
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{
+		"version": 1,
+		"status": "fail",
+		"started_at": "2023-11-29T17:00:00Z",
+		"uptime_seconds": 90,
+		"checks": [
+			{"name": "database", "status": "ok", "latency_ms": 0.25},
+			{"name": "migrations", "status": "ok", "latency_ms": 0.5, "details": {"pending": []}},
+			{"name": "upstream", "status": "fail", "latency_ms": 0, "error": "no successful upstream fetch yet", "details": {"max_age_seconds": 120}}
+		],
+		"breakers": [
+			{"provider": "awesomeapi", "state": "closed", "consecutive_failures": 0, "failure_threshold": 5}
+		],
+		"persistence": {"enqueued": 2, "persisted": 2, "duplicates": 0, "dropped": 0, "failed": 0, "pending": 0}
+	}`, recorder.Body.String())
+
+	mockHealth.AssertExpectations(t)
+}
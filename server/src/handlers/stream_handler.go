@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/repositories"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/streaming"
+)
+
+// Interfaces para dependências
+type ServedQuotationSource interface {
+	GetServedQuotation(ctx context.Context, pair gateways.Pair) (ServedQuotation, error)
+}
+
+type QuotationSubscriber interface {
+	Subscribe(pairs []gateways.Pair) *streaming.Subscription
+}
+
+// Tipos de evento enviados em GET /cotacao/stream
+const (
+	EventQuotation = "quotation"
+	EventError     = "error"
+)
+
+// LastEventIDHeader é o cabeçalho com que o EventSource do navegador informa,
+// ao reconectar, o ID do último evento recebido
+const LastEventIDHeader = "Last-Event-ID"
+
+var ErrTooManyPairs = errors.New("too many pairs")
+
+// StreamHandler envia Server-Sent Events com as cotações dos pares pedidos:
+// primeiro as perdidas desde Last-Event-ID, lidas do histórico, depois a atual
+// de cada par, como GET /cotacao a serviria, e então cada cotação mais recente
+// obtida dos provedores. Comentários a cada Heartbeat mantêm a conexão viva.
+//
+// Cada conexão tem sua própria assinatura no Hub, que guarda só a cotação mais
+// recente de cada par ainda não enviada, e cada escrita tem prazo WriteTimeout:
+// um cliente lento perde cotações intermediárias e, se parar de ler, é
+// desconectado, sem atrasar os demais.
+type StreamHandler struct {
+	quotations ServedQuotationSource
+	subscriber QuotationSubscriber
+	history    HistoryRepository
+	// Heartbeat é o intervalo entre comentários enviados sem cotações novas
+	Heartbeat time.Duration
+	// WriteTimeout limita cada escrita na conexão
+	WriteTimeout time.Duration
+	// RetryInterval é a espera sugerida ao navegador antes de reconectar
+	RetryInterval time.Duration
+	// ReplayLimit limita as cotações repetidas do histórico por par
+	ReplayLimit int
+	// QueryTimeout limita cada consulta ao histórico
+	QueryTimeout time.Duration
+	// MaxPairs limita os pares de uma conexão
+	MaxPairs int
+}
+
+func NewStreamHandler(quotations ServedQuotationSource, subscriber QuotationSubscriber, history HistoryRepository) *StreamHandler {
+	return &StreamHandler{
+		quotations:    quotations,
+		subscriber:    subscriber,
+		history:       history,
+		Heartbeat:     15 * time.Second,
+		WriteTimeout:  5 * time.Second,
+		RetryInterval: 3 * time.Second,
+		ReplayLimit:   repositories.DefaultHistoryLimit,
+		QueryTimeout:  500 * time.Millisecond,
+		MaxPairs:      10,
+	}
+}
+
+// HandleGetStream atende GET /cotacao/stream?pairs=USD-BRL,EUR-BRL. Sem pairs,
+// acompanha USD-BRL. O navegador, que não envia cabeçalhos na primeira conexão,
+// pode informar o último ID em last_event_id.
+func (h *StreamHandler) HandleGetStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	pairs, err := parsePairs(r.URL.Query().Get("pairs"), h.MaxPairs)
+	if err != nil {
+		slog.InfoContext(ctx, "Pares do stream inválidos", "error", err)
+		writeBadRequest(w, err)
+		return
+	}
+
+	lastEventID := r.Header.Get(LastEventIDHeader)
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	cursor, err := streaming.ParseCursor(lastEventID)
+	if err != nil {
+		slog.InfoContext(ctx, "Last-Event-ID inválido", "error", err)
+		writeBadRequest(w, err)
+		return
+	}
+
+	// Assina antes de ler histórico e cotação atual para não perder o que for
+	// publicado nesse meio-tempo; o cursor descarta o que chegar repetido
+	subscription := h.subscriber.Subscribe(pairs)
+	defer subscription.Close()
+
+	controller := http.NewResponseController(w)
+	// O stream dura mais que o ReadTimeout do servidor; a leitura não deve
+	// encerrá-lo, e cada escrita ganha seu próprio prazo
+	if err := controller.SetReadDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.WarnContext(ctx, "Não foi possível remover o prazo de leitura do stream", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	stream := &eventStream{w: w, controller: controller, writeTimeout: h.WriteTimeout, cursor: cursor}
+	start := time.Now()
+	slog.InfoContext(ctx, "Stream de cotações aberto", "pairs", pairs, "last_event_id", lastEventID)
+	defer func() {
+		slog.InfoContext(ctx, "Stream de cotações encerrado",
+			"pairs", pairs,
+			"events", stream.events,
+			"conflated", subscription.Conflated(),
+			"duration", time.Since(start),
+		)
+	}()
+
+	if err := h.catchUp(ctx, stream, pairs); err != nil {
+		h.logWriteError(ctx, err)
+		return
+	}
+
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-subscription.Done():
+			// O servidor está desligando; o navegador reconecta em outra instância
+			return
+		case <-heartbeat.C:
+			err = stream.comment("heartbeat")
+		case <-subscription.Ready():
+			for _, quotation := range subscription.Next() {
+				if err = stream.quotation(quotation, NewQuotationResponse(quotation, SourceLive)); err != nil {
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			h.logWriteError(ctx, err)
+			return
+		}
+	}
+}
+
+// catchUp envia o intervalo de reconexão, as cotações perdidas desde o cursor
+// e a cotação atual de cada par
+func (h *StreamHandler) catchUp(ctx context.Context, stream *eventStream, pairs []gateways.Pair) error {
+	if err := stream.write(fmt.Sprintf("retry: %d\n\n", h.RetryInterval.Milliseconds())); err != nil {
+		return err
+	}
+
+	for _, pair := range pairs {
+		since, ok := stream.cursor[pair]
+		if !ok {
+			continue
+		}
+		if err := h.replay(ctx, stream, pair, since); err != nil {
+			return err
+		}
+	}
+
+	for _, pair := range pairs {
+		served, err := h.quotations.GetServedQuotation(ctx, pair)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Sem cotação atual o stream continua: a próxima obtida será enviada
+			slog.WarnContext(ctx, "Cotação atual indisponível para o stream", "pair", pair, "error", err)
+			if err := stream.error(pair, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := stream.quotation(served.Quotation, served.Response()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replay envia as cotações do histórico do par posteriores a since
+func (h *StreamHandler) replay(ctx context.Context, stream *eventStream, pair gateways.Pair, since time.Time) error {
+	queryCtx, cancel := context.WithTimeout(ctx, h.QueryTimeout)
+	defer cancel()
+
+	page, err := h.history.FindHistory(queryCtx, repositories.HistoryFilter{
+		Pair:    pair,
+		From:    since.Add(time.Millisecond),
+		Limit:   h.ReplayLimit,
+		RawOnly: true,
+	})
+	if err != nil {
+		// A cotação atual, enviada a seguir, ainda leva o cliente ao presente
+		slog.WarnContext(ctx, "Erro ao repetir histórico no stream", "pair", pair, "error", err)
+		return nil
+	}
+	if page.NextCursor != "" {
+		slog.InfoContext(ctx, "Histórico do stream truncado", "pair", pair, "limit", h.ReplayLimit)
+	}
+
+	for _, quotation := range page.Quotations {
+		if err := stream.quotation(quotation, NewQuotationResponse(quotation, SourceStored)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *StreamHandler) logWriteError(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	slog.InfoContext(ctx, "Cliente do stream lento ou desconectado", "error", err)
+}
+
+// eventStream escreve eventos no formato text/event-stream
+type eventStream struct {
+	w            io.Writer
+	controller   *http.ResponseController
+	writeTimeout time.Duration
+	cursor       streaming.Cursor
+	events       int
+}
+
+// quotation envia a cotação se ela for mais recente que a última enviada do
+// par; o ID do evento é o cursor atualizado
+func (s *eventStream) quotation(quotation gateways.Quotation, response QuotationResponse) error {
+	if !s.cursor.Advance(quotation) {
+		return nil
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	s.events++
+	return s.write(fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", s.cursor, EventQuotation, data))
+}
+
+// error envia um evento de erro sem ID, que não altera o ponto de retomada
+func (s *eventStream) error(pair gateways.Pair, err error) error {
+	data, marshalErr := json.Marshal(ErrorResponse{
+		Version: ResponseVersion,
+		Error: ErrorDetail{
+			Code:      ErrorCodeUpstreamUnavailable,
+			Message:   fmt.Sprintf("%s: %v", pair, err),
+			Retryable: true,
+		},
+	})
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return s.write(fmt.Sprintf("event: %s\ndata: %s\n\n", EventError, data))
+}
+
+// comment envia uma linha ignorada pelo EventSource, que mantém a conexão
+// viva em proxies e permite ao cliente detectar um servidor parado
+func (s *eventStream) comment(text string) error {
+	return s.write(fmt.Sprintf(": %s\n\n", text))
+}
+
+func (s *eventStream) write(frame string) error {
+	if s.writeTimeout > 0 {
+		err := s.controller.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+
+	if _, err := io.WriteString(s.w, frame); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+// parsePairs lê uma lista de pares separados por vírgula, sem repetições e
+// com no máximo maxPairs. Vazia, devolve DefaultPair.
+func parsePairs(value string, maxPairs int) ([]gateways.Pair, error) {
+	if strings.TrimSpace(value) == "" {
+		return []gateways.Pair{gateways.DefaultPair}, nil
+	}
+
+	pairs, err := parsePairList(strings.Split(value, ","))
+	if err != nil {
+		return nil, err
+	}
+	if maxPairs > 0 && len(pairs) > maxPairs {
+		return nil, fmt.Errorf("%w: %d requested, at most %d allowed", ErrTooManyPairs, len(pairs), maxPairs)
+	}
+	return pairs, nil
+}
+
+// parsePairList lê uma lista de pares, sem repetições
+func parsePairList(values []string) ([]gateways.Pair, error) {
+	var pairs []gateways.Pair
+	seen := make(map[gateways.Pair]bool)
+	for _, value := range values {
+		pair, err := gateways.ParsePair(value)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[pair] {
+			seen[pair] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs, nil
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/repositories"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/streaming"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Mock served quotation source
+type MockServedQuotationSource struct {
+	mock.Mock
+}
+
+func (m *MockServedQuotationSource) GetServedQuotation(ctx context.Context, pair gateways.Pair) (ServedQuotation, error) {
+	args := m.Called(ctx, pair)
+	return args.Get(0).(ServedQuotation), args.Error(1)
+}
+
+// sseEvent is one frame of a text/event-stream response
+type sseEvent struct {
+	ID      string
+	Event   string
+	Data    string
+	Retry   string
+	Comment string
+}
+
+type sseReader struct {
+	t       *testing.T
+	scanner *bufio.Scanner
+}
+
+func (r *sseReader) next() sseEvent {
+	r.t.Helper()
+
+	var event sseEvent
+	read := false
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if line == "" {
+			if read {
+				return event
+			}
+			continue
+		}
+		read = true
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			event.Comment = value
+		case "id":
+			event.ID = value
+		case "event":
+			event.Event = value
+		case "data":
+			event.Data = value
+		case "retry":
+			event.Retry = value
+		}
+	}
+	r.t.Fatalf("stream ended: %v", r.scanner.Err())
+	return event
+}
+
+func (r *sseReader) nextQuotation() (sseEvent, QuotationResponse) {
+	r.t.Helper()
+
+	event := r.next()
+	require.Equal(r.t, EventQuotation, event.Event)
+	var response QuotationResponse
+	require.NoError(r.t, json.Unmarshal([]byte(event.Data), &response))
+	return event, response
+}
+
+func eurBrlQuotation() gateways.Quotation {
+	return gateways.Quotation{
+		Code:       "EUR",
+		Codein:     "BRL",
+		Bid:        decimal.MustParse("6.3894"),
+		Ask:        decimal.MustParse("6.3922"),
+		CreateDate: time.Date(2023, 11, 29, 17, 55, 40, 0, time.UTC),
+		Provider:   "awesomeapi",
+		FetchedAt:  time.Date(2023, 11, 29, 17, 55, 43, 0, time.UTC),
+	}
+}
+
+// openStream starts a server for the handler and opens GET /cotacao/stream
+func openStream(t *testing.T, handler *StreamHandler, query string, header http.Header) (*http.Response, *sseReader) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cotacao/stream", handler.HandleGetStream)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/cotacao/stream"+query, nil)
+	require.NoError(t, err)
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp, &sseReader{t: t, scanner: bufio.NewScanner(resp.Body)}
+}
+
+func waitForSubscribers(t *testing.T, hub *streaming.Hub, subscribers int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return hub.Stats().Subscribers == subscribers
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestHandleGetStream(t *testing.T) {
+	quotations := new(MockServedQuotationSource)
+	quotations.On("GetServedQuotation", mock.Anything, gateways.Pair("USD-BRL")).Return(ServedQuotation{Quotation: usdBrlQuotation(), Source: SourceCached}, nil)
+	quotations.On("GetServedQuotation", mock.Anything, gateways.Pair("EUR-BRL")).Return(ServedQuotation{Quotation: eurBrlQuotation(), Source: SourceStored}, nil)
+
+	hub := streaming.NewHub()
+	handler := NewStreamHandler(quotations, hub, new(MockHistoryRepository))
+
+	resp, stream := openStream(t, handler, "?pairs=usd-brl,EUR-BRL,USD-BRL", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	assert.Equal(t, "3000", stream.next().Retry)
+
+	// Snapshot of each pair, as GET /cotacao would serve it
+	event, response := stream.nextQuotation()
+	assert.Equal(t, "USD-BRL=1701280542000", event.ID)
+	assert.Equal(t, gateways.Pair("USD-BRL"), response.Pair)
+	assert.Equal(t, SourceCached, response.Source)
+
+	event, response = stream.nextQuotation()
+	assert.Equal(t, "EUR-BRL=1701280540000,USD-BRL=1701280542000", event.ID)
+	assert.Equal(t, gateways.Pair("EUR-BRL"), response.Pair)
+	assert.Equal(t, SourceStored, response.Source)
+
+	// Live updates: the snapshot already sent is not repeated
+	waitForSubscribers(t, hub, 1)
+	assert.True(t, hub.Publish(usdBrlQuotation()))
+	newer := usdBrlQuotation()
+	newer.CreateDate = newer.CreateDate.Add(time.Second)
+	newer.Bid = decimal.MustParse("5.8600")
+	assert.True(t, hub.Publish(newer))
+
+	event, response = stream.nextQuotation()
+	assert.Equal(t, "EUR-BRL=1701280540000,USD-BRL=1701280543000", event.ID)
+	assert.Equal(t, "5.8600", response.Bid.String())
+	assert.Equal(t, SourceLive, response.Source)
+
+	// Closing the hub on shutdown ends the stream
+	hub.Close()
+	assert.False(t, stream.scanner.Scan())
+	waitForSubscribers(t, hub, 0)
+}
+
+func TestHandleGetStreamResume(t *testing.T) {
+	missed := usdBrlQuotation()
+	missed.CreateDate = missed.CreateDate.Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		query  string
+		header http.Header
+	}{
+		{name: "Last-Event-ID header", header: http.Header{LastEventIDHeader: {"USD-BRL=1701280422000"}}},
+		{name: "last_event_id parameter", query: "?last_event_id=USD-BRL%3D1701280422000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotations := new(MockServedQuotationSource)
+			quotations.On("GetServedQuotation", mock.Anything, gateways.DefaultPair).Return(ServedQuotation{Quotation: usdBrlQuotation(), Source: SourceLive}, nil)
+
+			history := new(MockHistoryRepository)
+			history.On("FindHistory", mock.Anything, repositories.HistoryFilter{
+				Pair:    gateways.DefaultPair,
+				From:    time.UnixMilli(1701280422001).UTC(),
+				Limit:   repositories.DefaultHistoryLimit,
+				RawOnly: true,
+			}).Return(repositories.HistoryPage{Quotations: []gateways.Quotation{missed, usdBrlQuotation()}}, nil)
+
+			handler := NewStreamHandler(quotations, streaming.NewHub(), history)
+			handler.Heartbeat = 10 * time.Millisecond
+			_, stream := openStream(t, handler, tt.query, tt.header)
+			stream.next()
+
+			event, response := stream.nextQuotation()
+			assert.Equal(t, "USD-BRL=1701280482000", event.ID)
+			assert.Equal(t, SourceStored, response.Source)
+
+			event, response = stream.nextQuotation()
+			assert.Equal(t, "USD-BRL=1701280542000", event.ID)
+			assert.Equal(t, SourceStored, response.Source)
+
+			// The snapshot equals the last replayed quotation and is skipped
+			assert.Equal(t, "heartbeat", stream.next().Comment)
+			history.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleGetStreamHeartbeat(t *testing.T) {
+	quotations := new(MockServedQuotationSource)
+	quotations.On("GetServedQuotation", mock.Anything, gateways.DefaultPair).Return(ServedQuotation{}, errors.New("gateway error"))
+
+	handler := NewStreamHandler(quotations, streaming.NewHub(), new(MockHistoryRepository))
+	handler.Heartbeat = 10 * time.Millisecond
+
+	_, stream := openStream(t, handler, "", nil)
+	stream.next()
+
+	// Without a current quotation the stream reports the error and goes on
+	event := stream.next()
+	assert.Equal(t, EventError, event.Event)
+	assert.Empty(t, event.ID)
+	assert.JSONEq(t, `{"version": 1, "error": {"code": "upstream_unavailable", "message": "USD-BRL: gateway error", "retryable": true}}`, event.Data)
+
+	assert.Equal(t, "heartbeat", stream.next().Comment)
+	assert.Equal(t, "heartbeat", stream.next().Comment)
+}
+
+func TestHandleGetStreamBadRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		header       http.Header
+		expectedCode string
+	}{
+		{name: "unsupported pair", query: "?pairs=USD-BRL,XYZ-BRL", expectedCode: ErrorCodeUnsupportedPair},
+		{name: "too many pairs", query: "?pairs=USD-BRL,EUR-BRL", expectedCode: ErrorCodeInvalidRequest},
+		{name: "invalid Last-Event-ID", header: http.Header{LastEventIDHeader: {"yesterday"}}, expectedCode: ErrorCodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := streaming.NewHub()
+			handler := NewStreamHandler(new(MockServedQuotationSource), hub, new(MockHistoryRepository))
+			handler.MaxPairs = 1
+
+			req := httptest.NewRequest(http.MethodGet, "/cotacao/stream"+tt.query, nil)
+			for name, values := range tt.header {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			rr := httptest.NewRecorder()
+
+			handler.HandleGetStream(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			var response ErrorResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedCode, response.Error.Code)
+			assert.Equal(t, 0, hub.Stats().Subscribers)
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/streaming"
+	"github.com/gorilla/websocket"
+)
+
+// Tipos de mensagem do protocolo de GET /cotacao/ws
+const (
+	// Enviadas pelo cliente
+	MessageSubscribe   = "subscribe"
+	MessageUnsubscribe = "unsubscribe"
+	// MessageSnapshot é o pedido do cliente e também a resposta do servidor,
+	// uma por par
+	MessageSnapshot = "snapshot"
+
+	// Enviadas pelo servidor
+	MessageSubscriptions = "subscriptions"
+	MessageDelta         = "delta"
+	MessageError         = "error"
+)
+
+// ClientMessage é uma mensagem enviada pelo cliente. ID é opcional e volta
+// nas respostas à mensagem, para que o cliente as associe ao pedido.
+type ClientMessage struct {
+	Type  string   `json:"type"`
+	ID    string   `json:"id,omitempty"`
+	Pairs []string `json:"pairs,omitempty"`
+}
+
+// ServerMessage é uma mensagem enviada pelo servidor. Conforme Type, leva os
+// pares assinados (subscriptions), uma cotação (snapshot, delta) ou um erro.
+type ServerMessage struct {
+	Version   int                `json:"version"`
+	Type      string             `json:"type"`
+	ID        string             `json:"id,omitempty"`
+	Pairs     []gateways.Pair    `json:"pairs,omitempty"`
+	Pair      gateways.Pair      `json:"pair,omitempty"`
+	Quotation *QuotationResponse `json:"quotation,omitempty"`
+	Error     *ErrorDetail       `json:"error,omitempty"`
+}
+
+var ErrUnsupportedMessage = errors.New("unsupported message")
+
+// WebSocketHandler atende assinaturas de cotações por WebSocket. O cliente
+// assina e cancela pares a qualquer momento e pede snapshots; o servidor
+// responde cada assinatura com a cotação atual do par, como GET /cotacao a
+// serviria, e depois envia um delta a cada cotação mais recente obtida dos
+// provedores.
+//
+// Como em StreamHandler, a assinatura no Hub guarda só a cotação mais recente
+// de cada par ainda não enviada, cada escrita tem prazo WriteTimeout e um
+// cliente que não responde aos pings por PongTimeout é desconectado.
+type WebSocketHandler struct {
+	quotations ServedQuotationSource
+	subscriber QuotationSubscriber
+	// WriteTimeout limita o handshake e cada escrita na conexão
+	WriteTimeout time.Duration
+	// PingInterval é o intervalo entre pings enviados ao cliente
+	PingInterval time.Duration
+	// PongTimeout é quanto tempo a conexão fica sem receber nada, nem pong,
+	// antes de ser encerrada; deve ser maior que PingInterval
+	PongTimeout time.Duration
+	// SnapshotTimeout limita a obtenção da cotação atual de cada snapshot,
+	// durante a qual a conexão não escreve nada; nunca passa de PongTimeout
+	SnapshotTimeout time.Duration
+	// MaxPairs limita os pares assinados, e os de cada snapshot, por conexão
+	MaxPairs int
+	// MaxMessageSize limita o tamanho das mensagens do cliente, em bytes
+	MaxMessageSize int64
+	// CheckOrigin decide se aceita conexões de navegadores em outra origem.
+	// Nil aceita apenas a mesma origem, ou clientes sem cabeçalho Origin.
+	CheckOrigin func(r *http.Request) bool
+}
+
+func NewWebSocketHandler(quotations ServedQuotationSource, subscriber QuotationSubscriber) *WebSocketHandler {
+	return &WebSocketHandler{
+		quotations:      quotations,
+		subscriber:      subscriber,
+		WriteTimeout:    5 * time.Second,
+		PingInterval:    30 * time.Second,
+		PongTimeout:     time.Minute,
+		SnapshotTimeout: 5 * time.Second,
+		MaxPairs:        10,
+		MaxMessageSize:  4096,
+	}
+}
+
+// HandleWebSocket atende GET /cotacao/ws
+func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{
+		HandshakeTimeout: h.WriteTimeout,
+		CheckOrigin:      h.CheckOrigin,
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade já respondeu ao cliente com o erro
+		slog.InfoContext(r.Context(), "Upgrade para WebSocket recusado", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// Depois do upgrade a conexão é do handler: o contexto da requisição não
+	// é cancelado quando o cliente desconecta, então a leitura o cancela
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	subscription := h.subscriber.Subscribe(nil)
+	defer subscription.Close()
+
+	session := &wsSession{
+		conn:         conn,
+		subscription: subscription,
+		cursor:       make(streaming.Cursor),
+		writeTimeout: h.WriteTimeout,
+	}
+	start := time.Now()
+	slog.InfoContext(ctx, "Conexão WebSocket aberta", "remote_addr", r.RemoteAddr)
+	defer func() {
+		slog.InfoContext(ctx, "Conexão WebSocket encerrada",
+			"pairs", subscription.Pairs(),
+			"received", session.received,
+			"sent", session.sent,
+			"conflated", subscription.Conflated(),
+			"duration", time.Since(start),
+		)
+	}()
+
+	messages := make(chan clientInput)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- h.read(ctx, conn, messages)
+		cancel()
+	}()
+
+	ping := time.NewTicker(h.PingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+
+		select {
+		case readError := <-readErr:
+			if !websocket.IsCloseError(readError, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.InfoContext(ctx, "Cliente WebSocket desconectado", "error", readError)
+			}
+			return
+		case <-subscription.Done():
+			// O servidor está desligando; o cliente reconecta em outra instância
+			session.close(websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-ping.C:
+			err = session.ping()
+		case input := <-messages:
+			session.received++
+			err = h.handle(ctx, session, input)
+		case <-subscription.Ready():
+			for _, quotation := range subscription.Next() {
+				if !session.cursor.Advance(quotation) {
+					continue
+				}
+				response := NewQuotationResponse(quotation, SourceLive)
+				if err = session.send(ServerMessage{Type: MessageDelta, Quotation: &response}); err != nil {
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.InfoContext(ctx, "Cliente WebSocket lento ou desconectado", "error", err)
+			}
+			return
+		}
+	}
+}
+
+// clientInput é uma mensagem lida do cliente, ou o motivo de ela ser inválida
+type clientInput struct {
+	message ClientMessage
+	err     error
+}
+
+// read lê as mensagens do cliente até a conexão falhar ou ser fechada. É a
+// única goroutine que lê da conexão; as respostas são escritas pelo handler.
+func (h *WebSocketHandler) read(ctx context.Context, conn *websocket.Conn, messages chan<- clientInput) error {
+	conn.SetReadLimit(h.MaxMessageSize)
+	extend := func() error {
+		return conn.SetReadDeadline(time.Now().Add(h.PongTimeout))
+	}
+	if err := extend(); err != nil {
+		return err
+	}
+	conn.SetPongHandler(func(string) error { return extend() })
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := extend(); err != nil {
+			return err
+		}
+
+		var input clientInput
+		if messageType != websocket.TextMessage {
+			input.err = fmt.Errorf("%w: only JSON text messages are accepted", ErrUnsupportedMessage)
+		} else if err := json.Unmarshal(data, &input.message); err != nil {
+			input.err = fmt.Errorf("%w: %w", ErrUnsupportedMessage, err)
+		}
+
+		select {
+		case messages <- input:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// handle atende uma mensagem do cliente. Erros do pedido são respondidos com
+// uma mensagem de erro e mantêm a conexão; só falhas de escrita a encerram.
+func (h *WebSocketHandler) handle(ctx context.Context, session *wsSession, input clientInput) error {
+	message := input.message
+	if input.err != nil {
+		slog.InfoContext(ctx, "Mensagem WebSocket inválida", "error", input.err)
+		return session.error(message.ID, "", ErrorCodeInvalidRequest, input.err.Error(), false)
+	}
+
+	switch message.Type {
+	case MessageSubscribe:
+		return h.subscribe(ctx, session, message)
+	case MessageUnsubscribe:
+		return h.unsubscribe(ctx, session, message)
+	case MessageSnapshot:
+		return h.snapshot(ctx, session, message)
+	default:
+		err := fmt.Errorf("%w: %q", ErrUnsupportedMessage, message.Type)
+		slog.InfoContext(ctx, "Mensagem WebSocket inválida", "error", err)
+		return session.error(message.ID, "", ErrorCodeInvalidRequest, err.Error(), false)
+	}
+}
+
+// subscribe assina os pares e envia o snapshot dos que ainda não eram assinados
+func (h *WebSocketHandler) subscribe(ctx context.Context, session *wsSession, message ClientMessage) error {
+	pairs, err := parsePairList(message.Pairs)
+	if err == nil && len(pairs) == 0 {
+		err = fmt.Errorf("%w: subscribe requires at least one pair", ErrUnsupportedMessage)
+	}
+	if err != nil {
+		return session.badRequest(message.ID, err)
+	}
+
+	subscribed := make(map[gateways.Pair]bool)
+	for _, pair := range session.subscription.Pairs() {
+		subscribed[pair] = true
+	}
+	var added []gateways.Pair
+	for _, pair := range pairs {
+		if !subscribed[pair] {
+			added = append(added, pair)
+		}
+	}
+	if total := len(subscribed) + len(added); h.MaxPairs > 0 && total > h.MaxPairs {
+		return session.badRequest(message.ID, fmt.Errorf("%w: %d subscribed, at most %d allowed", ErrTooManyPairs, total, h.MaxPairs))
+	}
+
+	// Assina antes de ler a cotação atual para não perder o que for publicado
+	// nesse meio-tempo; o cursor descarta o que chegar repetido
+	session.subscription.Add(added...)
+	slog.DebugContext(ctx, "Pares assinados por WebSocket", "added", added)
+	if err := session.subscriptions(message.ID); err != nil {
+		return err
+	}
+
+	for _, pair := range added {
+		if err := h.sendSnapshot(ctx, session, message.ID, pair); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsubscribe cancela os pares informados, ou todos se nenhum for informado
+func (h *WebSocketHandler) unsubscribe(ctx context.Context, session *wsSession, message ClientMessage) error {
+	pairs, err := parsePairList(message.Pairs)
+	if err != nil {
+		return session.badRequest(message.ID, err)
+	}
+	if len(pairs) == 0 {
+		pairs = session.subscription.Pairs()
+	}
+
+	session.subscription.Remove(pairs...)
+	for _, pair := range pairs {
+		// Uma nova assinatura do par volta a receber o snapshot
+		delete(session.cursor, pair)
+	}
+	slog.DebugContext(ctx, "Pares cancelados por WebSocket", "removed", pairs)
+	return session.subscriptions(message.ID)
+}
+
+// snapshot envia a cotação atual dos pares informados, ou dos assinados se
+// nenhum for informado, mesmo que ela já tenha sido enviada
+func (h *WebSocketHandler) snapshot(ctx context.Context, session *wsSession, message ClientMessage) error {
+	pairs, err := parsePairList(message.Pairs)
+	if err == nil && h.MaxPairs > 0 && len(pairs) > h.MaxPairs {
+		err = fmt.Errorf("%w: %d requested, at most %d allowed", ErrTooManyPairs, len(pairs), h.MaxPairs)
+	}
+	if err != nil {
+		return session.badRequest(message.ID, err)
+	}
+	if len(pairs) == 0 {
+		pairs = session.subscription.Pairs()
+	}
+
+	for _, pair := range pairs {
+		if err := h.sendSnapshot(ctx, session, message.ID, pair); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *WebSocketHandler) sendSnapshot(ctx context.Context, session *wsSession, id string, pair gateways.Pair) error {
+	snapshotCtx, cancel := context.WithTimeout(ctx, h.snapshotTimeout())
+	served, err := h.quotations.GetServedQuotation(snapshotCtx, pair)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Sem cotação atual a assinatura continua: a próxima obtida será enviada
+		slog.WarnContext(ctx, "Cotação atual indisponível para o WebSocket", "pair", pair, "error", err)
+		return session.error(id, pair, ErrorCodeUpstreamUnavailable, err.Error(), true)
+	}
+
+	// Um delta igual ao snapshot não é enviado depois
+	session.cursor.Advance(served.Quotation)
+	response := served.Response()
+	return session.send(ServerMessage{Type: MessageSnapshot, ID: id, Quotation: &response})
+}
+
+// snapshotTimeout devolve o prazo de cada snapshot, limitado por PongTimeout
+// para que a espera pelos provedores não atrase pings e deltas além do que o
+// cliente tolera
+func (h *WebSocketHandler) snapshotTimeout() time.Duration {
+	if h.SnapshotTimeout <= 0 || h.SnapshotTimeout > h.PongTimeout {
+		return h.PongTimeout
+	}
+	return h.SnapshotTimeout
+}
+
+// wsSession escreve as mensagens de uma conexão; só a goroutine do handler a usa
+type wsSession struct {
+	conn         *websocket.Conn
+	subscription *streaming.Subscription
+	cursor       streaming.Cursor
+	writeTimeout time.Duration
+	received     int
+	sent         int
+}
+
+func (s *wsSession) send(message ServerMessage) error {
+	message.Version = ResponseVersion
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil {
+		return err
+	}
+	if err := s.conn.WriteJSON(message); err != nil {
+		return err
+	}
+	s.sent++
+	return nil
+}
+
+// subscriptions confirma um pedido com todos os pares assinados
+func (s *wsSession) subscriptions(id string) error {
+	return s.send(ServerMessage{Type: MessageSubscriptions, ID: id, Pairs: s.subscription.Pairs()})
+}
+
+func (s *wsSession) error(id string, pair gateways.Pair, code, message string, retryable bool) error {
+	return s.send(ServerMessage{
+		Type: MessageError,
+		ID:   id,
+		Pair: pair,
+		Error: &ErrorDetail{
+			Code:      code,
+			Message:   message,
+			Retryable: retryable,
+		},
+	})
+}
+
+// badRequest responde um pedido inválido, distinguindo pares não suportados
+// como writeBadRequest
+func (s *wsSession) badRequest(id string, err error) error {
+	code := ErrorCodeInvalidRequest
+	if errors.Is(err, gateways.ErrUnsupportedPair) {
+		code = ErrorCodeUnsupportedPair
+	}
+	return s.error(id, "", code, err.Error(), false)
+}
+
+func (s *wsSession) ping() error {
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.writeTimeout))
+}
+
+// close envia o frame de fechamento; a conexão é fechada em seguida pelo handler
+func (s *wsSession) close(code int, reason string) {
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(s.writeTimeout))
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/streaming"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// dialWebSocket starts a server for the handler and connects to GET /cotacao/ws
+func dialWebSocket(t *testing.T, handler *WebSocketHandler) *websocket.Conn {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cotacao/ws", handler.HandleWebSocket)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/cotacao/ws", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func sendMessage(t *testing.T, conn *websocket.Conn, message ClientMessage) {
+	t.Helper()
+	require.NoError(t, conn.WriteJSON(message))
+}
+
+func receiveMessage(t *testing.T, conn *websocket.Conn) ServerMessage {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var message ServerMessage
+	require.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, ResponseVersion, message.Version)
+	return message
+}
+
+func TestHandleWebSocket(t *testing.T) {
+	quotations := new(MockServedQuotationSource)
+	quotations.On("GetServedQuotation", mock.Anything, gateways.Pair("USD-BRL")).Return(ServedQuotation{Quotation: usdBrlQuotation(), Source: SourceCached}, nil)
+	quotations.On("GetServedQuotation", mock.Anything, gateways.Pair("EUR-BRL")).Return(ServedQuotation{Quotation: eurBrlQuotation(), Source: SourceStored}, nil)
+
+	hub := streaming.NewHub()
+	conn := dialWebSocket(t, NewWebSocketHandler(quotations, hub))
+
+	// Subscribing acknowledges every subscribed pair, then sends a snapshot of each new one
+	sendMessage(t, conn, ClientMessage{Type: MessageSubscribe, ID: "1", Pairs: []string{"usd-brl"}})
+	ack := receiveMessage(t, conn)
+	assert.Equal(t, MessageSubscriptions, ack.Type)
+	assert.Equal(t, "1", ack.ID)
+	assert.Equal(t, []gateways.Pair{"USD-BRL"}, ack.Pairs)
+
+	snapshot := receiveMessage(t, conn)
+	assert.Equal(t, MessageSnapshot, snapshot.Type)
+	assert.Equal(t, "1", snapshot.ID)
+	require.NotNil(t, snapshot.Quotation)
+	assert.Equal(t, gateways.Pair("USD-BRL"), snapshot.Quotation.Pair)
+	assert.Equal(t, SourceCached, snapshot.Quotation.Source)
+
+	sendMessage(t, conn, ClientMessage{Type: MessageSubscribe, ID: "2", Pairs: []string{"EUR-BRL", "USD-BRL"}})
+	ack = receiveMessage(t, conn)
+	assert.Equal(t, []gateways.Pair{"EUR-BRL", "USD-BRL"}, ack.Pairs)
+	snapshot = receiveMessage(t, conn)
+	assert.Equal(t, gateways.Pair("EUR-BRL"), snapshot.Quotation.Pair)
+
+	// Deltas: the quotation already sent as a snapshot is not repeated
+	assert.True(t, hub.Publish(usdBrlQuotation()))
+	newer := usdBrlQuotation()
+	newer.CreateDate = newer.CreateDate.Add(time.Second)
+	newer.Bid = decimal.MustParse("5.8600")
+	assert.True(t, hub.Publish(newer))
+
+	delta := receiveMessage(t, conn)
+	assert.Equal(t, MessageDelta, delta.Type)
+	assert.Empty(t, delta.ID)
+	assert.Equal(t, "5.8600", delta.Quotation.Bid.String())
+	assert.Equal(t, SourceLive, delta.Quotation.Source)
+
+	// An explicit snapshot is always sent, even if nothing changed
+	sendMessage(t, conn, ClientMessage{Type: MessageSnapshot, ID: "3", Pairs: []string{"EUR-BRL"}})
+	snapshot = receiveMessage(t, conn)
+	assert.Equal(t, MessageSnapshot, snapshot.Type)
+	assert.Equal(t, "3", snapshot.ID)
+	assert.Equal(t, gateways.Pair("EUR-BRL"), snapshot.Quotation.Pair)
+
+	// Unsubscribed pairs get no more deltas
+	sendMessage(t, conn, ClientMessage{Type: MessageUnsubscribe, ID: "4", Pairs: []string{"USD-BRL"}})
+	ack = receiveMessage(t, conn)
+	assert.Equal(t, MessageSubscriptions, ack.Type)
+	assert.Equal(t, []gateways.Pair{"EUR-BRL"}, ack.Pairs)
+
+	newer.CreateDate = newer.CreateDate.Add(time.Second)
+	assert.True(t, hub.Publish(newer))
+	eur := eurBrlQuotation()
+	eur.CreateDate = eur.CreateDate.Add(time.Minute)
+	assert.True(t, hub.Publish(eur))
+
+	delta = receiveMessage(t, conn)
+	assert.Equal(t, MessageDelta, delta.Type)
+	assert.Equal(t, gateways.Pair("EUR-BRL"), delta.Quotation.Pair)
+
+	// Unsubscribing without pairs cancels every subscription
+	sendMessage(t, conn, ClientMessage{Type: MessageUnsubscribe})
+	ack = receiveMessage(t, conn)
+	assert.Empty(t, ack.Pairs)
+	assert.Equal(t, 1, hub.Stats().Subscribers)
+
+	// Closing the hub on shutdown closes the connection
+	hub.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error: %v", err)
+	require.Eventually(t, func() bool { return hub.Stats().Subscribers == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestHandleWebSocketInvalidMessages(t *testing.T) {
+	tests := []struct {
+		name         string
+		message      string
+		binary       bool
+		expectedCode string
+	}{
+		{name: "invalid JSON", message: `{"type":`, expectedCode: ErrorCodeInvalidRequest},
+		{name: "binary message", message: `{"type":"subscribe"}`, binary: true, expectedCode: ErrorCodeInvalidRequest},
+		{name: "unknown type", message: `{"type":"publish","id":"7"}`, expectedCode: ErrorCodeInvalidRequest},
+		{name: "subscribe without pairs", message: `{"type":"subscribe","id":"7"}`, expectedCode: ErrorCodeInvalidRequest},
+		{name: "unsupported pair", message: `{"type":"subscribe","id":"7","pairs":["USD-BRL","XYZ-BRL"]}`, expectedCode: ErrorCodeUnsupportedPair},
+		{name: "too many pairs", message: `{"type":"subscribe","id":"7","pairs":["USD-BRL","EUR-BRL","GBP-BRL"]}`, expectedCode: ErrorCodeInvalidRequest},
+		{name: "snapshot of too many pairs", message: `{"type":"snapshot","id":"7","pairs":["USD-BRL","EUR-BRL","GBP-BRL"]}`, expectedCode: ErrorCodeInvalidRequest},
+		{name: "unsubscribe unsupported pair", message: `{"type":"unsubscribe","id":"7","pairs":["XYZ-BRL"]}`, expectedCode: ErrorCodeUnsupportedPair},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewWebSocketHandler(new(MockServedQuotationSource), streaming.NewHub())
+			handler.MaxPairs = 2
+			conn := dialWebSocket(t, handler)
+
+			messageType := websocket.TextMessage
+			if tt.binary {
+				messageType = websocket.BinaryMessage
+			}
+			require.NoError(t, conn.WriteMessage(messageType, []byte(tt.message)))
+
+			response := receiveMessage(t, conn)
+			assert.Equal(t, MessageError, response.Type)
+			require.NotNil(t, response.Error)
+			assert.Equal(t, tt.expectedCode, response.Error.Code)
+			assert.False(t, response.Error.Retryable)
+
+			// The connection stays open and nothing was subscribed
+			sendMessage(t, conn, ClientMessage{Type: MessageUnsubscribe})
+			ack := receiveMessage(t, conn)
+			assert.Equal(t, MessageSubscriptions, ack.Type)
+			assert.Empty(t, ack.Pairs)
+		})
+	}
+}
+
+func TestHandleWebSocketSnapshotError(t *testing.T) {
+	quotations := new(MockServedQuotationSource)
+	quotations.On("GetServedQuotation", mock.Anything, gateways.DefaultPair).Return(ServedQuotation{}, errors.New("gateway error"))
+
+	hub := streaming.NewHub()
+	conn := dialWebSocket(t, NewWebSocketHandler(quotations, hub))
+
+	sendMessage(t, conn, ClientMessage{Type: MessageSubscribe, ID: "1", Pairs: []string{"USD-BRL"}})
+	assert.Equal(t, MessageSubscriptions, receiveMessage(t, conn).Type)
+
+	// Without a current quotation the subscription is kept and reports the error
+	response := receiveMessage(t, conn)
+	assert.Equal(t, MessageError, response.Type)
+	assert.Equal(t, "1", response.ID)
+	assert.Equal(t, gateways.DefaultPair, response.Pair)
+	assert.Equal(t, &ErrorDetail{Code: ErrorCodeUpstreamUnavailable, Message: "gateway error", Retryable: true}, response.Error)
+
+	assert.True(t, hub.Publish(usdBrlQuotation()))
+	delta := receiveMessage(t, conn)
+	assert.Equal(t, MessageDelta, delta.Type)
+}
+
+func TestHandleWebSocketSnapshotTimeout(t *testing.T) {
+	quotations := new(MockServedQuotationSource)
+	quotations.On("GetServedQuotation", mock.Anything, gateways.DefaultPair).
+		Run(func(args mock.Arguments) {
+			// A provider that never answers holds the snapshot until its deadline
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(ServedQuotation{}, context.DeadlineExceeded)
+
+	handler := NewWebSocketHandler(quotations, streaming.NewHub())
+	handler.SnapshotTimeout = 50 * time.Millisecond
+	conn := dialWebSocket(t, handler)
+
+	start := time.Now()
+	sendMessage(t, conn, ClientMessage{Type: MessageSubscribe, ID: "1", Pairs: []string{"USD-BRL"}})
+	assert.Equal(t, MessageSubscriptions, receiveMessage(t, conn).Type)
+
+	// The snapshot gives up at its deadline and the connection keeps working
+	response := receiveMessage(t, conn)
+	assert.Equal(t, MessageError, response.Type)
+	assert.Equal(t, gateways.DefaultPair, response.Pair)
+	assert.Equal(t, ErrorCodeUpstreamUnavailable, response.Error.Code)
+	assert.True(t, response.Error.Retryable)
+	assert.Less(t, time.Since(start), time.Second)
+
+	sendMessage(t, conn, ClientMessage{Type: MessageUnsubscribe})
+	assert.Equal(t, MessageSubscriptions, receiveMessage(t, conn).Type)
+}
+
+func TestWebSocketSnapshotTimeoutLimit(t *testing.T) {
+	tests := []struct {
+		name            string
+		snapshotTimeout time.Duration
+		expected        time.Duration
+	}{
+		{name: "within pong timeout", snapshotTimeout: 5 * time.Second, expected: 5 * time.Second},
+		{name: "above pong timeout", snapshotTimeout: 2 * time.Minute, expected: time.Minute},
+		{name: "unset", snapshotTimeout: 0, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewWebSocketHandler(new(MockServedQuotationSource), streaming.NewHub())
+			handler.SnapshotTimeout = tt.snapshotTimeout
+			assert.Equal(t, tt.expected, handler.snapshotTimeout())
+		})
+	}
+}
+
+func TestHandleWebSocketPongTimeout(t *testing.T) {
+	handler := NewWebSocketHandler(new(MockServedQuotationSource), streaming.NewHub())
+	handler.PingInterval = 20 * time.Millisecond
+	handler.PongTimeout = 50 * time.Millisecond
+	conn := dialWebSocket(t, handler)
+
+	// A client that does not read never answers the pings and is disconnected
+	time.Sleep(200 * time.Millisecond)
+
+	conn.SetPingHandler(func(string) error { return nil })
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var netErr interface{ Timeout() bool }
+			assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "connection was not closed: %v", err)
+			return
+		}
+	}
+}
+
+func TestHandleWebSocketRejectsPlainRequests(t *testing.T) {
+	handler := NewWebSocketHandler(new(MockServedQuotationSource), streaming.NewHub())
+
+	rr := httptest.NewRecorder()
+	handler.HandleWebSocket(rr, httptest.NewRequest(http.MethodGet, "/cotacao/ws", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
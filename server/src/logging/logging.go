@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Formatos de saída aceitos por New
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// RequestIDKey é o atributo que identifica a requisição em cada linha de log
+const RequestIDKey = "request_id"
+
+var (
+	ErrInvalidFormat = errors.New("invalid log format")
+	ErrInvalidLevel  = errors.New("invalid log level")
+)
+
+// ParseFormat aceita "json" ou "logfmt"
+func ParseFormat(value string) (string, error) {
+	switch format := strings.ToLower(strings.TrimSpace(value)); format {
+	case FormatJSON, FormatLogfmt:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w %q: expected json or logfmt", ErrInvalidFormat, value)
+	}
+}
+
+// ParseLevel aceita debug, info, warn ou error
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return 0, fmt.Errorf("%w %q: expected debug, info, warn or error", ErrInvalidLevel, value)
+	}
+	return level, nil
+}
+
+// New cria um logger no formato informado que descarta registros abaixo de
+// level e acrescenta o request_id guardado no contexto de cada chamada
+// *Context (InfoContext, ErrorContext...)
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch format {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	case FormatLogfmt:
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("%w %q: expected json or logfmt", ErrInvalidFormat, format)
+	}
+
+	return slog.New(contextHandler{Handler: handler}), nil
+}
+
+type requestIDContextKey struct{}
+
+// WithRequestID guarda o identificador da requisição no contexto
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestID devolve o identificador guardado por WithRequestID, ou ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// contextHandler copia o request_id do contexto para o registro
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		wantErr  bool
+	}{
+		{value: "json", expected: FormatJSON},
+		{value: " LOGFMT ", expected: FormatLogfmt},
+		{value: "text", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			format, err := ParseFormat(tt.value)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidFormat)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, format)
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected slog.Level
+		wantErr  bool
+	}{
+		{value: "debug", expected: slog.LevelDebug},
+		{value: "INFO", expected: slog.LevelInfo},
+		{value: "warn", expected: slog.LevelWarn},
+		{value: "error", expected: slog.LevelError},
+		{value: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			level, err := ParseLevel(tt.value)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLevel)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, level)
+		})
+	}
+}
+
+func TestNewJSON(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.With("component", "gateway").InfoContext(ctx, "Provedor falhou", "provider", "awesomeapi")
+	logger.DebugContext(ctx, "Descartado pelo nível")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "Provedor falhou", record["msg"])
+	assert.Equal(t, "awesomeapi", record["provider"])
+	assert.Equal(t, "gateway", record["component"])
+	assert.Equal(t, "req-1", record[RequestIDKey])
+}
+
+func TestNewLogfmt(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := New(&out, FormatLogfmt, slog.LevelDebug)
+	require.NoError(t, err)
+
+	logger.DebugContext(context.Background(), "Cotação gravada", "pair", "USD-BRL")
+
+	// Without a request ID in the context no attribute is added
+	assert.Contains(t, out.String(), `level=DEBUG msg="Cotação gravada" pair=USD-BRL`)
+	assert.NotContains(t, out.String(), RequestIDKey)
+
+	_, err = New(&out, "xml", slog.LevelInfo)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestRequestID(t *testing.T) {
+	assert.Equal(t, "", RequestID(context.Background()))
+	assert.Equal(t, "req-1", RequestID(WithRequestID(context.Background(), "req-1")))
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/responses"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader é o cabeçalho que carrega o identificador da requisição
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength limita identificadores recebidos de clientes
+const maxRequestIDLength = 128
+
+// Middleware associa um identificador a cada requisição: reaproveita o
+// X-Request-ID recebido, se for válido, ou gera um novo. O identificador é
+// devolvido no mesmo cabeçalho, guardado no contexto para as linhas de log
+// de handlers, gateways e repositórios, e registrado ao fim da requisição.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := WithRequestID(r.Context(), id)
+		recorder := responses.Wrap(w)
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if recorder.Status() >= http.StatusInternalServerError {
+			level = slog.LevelWarn
+		}
+		slog.Log(ctx, level, "Requisição atendida",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.Status(),
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+		)
+	})
+}
+
+// validRequestID aceita identificadores curtos de caracteres ASCII visíveis,
+// para que um cliente não injete quebras de linha ou textos enormes nos logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
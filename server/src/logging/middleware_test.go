@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs redirects the default logger to a JSON buffer for the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var out bytes.Buffer
+	logger, err := New(&out, FormatJSON, slog.LevelDebug)
+	require.NoError(t, err)
+
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	return &out
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        string
+		keepsReceived bool
+	}{
+		{name: "reuses the received ID", header: "client-abc-123", keepsReceived: true},
+		{name: "generates an ID when missing", header: ""},
+		{name: "replaces an ID with control characters", header: "abc\nlevel=ERROR"},
+		{name: "replaces an oversized ID", header: strings.Repeat("a", 200)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureLogs(t)
+
+			var handlerID string
+			handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerID = RequestID(r.Context())
+				slog.InfoContext(r.Context(), "Dentro do handler")
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/cotacao", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			recorder := httptest.NewRecorder()
+
+			handler.ServeHTTP(recorder, req)
+
+			id := recorder.Header().Get(RequestIDHeader)
+			require.NotEmpty(t, id)
+			assert.Equal(t, id, handlerID)
+			if tt.keepsReceived {
+				assert.Equal(t, tt.header, id)
+			} else {
+				assert.Len(t, id, 36)
+			}
+
+			// Both the handler line and the access line carry the ID
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			require.Len(t, lines, 2)
+
+			var access map[string]any
+			require.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+			assert.Equal(t, id, access[RequestIDKey])
+			assert.Equal(t, "WARN", access["level"])
+			assert.Equal(t, float64(http.StatusBadGateway), access["status"])
+			assert.Equal(t, "/cotacao", access["path"])
+			assert.Contains(t, lines[0], `"request_id":"`+id+`"`)
+		})
+	}
+}
@@ -1,62 +1,512 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/config"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/conversions"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/database"
 	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
 	"github.com/CaiqueRibeiro/client-api-ex/server/src/handlers"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/logging"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/metrics"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/migrations"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/persistence"
 	"github.com/CaiqueRibeiro/client-api-ex/server/src/repositories"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/schedulers"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/streaming"
+	"github.com/CaiqueRibeiro/client-api-ex/shared/configloader"
 )
 
 func main() {
-	// Analisa os flags da linha de comando
-	port := flag.String("port", "8080", "HTTP server port")
-	dbPath := flag.String("db", "./quotations.db", "Path to SQLite database file")
-	flag.Parse()
+	// "server migrate ...", "server dedupe ..." e "server compact ..." administram o
+	// banco sem iniciar o servidor
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "dedupe":
+			os.Exit(runDedupe(os.Args[2:]))
+		case "compact":
+			os.Exit(runCompact(os.Args[2:]))
+		}
+	}
 
-	db, err := sql.Open("sqlite3", *dbPath)
+	// Junta padrões, arquivo de configuração, ambiente e flags
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
-		log.Fatalf("Failed to connect to SQLite database: %v", err)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	logger, err := newLogger(cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	schedule, err := schedulers.ParseSchedule(cfg.Poll)
+	if err != nil {
+		fatal("Invalid polling schedule", "error", err)
+	}
+
+	db, dialect, err := database.Open(cfg.DB)
+	if err != nil {
+		fatal("Failed to connect to database", "error", err)
+	}
+
+	migrator, err := migrations.NewMigrator(db, dialect)
+	if err != nil {
+		fatal("Failed to load migrations", "error", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		fatal("Failed to migrate database", "error", err)
 	}
-	defer db.Close()
 
-	err = createDatabaseTable(db)
+	overflowPolicy, err := persistence.ParseOverflowPolicy(cfg.PersistPolicy)
 	if err != nil {
-		log.Fatalf("Failed to create database table: %v", err)
+		fatal("Invalid persistence policy", "error", err)
 	}
 
-	quotationsRepository := repositories.NewQuotationsRepository(db)
-	quotationGateway := gateways.NewQuotationGateway()
+	quotationsRepository := repositories.NewRepository(db, dialect)
+	serviceMetrics := metrics.New()
+
+	persistenceQueue := persistence.NewQueue(quotationsRepository)
+	persistenceQueue.Capacity = cfg.PersistCapacity
+	persistenceQueue.BatchSize = cfg.PersistBatch
+	persistenceQueue.FlushInterval = cfg.PersistFlush
+	persistenceQueue.Policy = overflowPolicy
+	persistenceQueue.MaxRetries = cfg.PersistRetries
+	persistenceQueue.WriteTimeout = cfg.PersistWriteTimeout
+	persistenceQueue.Observer = serviceMetrics
+	persistenceQueue.Start()
+
+	awesomeAPI := gateways.NewAwesomeAPIProvider()
+	awesomeAPI.URL = cfg.AwesomeAPIURL
+	frankfurter := gateways.NewFrankfurterProvider()
+	frankfurter.URL = cfg.FrankfurterURL
+
+	quotationGateway := gateways.NewQuotationGateway(awesomeAPI, frankfurter)
+	quotationGateway.Timeout = cfg.UpstreamTimeout
+	quotationGateway.FailureThreshold = cfg.BreakerThreshold
+	quotationGateway.BreakerCooldown = cfg.BreakerCooldown
+	// O Hub recebe cada cotação obtida dos provedores, por requisição ou pelo
+	// poller, e a repassa às conexões de /cotacao/stream
+	quotationHub := streaming.NewHub()
+	quotationGateway.Observer = gateways.FetchObservers{serviceMetrics, quotationHub}
 	quotationHandler := handlers.NewQuotationHandler(quotationGateway, quotationsRepository)
+	quotationHandler.MaxAge = cfg.MaxAge
+	quotationHandler.CacheTTL = cfg.CacheTTL
+	quotationHandler.DBTimeout = cfg.DBTimeout
+	quotationHandler.Queue = persistenceQueue
+
+	historyHandler := handlers.NewHistoryHandler(quotationsRepository)
+	streamHandler := handlers.NewStreamHandler(quotationHandler, quotationHub, quotationsRepository)
+	streamHandler.Heartbeat = cfg.StreamHeartbeat
+	streamHandler.WriteTimeout = cfg.StreamWriteTimeout
+	streamHandler.MaxPairs = cfg.StreamMaxPairs
+	webSocketHandler := handlers.NewWebSocketHandler(quotationHandler, quotationHub)
+	webSocketHandler.WriteTimeout = cfg.StreamWriteTimeout
+	webSocketHandler.PingInterval = cfg.WSPingInterval
+	webSocketHandler.PongTimeout = 2 * cfg.WSPingInterval
+	// A snapshot gets the same budget as a GET /cotacao response
+	webSocketHandler.SnapshotTimeout = cfg.WriteTimeout
+	webSocketHandler.MaxPairs = cfg.StreamMaxPairs
+	candlesHandler := handlers.NewCandlesHandler(quotationsRepository)
+	convertHandler := handlers.NewConvertHandler(conversions.NewConverter(quotationHandler))
+	healthHandler := handlers.NewHealthHandler(db, migrator, quotationGateway)
+	healthHandler.MaxUpstreamAge = cfg.ReadyMaxUpstreamAge
+	healthHandler.Polling = len(schedule) > 0
+	statusHandler := handlers.NewStatusHandler(quotationGateway, persistenceQueue)
+	statusHandler.Health = healthHandler
+
+	// As tarefas em segundo plano param no desligamento, antes de a fila ser
+	// fechada, para não enfileirar cotações que não seriam mais gravadas
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+
+	quotationPoller := schedulers.NewQuotationPoller(quotationGateway, persistenceQueue, schedule)
+	quotationPoller.PersistTimeout = cfg.DBTimeout
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		quotationPoller.Run(jobsCtx)
+	}()
+
+	if cfg.Retention > 0 {
+		retentionJob := schedulers.NewRetentionJob(quotationsRepository, cfg.Retention)
+		retentionJob.Interval = cfg.RetentionInterval
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			retentionJob.Run(jobsCtx)
+		}()
+	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /cotacao", quotationHandler.HandleGetQuotation)
+	mux.HandleFunc("GET /cotacao/{pair}", quotationHandler.HandleGetQuotation)
+	mux.HandleFunc("GET /cotacao/stream", streamHandler.HandleGetStream)
+	mux.HandleFunc("GET /cotacao/ws", webSocketHandler.HandleWebSocket)
+	mux.HandleFunc("GET /cotacao/history", historyHandler.HandleGetHistory)
+	mux.HandleFunc("GET /cotacao/candles", candlesHandler.HandleGetCandles)
+	mux.HandleFunc("GET /convert", convertHandler.HandleConvert)
+	mux.HandleFunc("GET /healthz", healthHandler.HandleGetHealthz)
+	mux.HandleFunc("GET /readyz", healthHandler.HandleGetReadyz)
+	mux.HandleFunc("GET /status", statusHandler.HandleGetStatus)
+	mux.HandleFunc("GET /status/breakers", statusHandler.HandleGetBreakers)
+	mux.HandleFunc("GET /status/persistence", statusHandler.HandleGetPersistence)
+	mux.Handle("GET /metrics", serviceMetrics.Handler())
+
+	server := &http.Server{
+		Addr: fmt.Sprintf(":%s", cfg.Port),
+		// O middleware de logging fica por fora para que o request_id já esteja
+		// no contexto de tudo que os handlers registram
+		Handler:           logging.Middleware(serviceMetrics.Middleware(mux)),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	// Shutdown não espera conexões de streaming, que nunca ficam ociosas, nem
+	// as de WebSocket, que deixam de ser do servidor: encerrar as assinaturas
+	// faz cada uma terminar
+	server.RegisterOnShutdown(quotationHub.Close)
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "addr", server.Addr, "config_file", cfg.ConfigFile, "log_format", cfg.LogFormat, "log_level", cfg.LogLevel)
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	exitCode := 0
+	select {
+	case sig := <-stop:
+		slog.Info("Shutdown signal received", "signal", sig.String(), "grace", cfg.ShutdownGrace)
+	case err := <-serverErrors:
+		slog.Error("Server stopped", "error", err)
+		exitCode = 1
+	}
+
+	// Um segundo sinal encerra o processo sem esperar o fim do desligamento
+	go func() {
+		sig := <-stop
+		slog.Warn("Second signal received, exiting immediately", "signal", sig.String())
+		os.Exit(1)
+	}()
+
+	if !shutdown(server, healthHandler, stopJobs, &jobs, persistenceQueue, cfg.ShutdownDelay, cfg.ShutdownGrace) {
+		exitCode = 1
+	}
+
+	if err := db.Close(); err != nil {
+		slog.Error("Failed to close database", "error", err)
+		exitCode = 1
+	}
+
+	slog.Info("Server stopped", "exit_code", exitCode)
+	os.Exit(exitCode)
+}
+
+// shutdown desliga o servidor em ordem, dentro de um único prazo grace:
+//  1. /readyz passa a responder 503 e, durante delay, o servidor continua
+//     atendendo enquanto o balanceador deixa de enviar tráfego;
+//  2. o servidor para de aceitar conexões e espera as requisições em andamento;
+//  3. o poller e a compactação param;
+//  4. a fila grava as cotações pendentes.
+//
+// Devolve false se alguma etapa não terminou dentro do prazo.
+func shutdown(server *http.Server, health *handlers.HealthHandler, stopJobs context.CancelFunc, jobs *sync.WaitGroup, queue *persistence.Queue, delay, grace time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	clean := true
+
+	health.Drain()
+	if delay > 0 {
+		slog.Info("Draining before closing listeners", "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Failed to finish in-flight requests", "error", err)
+		clean = false
+	}
+
+	stopJobs()
+	jobsDone := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+	case <-ctx.Done():
+		slog.Error("Failed to stop background jobs", "error", ctx.Err())
+		clean = false
+	}
+
+	slog.Info("Flushing pending quotations", "pending", queue.Stats().Pending)
+	if err := queue.Close(ctx); err != nil {
+		slog.Error("Failed to flush persistence queue", "error", err)
+		clean = false
+	}
+
+	stats := queue.Stats()
+	slog.Info("Persistence queue closed", "persisted", stats.Persisted, "duplicates", stats.Duplicates, "dropped", stats.Dropped, "failed", stats.Failed)
+
+	return clean
+}
+
+// newLogger cria o logger padrão a partir dos flags -log-format e -log-level
+func newLogger(format, level string) (*slog.Logger, error) {
+	parsedFormat, err := logging.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	parsedLevel, err := logging.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return logging.New(os.Stderr, parsedFormat, parsedLevel)
+}
+
+// fatal registra o erro e encerra o processo
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// defaultDB é o -db padrão dos subcomandos: o mesmo banco do servidor quando
+// definido no ambiente
+func defaultDB() string {
+	if dsn, ok := os.LookupEnv(configloader.EnvName(config.EnvPrefix, "db")); ok {
+		return dsn
+	}
+	return config.Default().DB
+}
+
+const migrateUsage = `Usage: server migrate [-db dsn] <command>
+
+Commands:
+  up              Apply all pending migrations
+  down [-steps n] Revert the last n applied migrations (default 1)
+  status          List migrations and whether each one is applied
+`
+
+// runMigrate executa o subcomando migrate e devolve o código de saída
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), migrateUsage) }
+	dbDSN := flags.String("db", defaultDB(), config.DBUsage)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+
+	db, dialect, err := database.Open(*dbDSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db, dialect)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load migrations: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		versions, err := migrator.Up(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to apply migrations: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Applied %d migration(s)\n", len(versions))
+	case "down":
+		downFlags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := downFlags.Int("steps", 1, "Number of migrations to revert")
+		if err := downFlags.Parse(commandArgs); err != nil {
+			return 2
+		}
+		if *steps <= 0 {
+			fmt.Fprintln(os.Stderr, "-steps must be positive")
+			return 2
+		}
+
+		versions, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to revert migrations: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Reverted %d migration(s)\n", len(versions))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+			return 1
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown migrate command %q\n\n", command)
+		flags.Usage()
+		return 2
+	}
+
+	return 0
+}
+
+// runDedupe apaga as cotações repetidas de bancos gravados antes da regra de
+// unicidade e então aplica as migrações restantes. A migração do índice único
+// já faz o mesmo; o comando permite contá-las antes (-dry-run). Devolve o
+// código de saída.
+func runDedupe(args []string) int {
+	flags := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: server dedupe [-db dsn] [-dry-run]")
+		flags.PrintDefaults()
+	}
+	dbDSN := flags.String("db", defaultDB(), config.DBUsage)
+	dryRun := flags.Bool("dry-run", false, "Only report how many repeated quotations would be removed")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	db, dialect, err := database.Open(*dbDSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db, dialect)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load migrations: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+
+	// O índice único só pode ser criado depois que as repetidas forem apagadas
+	if _, err := migrator.UpTo(ctx, migrations.UniqueQuotationsVersion-1); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to migrate database: %v\n", err)
+		return 1
+	}
+
+	repository := repositories.NewRepository(db, dialect)
+
+	if *dryRun {
+		count, err := repository.CountDuplicates(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to count repeated quotations: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Found %d repeated quotation(s)\n", count)
+		return 0
+	}
+
+	removed, err := repository.RemoveDuplicates(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to remove repeated quotations: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Removed %d repeated quotation(s)\n", removed)
+
+	if _, err := migrator.Up(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to migrate database: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// runCompact resume e apaga as cotações brutas mais antigas que -retention,
+// como o servidor faz periodicamente. Devolve o código de saída.
+func runCompact(args []string) int {
+	flags := flag.NewFlagSet("compact", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: server compact [-db dsn] -retention duration")
+		flags.PrintDefaults()
+	}
+	dbDSN := flags.String("db", defaultDB(), config.DBUsage)
+	retention := flags.Duration("retention", 0, config.RetentionUsage)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *retention <= 0 {
+		fmt.Fprintln(os.Stderr, "-retention must be positive")
+		return 2
+	}
+
+	db, dialect, err := database.Open(*dbDSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db, dialect)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load migrations: %v\n", err)
+		return 1
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to migrate database: %v\n", err)
+		return 1
+	}
+
+	job := schedulers.NewRetentionJob(repositories.NewRepository(db, dialect), *retention)
+	// Uma execução manual pode ter um acúmulo grande para compactar
+	job.Timeout = time.Hour
+
+	result, err := job.RunOnce(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to compact quotations: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Compacted %d quotation(s) older than %s into %d rollup(s)\n", result.Removed, job.Cutoff().Format(time.DateOnly), result.Rollups)
 
-	serverAddr := fmt.Sprintf(":%s", *port)
-
-	log.Printf("Starting server on %s", serverAddr)
-	log.Fatal(http.ListenAndServe(serverAddr, mux))
-}
-
-func createDatabaseTable(conn *sql.DB) error {
-	_, err := conn.Exec(`CREATE TABLE IF NOT EXISTS quotations (
-		id TEXT PRIMARY KEY,
-		code TEXT,
-		codein TEXT,
-		name TEXT,
-		high TEXT,
-		low TEXT,
-		varBid TEXT,
-		pctChange TEXT,
-		bid TEXT,
-		ask TEXT,
-		timestamp TEXT,
-		create_date TEXT
-	)`)
-	return err
+	return 0
 }
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/responses"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixa o nome de todas as métricas do serviço
+const Namespace = "quotation"
+
+// Resultado de uma consulta a um provedor ou de uma gravação no banco
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// UnmatchedRoute é o rótulo das requisições que não correspondem a nenhuma rota
+const UnmatchedRoute = "unmatched"
+
+// Metrics registra as métricas do serviço em um registro próprio e as expõe no
+// formato texto do Prometheus. Implementa gateways.FetchObserver e
+// persistence.WriteObserver.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	requests                *prometheus.CounterVec
+	requestDuration         *prometheus.HistogramVec
+	upstreamDuration        *prometheus.HistogramVec
+	upstreamErrors          *prometheus.CounterVec
+	dbWriteDuration         *prometheus.HistogramVec
+	dbWriteDeadlineExceeded prometheus.Counter
+	latestBid               *prometheus.GaugeVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to serve an HTTP request, by route, method and status code.",
+			Buckets:   []float64{.001, .005, .01, .025, .05, .1, .2, .3, .5, 1, 2.5, 5},
+		}, []string{"route", "method", "status"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Time of each quotation provider call, by provider and outcome.",
+			Buckets:   []float64{.01, .025, .05, .1, .15, .2, .3, .5, 1, 2},
+		}, []string{"provider", "outcome"}),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "upstream_errors_total",
+			Help:      "Failed quotation provider calls, by provider and error class (timeout, network, http, decode, invalid, canceled, other).",
+		}, []string{"provider", "class"}),
+		dbWriteDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "db_write_duration_seconds",
+			Help:      "Time of each batch write to the database, by outcome.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"outcome"}),
+		dbWriteDeadlineExceeded: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "db_write_deadline_exceeded_total",
+			Help:      "Batch writes to the database that exceeded their deadline.",
+		}),
+		latestBid: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "latest_bid",
+			Help:      "Bid of the latest quotation fetched from a provider, by pair.",
+		}, []string{"pair"}),
+	}
+
+	m.Registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.upstreamDuration,
+		m.upstreamErrors,
+		m.dbWriteDuration,
+		m.dbWriteDeadlineExceeded,
+		m.latestBid,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Handler atende GET /metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// Middleware conta e mede as requisições atendidas por next. A rota é o padrão
+// do ServeMux que atendeu a requisição, de forma que /cotacao/USD-BRL e
+// /cotacao/EUR-BRL são contadas juntas em /cotacao/{pair}.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := responses.Wrap(w)
+
+		next.ServeHTTP(recorder, r)
+
+		labels := prometheus.Labels{
+			"route":  route(r),
+			"method": r.Method,
+			"status": strconv.Itoa(recorder.Status()),
+		}
+		m.requests.With(labels).Inc()
+		m.requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// route devolve o caminho do padrão definido pelo ServeMux em r.Pattern
+func route(r *http.Request) string {
+	if r.Pattern == "" {
+		return UnmatchedRoute
+	}
+
+	// "GET /cotacao/{pair}" -> "/cotacao/{pair}"
+	if _, path, ok := strings.Cut(r.Pattern, " "); ok {
+		return path
+	}
+	return r.Pattern
+}
+
+// ObserveFetch registra a duração e, em caso de falha, a classe do erro de uma
+// consulta a um provedor
+func (m *Metrics) ObserveFetch(provider string, pair gateways.Pair, duration time.Duration, err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+		m.upstreamErrors.WithLabelValues(provider, gateways.ErrorClass(err)).Inc()
+	}
+	m.upstreamDuration.WithLabelValues(provider, outcome).Observe(duration.Seconds())
+}
+
+// ObserveQuotation atualiza o último bid do par
+func (m *Metrics) ObserveQuotation(quotation gateways.Quotation) {
+	bid, _ := quotation.Bid.Rat().Float64()
+	m.latestBid.WithLabelValues(quotation.Pair().String()).Set(bid)
+}
+
+// ObserveWrite registra a duração de uma gravação de lote e se ela excedeu o prazo
+func (m *Metrics) ObserveWrite(size int, duration time.Duration, err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+		if errors.Is(err, context.DeadlineExceeded) {
+			m.dbWriteDeadlineExceeded.Inc()
+		}
+	}
+	m.dbWriteDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
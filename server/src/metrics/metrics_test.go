@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /cotacao/{pair}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("pair") == "XYZ-BRL" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+	handler := m.Middleware(mux)
+
+	for _, path := range []string{"/cotacao/USD-BRL", "/cotacao/EUR-BRL", "/cotacao/XYZ-BRL", "/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Requests are grouped by route pattern, not by path
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues("/cotacao/{pair}", "GET", "200")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("/cotacao/{pair}", "GET", "400")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues(UnmatchedRoute, "GET", "404")))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.requestDuration))
+}
+
+func TestObserveFetch(t *testing.T) {
+	m := New()
+
+	m.ObserveFetch("awesomeapi", gateways.DefaultPair, 50*time.Millisecond, nil)
+	m.ObserveFetch("awesomeapi", gateways.DefaultPair, 200*time.Millisecond, fmt.Errorf("awesomeapi: %w", context.DeadlineExceeded))
+	m.ObserveFetch("awesomeapi", gateways.DefaultPair, 30*time.Millisecond, fmt.Errorf("%w 500 for USD-BRL", gateways.ErrUnexpectedStatus))
+	m.ObserveFetch("frankfurter", gateways.DefaultPair, 30*time.Millisecond, fmt.Errorf("%w: unexpected EOF", gateways.ErrDecode))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.upstreamErrors.WithLabelValues("awesomeapi", gateways.ErrorClassTimeout)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.upstreamErrors.WithLabelValues("awesomeapi", gateways.ErrorClassHTTP)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.upstreamErrors.WithLabelValues("frankfurter", gateways.ErrorClassDecode)))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.upstreamDuration))
+}
+
+func TestObserveQuotation(t *testing.T) {
+	m := New()
+
+	m.ObserveQuotation(gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576")})
+	m.ObserveQuotation(gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8580")})
+	m.ObserveQuotation(gateways.Quotation{Code: "EUR", Codein: "BRL", Bid: decimal.MustParse("6.3894")})
+
+	assert.Equal(t, 5.858, testutil.ToFloat64(m.latestBid.WithLabelValues("USD-BRL")))
+	assert.Equal(t, 6.3894, testutil.ToFloat64(m.latestBid.WithLabelValues("EUR-BRL")))
+}
+
+func TestObserveWrite(t *testing.T) {
+	m := New()
+
+	m.ObserveWrite(10, 2*time.Millisecond, nil)
+	m.ObserveWrite(10, time.Second, fmt.Errorf("falha ao gravar lote: %w", context.DeadlineExceeded))
+	m.ObserveWrite(10, time.Millisecond, errors.New("database is locked"))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.dbWriteDeadlineExceeded))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.dbWriteDuration))
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.ObserveQuotation(gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576")})
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain"))
+
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `quotation_latest_bid{pair="USD-BRL"} 5.8576`)
+	assert.Contains(t, string(body), "go_goroutines")
+}
@@ -0,0 +1,396 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/database"
+)
+
+// Cada dialeto tem seu diretório de migrações; a mesma versão descreve o mesmo
+// esquema em todos eles
+//
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+// UniqueQuotationsVersion é a migração que torna (pair, provider, timestamp)
+// único; bancos com cotações repetidas precisam passar pelo dedupe antes dela
+const UniqueQuotationsVersion = 4
+
+var (
+	ErrInvalidMigration = errors.New("invalid migration")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+)
+
+// Migration é uma mudança versionada do esquema, lida dos arquivos
+// NNNN_nome.up.sql e NNNN_nome.down.sql embutidos no binário
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status informa se uma migração já foi aplicada ao banco
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load devolve as migrações embutidas do dialeto, em ordem de versão
+func Load(dialect database.Dialect) ([]Migration, error) {
+	fsys, err := fs.Sub(files, string(dialect))
+	if err != nil {
+		return nil, err
+	}
+	return parse(fsys)
+}
+
+func parse(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		versionText, migrationName, hasName := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionText)
+		if !ok || !hasName || err != nil || version <= 0 || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("%w: unexpected file name %q", ErrInvalidMigration, name)
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: migrationName}
+			byVersion[version] = migration
+		} else if migration.Name != migrationName {
+			return nil, fmt.Errorf("%w: version %d used by %q and %q", ErrInvalidMigration, version, migration.Name, migrationName)
+		}
+
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("%w: %04d_%s needs both up and down files", ErrInvalidMigration, migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Migrator aplica e desfaz migrações, registrando as aplicadas em schema_migrations
+type Migrator struct {
+	Db         *sql.DB
+	Dialect    database.Dialect
+	migrations []Migration
+}
+
+// NewMigrator cria um Migrator com as migrações embutidas do dialeto
+func NewMigrator(db *sql.DB, dialect database.Dialect) (*Migrator, error) {
+	migrations, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{Db: db, Dialect: dialect, migrations: migrations}, nil
+}
+
+// Up aplica, em ordem, todas as migrações pendentes e devolve as versões aplicadas
+func (m *Migrator) Up(ctx context.Context) ([]int, error) {
+	if len(m.migrations) == 0 {
+		return nil, nil
+	}
+	return m.UpTo(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// UpTo aplica, em ordem, as migrações pendentes até a versão informada, inclusive
+func (m *Migrator) UpTo(ctx context.Context, version int) ([]int, error) {
+	applied, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.apply(ctx, migration.Up, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx,
+				m.Dialect.Rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+				migration.Version, migration.Name, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return versions, fmt.Errorf("migration %04d_%s up: %w", migration.Version, migration.Name, err)
+		}
+
+		slog.InfoContext(ctx, "Migração aplicada", "version", migration.Version, "name", migration.Name)
+		versions = append(versions, migration.Version)
+	}
+
+	return versions, nil
+}
+
+// Down desfaz as últimas steps migrações aplicadas, da mais recente para a mais
+// antiga, e devolve as versões desfeitas
+func (m *Migrator) Down(ctx context.Context, steps int) ([]int, error) {
+	applied, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	for i := len(m.migrations) - 1; i >= 0 && len(versions) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		err := m.apply(ctx, migration.Down, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, m.Dialect.Rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+			return err
+		})
+		if err != nil {
+			return versions, fmt.Errorf("migration %04d_%s down: %w", migration.Version, migration.Name, err)
+		}
+
+		slog.InfoContext(ctx, "Migração desfeita", "version", migration.Version, "name", migration.Name)
+		versions = append(versions, migration.Version)
+	}
+
+	return versions, nil
+}
+
+// Status lista todas as migrações conhecidas e se cada uma já foi aplicada
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+// Version devolve a maior versão aplicada, ou zero em um banco sem migrações
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	applied, err := m.prepare(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// Pending lista as versões conhecidas ainda não aplicadas. Ao contrário de
+// Status, apenas lê schema_migrations, sem criá-la nem fazer o baseline, então
+// pode ser chamado a cada verificação de prontidão.
+func (m *Migrator) Pending(ctx context.Context) ([]int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	for version := range applied {
+		if _, ok := m.find(version); !ok {
+			return nil, fmt.Errorf("%w: database is at version %d, which this binary does not know", ErrUnknownVersion, version)
+		}
+	}
+
+	pending := []int{}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration.Version)
+		}
+	}
+
+	return pending, nil
+}
+
+// apply executa o SQL da migração e o registro em schema_migrations na mesma transação
+func (m *Migrator) apply(ctx context.Context, statements string, record func(tx *sql.Tx) error) error {
+	tx, err := m.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+
+	if err := record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// prepare garante a tabela schema_migrations e devolve as versões aplicadas
+func (m *Migrator) prepare(ctx context.Context) (map[int]time.Time, error) {
+	_, err := m.Db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(applied) == 0 {
+		if err := m.baseline(ctx); err != nil {
+			return nil, err
+		}
+		return m.applied(ctx)
+	}
+
+	for version := range applied {
+		if _, ok := m.find(version); !ok {
+			return nil, fmt.Errorf("%w: database is at version %d, which this binary does not know", ErrUnknownVersion, version)
+		}
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := m.Db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt string
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version], _ = time.Parse(time.RFC3339, appliedAt)
+	}
+
+	return applied, rows.Err()
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// baseline registra como aplicadas as migrações que bancos anteriores ao
+// sistema de migrações já possuem: a tabela quotations original (0001) e as
+// colunas provider e fetched_at, que eram adicionadas na inicialização (0002).
+// Só existem bancos assim em SQLite.
+func (m *Migrator) baseline(ctx context.Context) error {
+	if m.Dialect != database.SQLite {
+		return nil
+	}
+
+	columns, err := m.columns(ctx, "quotations")
+	if err != nil || len(columns) == 0 {
+		return err
+	}
+
+	versions := []int{1}
+	if columns["provider"] && columns["fetched_at"] {
+		versions = append(versions, 2)
+	}
+
+	for _, version := range versions {
+		migration, ok := m.find(version)
+		if !ok {
+			return fmt.Errorf("%w: baseline version %d", ErrUnknownVersion, version)
+		}
+
+		_, err := m.Db.ExecContext(ctx,
+			m.Dialect.Rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+			migration.Version, migration.Name, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to baseline existing database: %w", err)
+		}
+		slog.InfoContext(ctx, "Banco existente já possui a migração", "version", migration.Version, "name", migration.Name)
+	}
+
+	return nil
+}
+
+// columns devolve as colunas existentes da tabela; vazio se ela não existir
+func (m *Migrator) columns(ctx context.Context, table string) (map[string]bool, error) {
+	rows, err := m.Db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
@@ -0,0 +1,305 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/database"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	// Every connection to :memory: is a different database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newMigrator(t *testing.T, db *sql.DB) *Migrator {
+	t.Helper()
+
+	migrator, err := NewMigrator(db, database.SQLite)
+	require.NoError(t, err)
+	return migrator
+}
+
+func tableColumns(t *testing.T, db *sql.DB) map[string]bool {
+	t.Helper()
+
+	columns, err := (&Migrator{Db: db}).columns(context.Background(), "quotations")
+	require.NoError(t, err)
+	return columns
+}
+
+func TestLoad(t *testing.T) {
+	sqlite, err := Load(database.SQLite)
+	require.NoError(t, err)
+	postgres, err := Load(database.Postgres)
+	require.NoError(t, err)
+
+	require.NotEmpty(t, sqlite)
+	for i, migration := range sqlite {
+		assert.Equal(t, i+1, migration.Version, "versions are sequential")
+		assert.NotEmpty(t, migration.Name)
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+
+	// Every dialect describes the same versions
+	require.Len(t, postgres, len(sqlite))
+	for i := range sqlite {
+		assert.Equal(t, sqlite[i].Version, postgres[i].Version)
+		assert.Equal(t, sqlite[i].Name, postgres[i].Name)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		expected []int
+		wantErr  bool
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"0002_second.up.sql":   {Data: []byte("SELECT 2")},
+				"0002_second.down.sql": {Data: []byte("SELECT 2")},
+				"0001_first.up.sql":    {Data: []byte("SELECT 1")},
+				"0001_first.down.sql":  {Data: []byte("SELECT 1")},
+			},
+			expected: []int{1, 2},
+		},
+		{
+			name:    "missing down file",
+			files:   fstest.MapFS{"0001_first.up.sql": {Data: []byte("SELECT 1")}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid direction",
+			files:   fstest.MapFS{"0001_first.sideways.sql": {Data: []byte("SELECT 1")}},
+			wantErr: true,
+		},
+		{
+			name:    "missing version",
+			files:   fstest.MapFS{"first.up.sql": {Data: []byte("SELECT 1")}},
+			wantErr: true,
+		},
+		{
+			name: "version reused by another name",
+			files: fstest.MapFS{
+				"0001_first.up.sql":   {Data: []byte("SELECT 1")},
+				"0001_other.down.sql": {Data: []byte("SELECT 1")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := parse(tt.files)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidMigration)
+				return
+			}
+
+			require.NoError(t, err)
+			versions := make([]int, 0, len(migrations))
+			for _, migration := range migrations {
+				versions = append(versions, migration.Version)
+			}
+			assert.Equal(t, tt.expected, versions)
+		})
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := openDatabase(t)
+	migrator := newMigrator(t, db)
+
+	versions, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, versions)
+
+	columns := tableColumns(t, db)
+	assert.True(t, columns["provider"])
+	assert.True(t, columns["fetched_at"])
+	assert.True(t, columns["pair"])
+
+	// Running again is a no-op
+	versions, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, version)
+
+	versions, err = migrator.Down(ctx, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []int{5, 4, 3, 2}, versions)
+
+	columns = tableColumns(t, db)
+	assert.False(t, columns["pair"])
+	assert.False(t, columns["provider"])
+	assert.True(t, columns["code"])
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 5)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[0].AppliedAt.IsZero())
+	for _, status := range statuses[1:] {
+		assert.False(t, status.Applied, "migration %d", status.Version)
+	}
+
+	// Reverting everything drops the table
+	_, err = migrator.Down(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, tableColumns(t, db))
+}
+
+func TestBaselineLegacyDatabase(t *testing.T) {
+	tests := []struct {
+		name     string
+		ddl      string
+		baseline []bool
+	}{
+		{
+			name: "original table",
+			ddl: `CREATE TABLE quotations (id TEXT PRIMARY KEY, code TEXT, codein TEXT, name TEXT,
+				high TEXT, low TEXT, varBid TEXT, pctChange TEXT, bid TEXT, ask TEXT, timestamp TEXT, create_date TEXT)`,
+			baseline: []bool{true, false, false, false, false},
+		},
+		{
+			name: "table with provider and fetched_at",
+			ddl: `CREATE TABLE quotations (id TEXT PRIMARY KEY, code TEXT, codein TEXT, name TEXT,
+				high TEXT, low TEXT, varBid TEXT, pctChange TEXT, bid TEXT, ask TEXT, timestamp TEXT, create_date TEXT,
+				provider TEXT, fetched_at TEXT)`,
+			baseline: []bool{true, true, false, false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := openDatabase(t)
+
+			_, err := db.Exec(tt.ddl)
+			require.NoError(t, err)
+			_, err = db.Exec(`INSERT INTO quotations (id, code, codein) VALUES ('1', 'USD', 'BRL')`)
+			require.NoError(t, err)
+
+			migrator := newMigrator(t, db)
+
+			statuses, err := migrator.Status(ctx)
+			require.NoError(t, err)
+			for i, status := range statuses {
+				assert.Equal(t, tt.baseline[i], status.Applied, "migration %d", status.Version)
+			}
+
+			_, err = migrator.Up(ctx)
+			require.NoError(t, err)
+
+			// Existing rows keep their data and get the pair backfilled
+			var pair string
+			require.NoError(t, db.QueryRow("SELECT pair FROM quotations WHERE id = '1'").Scan(&pair))
+			assert.Equal(t, "USD-BRL", pair)
+		})
+	}
+}
+
+func TestUpTo(t *testing.T) {
+	ctx := context.Background()
+	db := openDatabase(t)
+	migrator := newMigrator(t, db)
+
+	versions, err := migrator.UpTo(ctx, UniqueQuotationsVersion-1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, versions)
+
+	// Repeated quotes written before the unique index, and quotes without a provider timestamp
+	rows := []struct{ id, timestamp, fetchedAt string }{
+		{"1", "1701278942", "2023-11-29 17:55:43"},
+		{"2", "1701278942", "2023-11-29 17:56:13"},
+		{"3", "1701278942", ""},
+		{"4", "", "2023-11-29 17:55:43"},
+		{"5", "", "2023-11-29 17:55:43"},
+	}
+	for _, row := range rows {
+		_, err = db.Exec(`INSERT INTO quotations (id, code, codein, pair, provider, timestamp, fetched_at) VALUES (?, 'USD', 'BRL', 'USD-BRL', 'awesomeapi', ?, NULLIF(?, ''))`, row.id, row.timestamp, row.fetchedAt)
+		require.NoError(t, err)
+	}
+
+	// The migration keeps only the most recently fetched of the repeats, without a manual dedupe
+	versions, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{4, 5}, versions)
+
+	var ids []string
+	result, err := db.Query("SELECT id FROM quotations ORDER BY id")
+	require.NoError(t, err)
+	defer result.Close()
+	for result.Next() {
+		var id string
+		require.NoError(t, result.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.NoError(t, result.Err())
+	assert.Equal(t, []string{"2", "4", "5"}, ids)
+}
+
+func TestPending(t *testing.T) {
+	ctx := context.Background()
+	db := openDatabase(t)
+	migrator := newMigrator(t, db)
+
+	// Without schema_migrations the database has never been migrated
+	_, err := migrator.Pending(ctx)
+	assert.Error(t, err)
+
+	_, err = migrator.UpTo(ctx, 3)
+	require.NoError(t, err)
+
+	pending, err := migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{4, 5}, pending)
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	pending, err = migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	_, err = db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (99, 'future', '2024-01-01T00:00:00Z')")
+	require.NoError(t, err)
+
+	_, err = migrator.Pending(ctx)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+}
+
+func TestUnknownVersion(t *testing.T) {
+	ctx := context.Background()
+	db := openDatabase(t)
+
+	_, err := newMigrator(t, db).Up(ctx)
+	require.NoError(t, err)
+
+	// A database migrated by a newer binary
+	_, err = db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (99, 'future', '2024-01-01T00:00:00Z')")
+	require.NoError(t, err)
+
+	_, err = newMigrator(t, db).Up(ctx)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS quotations (
+	id TEXT PRIMARY KEY,
+	code TEXT,
+	codein TEXT,
+	name TEXT,
+	high NUMERIC,
+	low NUMERIC,
+	varBid NUMERIC,
+	pctChange NUMERIC,
+	bid NUMERIC,
+	ask NUMERIC,
+	timestamp TEXT,
+	create_date TIMESTAMPTZ
+);
@@ -0,0 +1,2 @@
+ALTER TABLE quotations DROP COLUMN fetched_at;
+ALTER TABLE quotations DROP COLUMN provider;
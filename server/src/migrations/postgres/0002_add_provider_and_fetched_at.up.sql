@@ -0,0 +1,2 @@
+ALTER TABLE quotations ADD COLUMN provider TEXT;
+ALTER TABLE quotations ADD COLUMN fetched_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+DROP INDEX idx_quotations_pair_fetched_at;
+DROP INDEX idx_quotations_pair_create_date;
+ALTER TABLE quotations DROP COLUMN pair;
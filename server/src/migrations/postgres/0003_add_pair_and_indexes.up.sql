@@ -0,0 +1,4 @@
+ALTER TABLE quotations ADD COLUMN pair TEXT;
+UPDATE quotations SET pair = code || '-' || codein;
+CREATE INDEX idx_quotations_pair_create_date ON quotations (pair, create_date);
+CREATE INDEX idx_quotations_pair_fetched_at ON quotations (pair, fetched_at);
@@ -0,0 +1 @@
+DROP INDEX idx_quotations_pair_provider_timestamp;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS quotation_rollups (
+	pair TEXT NOT NULL,
+	resolution TEXT NOT NULL,
+	bucket_start TIMESTAMPTZ NOT NULL,
+	open NUMERIC NOT NULL,
+	high NUMERIC NOT NULL,
+	low NUMERIC NOT NULL,
+	close NUMERIC NOT NULL,
+	avg NUMERIC NOT NULL,
+	close_ask NUMERIC NOT NULL,
+	samples INTEGER NOT NULL,
+	PRIMARY KEY (pair, resolution, bucket_start)
+);
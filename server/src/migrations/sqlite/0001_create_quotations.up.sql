@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS quotations (
+	id TEXT PRIMARY KEY,
+	code TEXT,
+	codein TEXT,
+	name TEXT,
+	high TEXT,
+	low TEXT,
+	varBid TEXT,
+	pctChange TEXT,
+	bid TEXT,
+	ask TEXT,
+	timestamp TEXT,
+	create_date TEXT
+);
@@ -0,0 +1,18 @@
+-- Cotações sem provedor (anteriores a 0002) passam a ter provider vazio e as sem
+-- timestamp do provedor ficam com NULL, que nunca conflita no índice único.
+UPDATE quotations SET provider = '' WHERE provider IS NULL;
+UPDATE quotations SET timestamp = NULL WHERE timestamp = '';
+-- Cotações repetidas gravadas antes da regra impediriam o índice; de cada grupo
+-- fica a obtida mais recentemente, como em "server dedupe"
+DELETE FROM quotations WHERE id IN (
+	SELECT id FROM (
+		SELECT id, ROW_NUMBER() OVER (
+			PARTITION BY pair, provider, timestamp
+			ORDER BY fetched_at IS NULL, fetched_at DESC, id DESC
+		) AS position
+		FROM quotations
+		WHERE pair IS NOT NULL AND timestamp IS NOT NULL
+	) ranked
+	WHERE position > 1
+);
+CREATE UNIQUE INDEX idx_quotations_pair_provider_timestamp ON quotations (pair, provider, timestamp);
@@ -0,0 +1 @@
+DROP TABLE quotation_rollups;
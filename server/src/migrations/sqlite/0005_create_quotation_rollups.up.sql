@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS quotation_rollups (
+	pair TEXT NOT NULL,
+	resolution TEXT NOT NULL,
+	bucket_start TEXT NOT NULL,
+	open TEXT NOT NULL,
+	high TEXT NOT NULL,
+	low TEXT NOT NULL,
+	close TEXT NOT NULL,
+	avg TEXT NOT NULL,
+	close_ask TEXT NOT NULL,
+	samples INTEGER NOT NULL,
+	PRIMARY KEY (pair, resolution, bucket_start)
+);
@@ -0,0 +1,322 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/logging"
+)
+
+var (
+	ErrQueueFull     = errors.New("persistence queue full")
+	ErrQueueClosed   = errors.New("persistence queue closed")
+	ErrInvalidPolicy = errors.New("invalid overflow policy")
+)
+
+// Interfaces para dependências
+type BatchRepository interface {
+	// CreateBatch devolve quantas cotações do lote eram repetidas e foram ignoradas
+	CreateBatch(ctx context.Context, quotations []gateways.Quotation) (int, error)
+}
+
+// WriteObserver é notificado de cada tentativa de gravação de um lote
+type WriteObserver interface {
+	ObserveWrite(size int, duration time.Duration, err error)
+}
+
+// OverflowPolicy define o que Enqueue faz quando a fila está cheia
+type OverflowPolicy string
+
+const (
+	// Drop descarta a cotação nova, contando-a em QueueStats.Dropped
+	Drop OverflowPolicy = "drop"
+	// Block espera por espaço na fila, limitado pelo contexto de quem enfileira
+	Block OverflowPolicy = "block"
+)
+
+func ParseOverflowPolicy(value string) (OverflowPolicy, error) {
+	switch OverflowPolicy(strings.ToLower(strings.TrimSpace(value))) {
+	case Drop:
+		return Drop, nil
+	case Block:
+		return Block, nil
+	}
+	return "", fmt.Errorf("%w: %q (expected drop or block)", ErrInvalidPolicy, value)
+}
+
+// QueueStats conta o destino das cotações que passaram pela fila. Duplicates são
+// as cotações repetidas do provedor que o repositório não gravou de novo;
+// Failed, as inválidas e as de lotes que esgotaram as tentativas.
+type QueueStats struct {
+	Enqueued   uint64 `json:"enqueued"`
+	Persisted  uint64 `json:"persisted"`
+	Duplicates uint64 `json:"duplicates"`
+	Dropped    uint64 `json:"dropped"`
+	Failed     uint64 `json:"failed"`
+	Pending    int    `json:"pending"`
+}
+
+// queuedQuotation guarda, com a cotação, a requisição que a enfileirou
+type queuedQuotation struct {
+	quotation gateways.Quotation
+	requestID string
+}
+
+// Queue grava as cotações no banco em segundo plano (write-behind): Enqueue
+// devolve imediatamente e um worker agrupa as cotações em lotes de até
+// BatchSize, gravando quando o lote enche ou a cada FlushInterval. Lotes que
+// falham são repetidos até MaxRetries vezes antes de serem descartados;
+// cotações inválidas (gateways.ErrInvalidQuotation) nunca são repetidas.
+type Queue struct {
+	Capacity      int
+	BatchSize     int
+	FlushInterval time.Duration
+	Policy        OverflowPolicy
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	WriteTimeout  time.Duration
+	// Observer, quando definido, recebe a duração e o resultado de cada
+	// tentativa de gravação de um lote
+	Observer WriteObserver
+
+	repository BatchRepository
+	items      chan queuedQuotation
+	done       chan struct{}
+	startOnce  sync.Once
+
+	// closing é fechado no início de Close, liberando quem espera por espaço
+	// na política Block antes de Close pedir o lock exclusivo
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	mu     sync.RWMutex
+	closed bool
+
+	enqueued   atomic.Uint64
+	persisted  atomic.Uint64
+	duplicates atomic.Uint64
+	dropped    atomic.Uint64
+	failed     atomic.Uint64
+}
+
+func NewQueue(repository BatchRepository) *Queue {
+	return &Queue{
+		Capacity:      1000,
+		BatchSize:     50,
+		FlushInterval: 100 * time.Millisecond,
+		Policy:        Drop,
+		MaxRetries:    3,
+		RetryBackoff:  50 * time.Millisecond,
+		WriteTimeout:  time.Second,
+		repository:    repository,
+		done:          make(chan struct{}),
+		closing:       make(chan struct{}),
+	}
+}
+
+// Start inicia o worker que grava os lotes. A configuração não deve mudar depois disso.
+func (q *Queue) Start() {
+	q.startOnce.Do(func() {
+		q.items = make(chan queuedQuotation, max(q.Capacity, 1))
+		go q.run()
+	})
+}
+
+// Enqueue agenda a gravação da cotação. Cotações inválidas são recusadas com
+// gateways.ErrInvalidQuotation, antes de entrar em um lote. Com a fila cheia devolve ErrQueueFull
+// (política Drop) ou espera por espaço até ctx terminar ou a fila ser fechada
+// (política Block).
+func (q *Queue) Enqueue(ctx context.Context, quotation gateways.Quotation) error {
+	q.Start()
+
+	if err := quotation.Validate(); err != nil {
+		q.failed.Add(1)
+		return err
+	}
+
+	item := queuedQuotation{quotation: quotation, requestID: logging.RequestID(ctx)}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		q.dropped.Add(1)
+		return ErrQueueClosed
+	}
+
+	if q.Policy == Block {
+		select {
+		case q.items <- item:
+			q.enqueued.Add(1)
+			return nil
+		case <-ctx.Done():
+			q.dropped.Add(1)
+			return fmt.Errorf("%w: %w", ErrQueueFull, ctx.Err())
+		case <-q.closing:
+			q.dropped.Add(1)
+			return ErrQueueClosed
+		}
+	}
+
+	select {
+	case q.items <- item:
+		q.enqueued.Add(1)
+		return nil
+	default:
+		q.dropped.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// CreateWithContext enfileira a cotação, permitindo usar a fila onde se espera
+// um repositório (ex.: no poller)
+func (q *Queue) CreateWithContext(ctx context.Context, quotation gateways.Quotation) error {
+	return q.Enqueue(ctx, quotation)
+}
+
+// Close para de aceitar cotações e espera o worker gravar o que ainda está na
+// fila, ou ctx terminar
+func (q *Queue) Close(ctx context.Context) error {
+	q.Start()
+
+	q.closeOnce.Do(func() { close(q.closing) })
+
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.items)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("persistence queue not flushed, %d quotations pending: %w", len(q.items), ctx.Err())
+	}
+}
+
+func (q *Queue) Stats() QueueStats {
+	q.Start()
+
+	return QueueStats{
+		Enqueued:   q.enqueued.Load(),
+		Persisted:  q.persisted.Load(),
+		Duplicates: q.duplicates.Load(),
+		Dropped:    q.dropped.Load(),
+		Failed:     q.failed.Load(),
+		Pending:    len(q.items),
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+
+	interval := q.FlushInterval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batchSize := max(q.BatchSize, 1)
+	batch := make([]gateways.Quotation, 0, batchSize)
+	var requestIDs []string
+
+	flush := func() {
+		if len(batch) > 0 {
+			q.write(batch, requestIDs)
+			batch = make([]gateways.Quotation, 0, batchSize)
+			requestIDs = nil
+		}
+	}
+
+	for {
+		select {
+		case item, ok := <-q.items:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, item.quotation)
+			if item.requestID != "" {
+				requestIDs = append(requestIDs, item.requestID)
+			}
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// write grava o lote, repetindo com espera crescente em caso de falha. Os logs
+// de falha citam as requisições que enfileiraram as cotações do lote.
+func (q *Queue) write(batch []gateways.Quotation, requestIDs []string) {
+	backoff := q.RetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		duplicates, err := q.writeOnce(batch)
+		if err == nil {
+			q.persisted.Add(uint64(len(batch) - duplicates))
+			q.duplicates.Add(uint64(duplicates))
+			return
+		}
+
+		if errors.Is(err, gateways.ErrInvalidQuotation) {
+			q.reject(batch, requestIDs, err)
+			return
+		}
+
+		if attempt >= q.MaxRetries {
+			q.failed.Add(uint64(len(batch)))
+			slog.Error("Lote de cotações descartado após falhas de gravação", "size", len(batch), "attempts", attempt+1, "request_ids", requestIDs, "error", err)
+			return
+		}
+
+		slog.Warn("Falha ao gravar lote de cotações, repetindo", "size", len(batch), "attempt", attempt+1, "backoff", backoff, "request_ids", requestIDs, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// reject trata um lote recusado por conter uma cotação inválida, o que não se
+// resolve repetindo. Como a recusa desfaz o lote inteiro, as cotações são
+// gravadas uma a uma e só as inválidas são descartadas.
+func (q *Queue) reject(batch []gateways.Quotation, requestIDs []string, err error) {
+	if len(batch) == 1 {
+		q.failed.Add(1)
+		slog.Error("Cotação inválida descartada", "pair", batch[0].Pair(), "request_ids", requestIDs, "error", err)
+		return
+	}
+
+	slog.Warn("Lote de cotações recusado, gravando uma a uma", "size", len(batch), "request_ids", requestIDs, "error", err)
+	for _, quotation := range batch {
+		q.write([]gateways.Quotation{quotation}, requestIDs)
+	}
+}
+
+func (q *Queue) writeOnce(batch []gateways.Quotation) (int, error) {
+	ctx := context.Background()
+	if q.WriteTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.WriteTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	duplicates, err := q.repository.CreateBatch(ctx, batch)
+	if q.Observer != nil {
+		q.Observer.ObserveWrite(len(batch), time.Since(start), err)
+	}
+
+	return duplicates, err
+}
@@ -0,0 +1,407 @@
+package persistence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Fake repository that records every batch and can fail the first calls
+type fakeRepository struct {
+	mu       sync.Mutex
+	batches  [][]gateways.Quotation
+	failures int
+	calls    int
+	block    chan struct{}
+	seen     map[string]bool // Provider timestamps already written
+	invalid  map[string]bool // Bids the repository refuses, rejecting the whole batch
+}
+
+func (r *fakeRepository) CreateBatch(ctx context.Context, quotations []gateways.Quotation) (int, error) {
+	if r.block != nil {
+		<-r.block
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls++
+	if r.failures > 0 {
+		r.failures--
+		return 0, errors.New("database is locked")
+	}
+	for _, quotation := range quotations {
+		if r.invalid[quotation.Bid.String()] {
+			return 0, fmt.Errorf("%w: bid %s", gateways.ErrInvalidQuotation, quotation.Bid)
+		}
+	}
+
+	duplicates := 0
+	for _, quotation := range quotations {
+		if quotation.Timestamp == "" {
+			continue
+		}
+		if r.seen[quotation.Timestamp] {
+			duplicates++
+		}
+		if r.seen == nil {
+			r.seen = make(map[string]bool)
+		}
+		r.seen[quotation.Timestamp] = true
+	}
+
+	r.batches = append(r.batches, append([]gateways.Quotation(nil), quotations...))
+	return duplicates, nil
+}
+
+func (r *fakeRepository) batchSizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sizes := make([]int, 0, len(r.batches))
+	for _, batch := range r.batches {
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
+func (r *fakeRepository) callCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func quotation(bid string) gateways.Quotation {
+	return gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse(bid), Ask: decimal.MustParse(bid)}
+}
+
+func newTestQueue(repository BatchRepository) *Queue {
+	queue := NewQueue(repository)
+	queue.FlushInterval = time.Hour // Only full batches and Close flush
+	queue.RetryBackoff = time.Millisecond
+	return queue
+}
+
+func TestQueueBatchesAndFlushesOnClose(t *testing.T) {
+	repository := &fakeRepository{}
+	queue := newTestQueue(repository)
+	queue.BatchSize = 3
+
+	for i := 0; i < 7; i++ {
+		require.NoError(t, queue.Enqueue(context.Background(), quotation("5.8576")))
+	}
+
+	require.NoError(t, queue.Close(context.Background()))
+
+	assert.Equal(t, []int{3, 3, 1}, repository.batchSizes())
+	assert.Equal(t, QueueStats{Enqueued: 7, Persisted: 7}, queue.Stats())
+}
+
+func TestQueueFlushInterval(t *testing.T) {
+	repository := &fakeRepository{}
+	queue := NewQueue(repository)
+	queue.FlushInterval = 10 * time.Millisecond
+	defer queue.Close(context.Background())
+
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("5.8576")))
+
+	// A partial batch is written once the interval elapses
+	assert.Eventually(t, func() bool {
+		return queue.Stats().Persisted == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestQueueCountsDuplicates(t *testing.T) {
+	repository := &fakeRepository{}
+	queue := newTestQueue(repository)
+
+	for _, timestamp := range []string{"1701278942", "1701278942", "1701279002", "1701278942"} {
+		quotation := quotation("5.8576")
+		quotation.Timestamp = timestamp
+		require.NoError(t, queue.Enqueue(context.Background(), quotation))
+	}
+	require.NoError(t, queue.Close(context.Background()))
+
+	assert.Equal(t, QueueStats{Enqueued: 4, Persisted: 2, Duplicates: 2}, queue.Stats())
+}
+
+func TestQueueRetries(t *testing.T) {
+	tests := []struct {
+		name              string
+		failures          int
+		expectedCalls     int
+		expectedPersisted uint64
+		expectedFailed    uint64
+	}{
+		{name: "succeeds after retries", failures: 2, expectedCalls: 3, expectedPersisted: 2},
+		{name: "gives up after max retries", failures: 10, expectedCalls: 4, expectedFailed: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRepository{failures: tt.failures}
+			queue := newTestQueue(repository)
+			queue.MaxRetries = 3
+
+			require.NoError(t, queue.Enqueue(context.Background(), quotation("5.8576")))
+			require.NoError(t, queue.Enqueue(context.Background(), quotation("5.8580")))
+			require.NoError(t, queue.Close(context.Background()))
+
+			stats := queue.Stats()
+			assert.Equal(t, tt.expectedCalls, repository.callCount())
+			assert.Equal(t, tt.expectedPersisted, stats.Persisted)
+			assert.Equal(t, tt.expectedFailed, stats.Failed)
+		})
+	}
+}
+
+func TestQueueRejectsInvalidQuotations(t *testing.T) {
+	repository := &fakeRepository{}
+	queue := newTestQueue(repository)
+
+	// Invalid quotations never reach a batch
+	err := queue.Enqueue(context.Background(), quotation("0"))
+	assert.ErrorIs(t, err, gateways.ErrInvalidQuotation)
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("5.8576")))
+	require.NoError(t, queue.Close(context.Background()))
+
+	assert.Equal(t, []int{1}, repository.batchSizes())
+	assert.Equal(t, QueueStats{Enqueued: 1, Persisted: 1, Failed: 1}, queue.Stats())
+}
+
+func TestQueueDoesNotRetryRejectedBatches(t *testing.T) {
+	repository := &fakeRepository{invalid: map[string]bool{"5.8580": true}}
+	queue := newTestQueue(repository)
+	queue.BatchSize = 3
+	queue.MaxRetries = 3
+
+	for _, bid := range []string{"5.8576", "5.8580", "5.8590"} {
+		require.NoError(t, queue.Enqueue(context.Background(), quotation(bid)))
+	}
+	require.NoError(t, queue.Close(context.Background()))
+
+	// The batch is not retried; written one by one, only the refused quotation is lost
+	assert.Equal(t, 4, repository.callCount())
+	assert.Equal(t, []int{1, 1}, repository.batchSizes())
+	assert.Equal(t, QueueStats{Enqueued: 3, Persisted: 2, Failed: 1}, queue.Stats())
+}
+
+// Observer that records the outcome of every write attempt
+type recordingObserver struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (o *recordingObserver) ObserveWrite(size int, duration time.Duration, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	outcome := "ok"
+	if err != nil {
+		outcome = err.Error()
+	}
+	o.writes = append(o.writes, fmt.Sprintf("%d %s", size, outcome))
+}
+
+func TestQueueObserver(t *testing.T) {
+	repository := &fakeRepository{failures: 1}
+	observer := &recordingObserver{}
+	queue := newTestQueue(repository)
+	queue.Observer = observer
+
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("5.8576")))
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("5.8580")))
+	require.NoError(t, queue.Close(context.Background()))
+
+	// Every attempt is observed, including the failed one that was retried
+	assert.Equal(t, []string{"2 database is locked", "2 ok"}, observer.writes)
+}
+
+func TestQueueLogsRequestIDs(t *testing.T) {
+	var logs bytes.Buffer
+	logger, err := logging.New(&logs, logging.FormatJSON, slog.LevelInfo)
+	require.NoError(t, err)
+
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	repository := &fakeRepository{failures: 1}
+	queue := newTestQueue(repository)
+
+	require.NoError(t, queue.Enqueue(logging.WithRequestID(context.Background(), "req-1"), quotation("5.8576")))
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("5.8578"))) // e.g. the poller
+	require.NoError(t, queue.Enqueue(logging.WithRequestID(context.Background(), "req-2"), quotation("5.8580")))
+	require.NoError(t, queue.Close(context.Background()))
+
+	// The retried batch names the requests that enqueued its quotations
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &record))
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, []any{"req-1", "req-2"}, record["request_ids"])
+}
+
+func TestQueueDropPolicy(t *testing.T) {
+	// The worker is stuck writing, so the queue fills up
+	repository := &fakeRepository{block: make(chan struct{})}
+	queue := newTestQueue(repository)
+	queue.Capacity = 2
+	queue.BatchSize = 1
+
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("1")))
+	assert.Eventually(t, func() bool { return queue.Stats().Pending == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("2")))
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("3")))
+
+	err := queue.Enqueue(context.Background(), quotation("4"))
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	close(repository.block)
+	require.NoError(t, queue.Close(context.Background()))
+
+	stats := queue.Stats()
+	assert.Equal(t, uint64(3), stats.Enqueued)
+	assert.Equal(t, uint64(3), stats.Persisted)
+	assert.Equal(t, uint64(1), stats.Dropped)
+}
+
+func TestQueueBlockPolicy(t *testing.T) {
+	repository := &fakeRepository{block: make(chan struct{})}
+	queue := newTestQueue(repository)
+	queue.Capacity = 1
+	queue.BatchSize = 1
+	queue.Policy = Block
+
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("1")))
+	assert.Eventually(t, func() bool { return queue.Stats().Pending == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("2")))
+
+	// A full queue waits up to the caller's deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := queue.Enqueue(ctx, quotation("3"))
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Once the worker makes progress, a blocked caller gets in
+	enqueued := make(chan error, 1)
+	go func() {
+		enqueued <- queue.Enqueue(context.Background(), quotation("4"))
+	}()
+	close(repository.block)
+	require.NoError(t, <-enqueued)
+
+	require.NoError(t, queue.Close(context.Background()))
+	assert.Equal(t, uint64(3), queue.Stats().Persisted)
+	assert.Equal(t, uint64(1), queue.Stats().Dropped)
+}
+
+func TestQueueCloseReleasesBlockedProducer(t *testing.T) {
+	repository := &fakeRepository{block: make(chan struct{})}
+	queue := newTestQueue(repository)
+	queue.Capacity = 1
+	queue.BatchSize = 1
+	queue.Policy = Block
+
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("1")))
+	assert.Eventually(t, func() bool { return queue.Stats().Pending == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("2")))
+
+	// The producer has no deadline and waits for space that never frees up
+	enqueued := make(chan error, 1)
+	go func() {
+		enqueued <- queue.Enqueue(context.Background(), quotation("3"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- queue.Close(ctx)
+	}()
+
+	select {
+	case err := <-enqueued:
+		assert.ErrorIs(t, err, ErrQueueClosed)
+	case <-time.After(time.Second):
+		t.Fatal("blocked producer was not released by Close")
+	}
+
+	select {
+	case err := <-closed:
+		// The worker is still stuck writing, so Close gives up at its deadline
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	close(repository.block)
+	require.NoError(t, queue.Close(context.Background()))
+	assert.Equal(t, uint64(2), queue.Stats().Persisted)
+	assert.Equal(t, uint64(1), queue.Stats().Dropped)
+}
+
+func TestQueueClosed(t *testing.T) {
+	queue := newTestQueue(&fakeRepository{})
+	require.NoError(t, queue.Close(context.Background()))
+
+	err := queue.Enqueue(context.Background(), quotation("5.8576"))
+	assert.ErrorIs(t, err, ErrQueueClosed)
+	assert.NoError(t, queue.Close(context.Background()), "closing twice is harmless")
+}
+
+func TestQueueCloseDeadline(t *testing.T) {
+	repository := &fakeRepository{block: make(chan struct{})}
+	defer close(repository.block)
+
+	queue := newTestQueue(repository)
+	queue.BatchSize = 1
+	require.NoError(t, queue.Enqueue(context.Background(), quotation("5.8576")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := queue.Close(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestParseOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected OverflowPolicy
+		wantErr  bool
+	}{
+		{value: "drop", expected: Drop},
+		{value: " BLOCK ", expected: Block},
+		{value: "discard", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			policy, err := ParseOverflowPolicy(tt.value)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidPolicy)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, policy)
+		})
+	}
+}
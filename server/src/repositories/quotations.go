@@ -3,18 +3,96 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/aggregations"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/database"
 	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
 	"github.com/google/uuid"
 )
 
+var (
+	ErrQuotationNotFound = errors.New("quotation not found")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+)
+
+const (
+	DefaultHistoryLimit = 100
+	MaxHistoryLimit     = 1000
+)
+
+const quotationColumns = "code, codein, name, high, low, varBid, pctChange, bid, ask, timestamp, create_date, provider, fetched_at"
+
+// HistoryFilter delimita a consulta ao histórico de um par. From e To são
+// opcionais e comparados com create_date; Cursor continua uma página anterior.
+type HistoryFilter struct {
+	Pair   gateways.Pair
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Cursor string
+	// RawOnly deixa de fora os resumos dos períodos compactados
+	RawOnly bool
+}
+
+type HistoryPage struct {
+	Quotations []gateways.Quotation
+	NextCursor string
+}
+
+// Formatos usados pelo driver do SQLite ao gravar valores time.Time em colunas
+// TEXT; o Postgres devolve time.Time, lido como RFC 3339
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+}
+
+// Repository é o contrato comum aos bancos suportados, verificado pela mesma
+// suíte de testes em cada um deles
+type Repository interface {
+	Create(quotation gateways.Quotation) error
+	CreateWithContext(ctx context.Context, quotation gateways.Quotation) error
+	CreateBatch(ctx context.Context, quotations []gateways.Quotation) (int, error)
+	FindLatest(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error)
+	FindHistory(ctx context.Context, filter HistoryFilter) (HistoryPage, error)
+	CountDuplicates(ctx context.Context) (int, error)
+	RemoveDuplicates(ctx context.Context) (int, error)
+	Compact(ctx context.Context, cutoff time.Time) (CompactionResult, error)
+	FindRollups(ctx context.Context, pair gateways.Pair, resolution aggregations.Interval, from, to time.Time) ([]aggregations.Rollup, error)
+}
+
+var _ Repository = (*QuotationsRepository)(nil)
+
+// QuotationsRepository implementa Repository sobre database/sql. As consultas
+// são escritas com placeholders "?" e adaptadas ao Dialect; as diferenças de
+// tipos entre os bancos ficam nas migrações de cada um.
 type QuotationsRepository struct {
-	Db *sql.DB
+	Db      *sql.DB
+	Dialect database.Dialect
 }
 
+// NewQuotationsRepository cria o repositório para um banco SQLite
 func NewQuotationsRepository(db *sql.DB) *QuotationsRepository {
-	return &QuotationsRepository{Db: db}
+	return NewRepository(db, database.SQLite)
+}
+
+// NewPostgresRepository cria o repositório para um banco Postgres
+func NewPostgresRepository(db *sql.DB) *QuotationsRepository {
+	return NewRepository(db, database.Postgres)
+}
+
+// NewRepository cria o repositório para o dialeto informado (ex.: o devolvido por database.Open)
+func NewRepository(db *sql.DB, dialect database.Dialect) *QuotationsRepository {
+	return &QuotationsRepository{Db: db, Dialect: dialect}
 }
 
 func (r *QuotationsRepository) Create(quotation gateways.Quotation) error {
@@ -22,30 +100,122 @@ func (r *QuotationsRepository) Create(quotation gateways.Quotation) error {
 	return r.CreateWithContext(context.Background(), quotation)
 }
 
+// insertQuotationQuery grava a cotação uma única vez por (pair, provider,
+// timestamp): repetir a mesma cotação do provedor só atualiza fetched_at, e o id
+// devolvido é o da linha existente
+const insertQuotationQuery = `
+	INSERT INTO quotations (
+	id,
+	code,
+	codein,
+	name,
+	high,
+	low,
+	varBid,
+	pctChange,
+	bid,
+	ask,
+	timestamp,
+	create_date,
+	provider,
+	fetched_at,
+	pair)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (pair, provider, timestamp) DO UPDATE
+	SET fetched_at = excluded.fetched_at
+	WHERE quotations.fetched_at IS NULL OR excluded.fetched_at > quotations.fetched_at
+	RETURNING id
+`
+
+// CreateWithContext grava a cotação; uma cotação repetida do provedor não cria
+// uma nova linha
 func (r *QuotationsRepository) CreateWithContext(ctx context.Context, quotation gateways.Quotation) error {
-	query := `
-		INSERT INTO quotations (
-		id,
-		code,
-		codein,
-		name,
-		high,
-		low,
-		varBid,
-		pctChange,
-		bid,
-		ask,
-		timestamp,
-		create_date)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
+	start := time.Now()
+	inserted, err := upsert(func(args ...any) *sql.Row {
+		return r.Db.QueryRowContext(ctx, r.Dialect.Rebind(insertQuotationQuery), args...)
+	}, quotation)
+	if err != nil {
+		return fmt.Errorf("falha ao inserir cotação: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Cotação gravada", "pair", quotation.Pair(), "provider", quotation.Provider, "duplicate", !inserted, "duration", time.Since(start))
+	return nil
+}
+
+// CreateBatch grava as cotações em uma única transação, ou todas ou nenhuma, e
+// devolve quantas eram repetidas e foram ignoradas. Um lote com uma cotação
+// inválida é recusado inteiro com gateways.ErrInvalidQuotation, antes de abrir
+// a transação.
+func (r *QuotationsRepository) CreateBatch(ctx context.Context, quotations []gateways.Quotation) (int, error) {
+	start := time.Now()
+
+	for _, quotation := range quotations {
+		if err := quotation.Validate(); err != nil {
+			return 0, fmt.Errorf("falha ao inserir cotação: %w", err)
+		}
+	}
+
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, r.Dialect.Rebind(insertQuotationQuery))
+	if err != nil {
+		return 0, fmt.Errorf("falha ao preparar inserção: %w", err)
+	}
+	defer stmt.Close()
+
+	duplicates := 0
+	for _, quotation := range quotations {
+		inserted, err := upsert(func(args ...any) *sql.Row {
+			return stmt.QueryRowContext(ctx, args...)
+		}, quotation)
+		if err != nil {
+			return 0, fmt.Errorf("falha ao inserir cotação: %w", err)
+		}
+		if !inserted {
+			duplicates++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("falha ao confirmar transação: %w", err)
+	}
+
+	slog.DebugContext(ctx, "Lote de cotações gravado", "size", len(quotations), "duplicates", duplicates, "duration", time.Since(start))
+	return duplicates, nil
+}
 
-	id := uuid.New().String()
+// upsert executa insertQuotationQuery e informa se a cotação criou uma linha
+// nova. Quando ela é repetida, RETURNING devolve o id da linha existente ou,
+// se fetched_at não mudou, nenhuma linha.
+func upsert(query func(args ...any) *sql.Row, quotation gateways.Quotation) (bool, error) {
+	args := insertArgs(quotation)
 
-	_, err := r.Db.ExecContext(
-		ctx,
-		query,
-		id,
+	var id string
+	err := query(args...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return id == args[0], nil
+}
+
+// insertArgs monta os valores de insertQuotationQuery, gerando o id e usando o
+// horário atual quando a cotação não informa fetched_at
+func insertArgs(quotation gateways.Quotation) []any {
+	fetchedAt := quotation.FetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = time.Now()
+	}
+
+	return []any{
+		uuid.New().String(),
 		quotation.Code,
 		quotation.Codein,
 		quotation.Name,
@@ -55,12 +225,355 @@ func (r *QuotationsRepository) CreateWithContext(ctx context.Context, quotation
 		quotation.PctChange,
 		quotation.Bid,
 		quotation.Ask,
-		quotation.Timestamp,
+		nullIfEmpty(quotation.Timestamp),
 		quotation.CreateDate,
+		quotation.Provider,
+		fetchedAt.UTC(),
+		quotation.Pair().String(),
+	}
+}
+
+// Sem o timestamp do provedor não há como saber se a cotação é repetida; NULL
+// nunca conflita no índice único
+func nullIfEmpty(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// duplicatesQuery lista as cotações repetidas, mantendo em cada grupo de
+// (pair, provider, timestamp) a obtida mais recentemente
+const duplicatesQuery = `
+	SELECT id FROM (
+		SELECT id, ROW_NUMBER() OVER (
+			PARTITION BY pair, COALESCE(provider, ''), timestamp
+			ORDER BY fetched_at IS NULL, fetched_at DESC, id DESC
+		) AS position
+		FROM quotations
+		WHERE timestamp IS NOT NULL AND timestamp <> ''
+	) ranked
+	WHERE position > 1
+`
+
+// CountDuplicates conta as cotações que RemoveDuplicates apagaria
+func (r *QuotationsRepository) CountDuplicates(ctx context.Context) (int, error) {
+	var count int
+	err := r.Db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+duplicatesQuery+") duplicates").Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("falha ao contar cotações repetidas: %w", err)
+	}
+
+	return count, nil
+}
+
+// RemoveDuplicates apaga as cotações repetidas gravadas antes da regra de
+// unicidade e devolve quantas foram apagadas
+func (r *QuotationsRepository) RemoveDuplicates(ctx context.Context) (int, error) {
+	result, err := r.Db.ExecContext(ctx, "DELETE FROM quotations WHERE id IN ("+duplicatesQuery+")")
+	if err != nil {
+		return 0, fmt.Errorf("falha ao apagar cotações repetidas: %w", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("falha ao apagar cotações repetidas: %w", err)
+	}
+
+	return int(removed), nil
+}
+
+// FindLatest retorna a cotação mais recente obtida para o par
+func (r *QuotationsRepository) FindLatest(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error) {
+	query := `
+		SELECT ` + quotationColumns + `
+		FROM quotations
+		WHERE pair = ? AND fetched_at IS NOT NULL
+		ORDER BY fetched_at DESC
+		LIMIT 1
+	`
+
+	row := r.Db.QueryRowContext(ctx, r.Dialect.Rebind(query), pair.String())
+
+	quotation, err := scanQuotation(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.DebugContext(ctx, "Nenhuma cotação armazenada", "pair", pair)
+		return gateways.Quotation{}, fmt.Errorf("%w: %s", ErrQuotationNotFound, pair)
+	}
+	if err != nil {
+		return gateways.Quotation{}, fmt.Errorf("falha ao buscar cotação: %w", err)
+	}
+
+	return quotation, nil
+}
+
+// FindHistory lista as cotações do par em ordem cronológica de create_date,
+// paginando por cursor. Períodos já compactados aparecem como os resumos por
+// hora (ver aggregations.Rollup.Quotation), salvo com RawOnly.
+func (r *QuotationsRepository) FindHistory(ctx context.Context, filter HistoryFilter) (HistoryPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	limit = min(limit, MaxHistoryLimit)
+
+	var cursor *historyCursor
+	if filter.Cursor != "" {
+		createDate, id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return HistoryPage{}, err
+		}
+		cursor = &historyCursor{createDate: createDate, id: id}
+	}
+
+	// Busca um registro a mais de cada origem para saber se existe uma próxima página
+	entries, err := r.rawHistory(ctx, filter, cursor, limit+1)
+	if err != nil {
+		return HistoryPage{}, err
+	}
+
+	if !filter.RawOnly {
+		rollups, err := r.rollupHistory(ctx, filter, cursor, limit+1)
+		if err != nil {
+			return HistoryPage{}, err
+		}
+		entries = mergeHistory(entries, rollups)
+	}
+
+	page := HistoryPage{Quotations: []gateways.Quotation{}}
+	for i, entry := range entries {
+		if i == limit {
+			last := entries[i-1]
+			page.NextCursor = encodeCursor(last.rawCreateDate, last.id)
+			break
+		}
+		page.Quotations = append(page.Quotations, entry.quotation)
+	}
+
+	return page, nil
+}
+
+type historyCursor struct {
+	createDate string
+	id         string
+}
+
+// historyEntry guarda, junto da cotação, o create_date bruto e o id usados no cursor
+type historyEntry struct {
+	id            string
+	rawCreateDate string
+	quotation     gateways.Quotation
+}
+
+// historyConditions monta as condições comuns às duas origens do histórico
+func historyConditions(filter HistoryFilter, cursor *historyCursor, dateColumn, idColumn string) ([]string, []any) {
+	conditions := []string{"pair = ?"}
+	args := []any{filter.Pair.String()}
+
+	if !filter.From.IsZero() {
+		conditions = append(conditions, dateColumn+" >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, dateColumn+" < ?")
+		args = append(args, filter.To.UTC())
+	}
+	if cursor != nil {
+		conditions = append(conditions, "("+dateColumn+" > ? OR ("+dateColumn+" = ? AND "+idColumn+" > ?))")
+		args = append(args, cursor.createDate, cursor.createDate, cursor.id)
+	}
+
+	return conditions, args
+}
+
+func (r *QuotationsRepository) rawHistory(ctx context.Context, filter HistoryFilter, cursor *historyCursor, limit int) ([]historyEntry, error) {
+	conditions, args := historyConditions(filter, cursor, "create_date", "id")
+
+	query := `
+		SELECT id, CAST(create_date AS TEXT), ` + quotationColumns + `
+		FROM quotations
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY create_date ASC, id ASC
+		LIMIT ?
+	`
+	args = append(args, limit)
+
+	rows, err := r.Db.QueryContext(ctx, r.Dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar histórico: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []historyEntry
+	for rows.Next() {
+		var entry historyEntry
+
+		entry.quotation, err = scanQuotation(rows, &entry.id, &entry.rawCreateDate)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler histórico: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("falha ao ler histórico: %w", err)
+	}
+
+	return entries, nil
+}
+
+// rollupHistory lê os resumos por hora como entradas do histórico. O id de um
+// resumo é o nome da sua origem, que no mesmo create_date ordena depois dos
+// UUIDs das cotações brutas.
+func (r *QuotationsRepository) rollupHistory(ctx context.Context, filter HistoryFilter, cursor *historyCursor, limit int) ([]historyEntry, error) {
+	id := aggregations.RollupProvider(aggregations.Hour)
+	conditions, args := historyConditions(filter, nil, "bucket_start", "")
+	if cursor != nil {
+		// Todos os resumos têm o mesmo id, então o desempate é decidido aqui
+		if id > cursor.id {
+			conditions = append(conditions, "bucket_start >= ?")
+		} else {
+			conditions = append(conditions, "bucket_start > ?")
+		}
+		args = append(args, cursor.createDate)
+	}
+	conditions = append(conditions, "resolution = ?")
+	args = append(args, string(aggregations.Hour))
+
+	query := `
+		SELECT CAST(bucket_start AS TEXT), ` + rollupColumns + `
+		FROM quotation_rollups
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY bucket_start ASC
+		LIMIT ?
+	`
+	args = append(args, limit)
+
+	rows, err := r.Db.QueryContext(ctx, r.Dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar histórico resumido: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []historyEntry
+	for rows.Next() {
+		entry := historyEntry{id: id}
+
+		rollup, err := scanRollup(rows, &entry.rawCreateDate)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler histórico resumido: %w", err)
+		}
+		entry.quotation = rollup.Quotation()
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("falha ao ler histórico resumido: %w", err)
+	}
+
+	return entries, nil
+}
+
+// mergeHistory intercala duas listas já ordenadas por (create_date, id)
+func mergeHistory(a, b []historyEntry) []historyEntry {
+	merged := make([]historyEntry, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		first, second := a[0].quotation.CreateDate, b[0].quotation.CreateDate
+		if first.Before(second) || (first.Equal(second) && a[0].id <= b[0].id) {
+			merged = append(merged, a[0])
+			a = a[1:]
+		} else {
+			merged = append(merged, b[0])
+			b = b[1:]
+		}
+	}
+
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
+// O cursor guarda o create_date bruto e o id do último registro da página
+func encodeCursor(createDate, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createDate + "|" + id))
+}
+
+func decodeCursor(cursor string) (string, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	createDate, id, ok := strings.Cut(string(raw), "|")
+	if !ok || createDate == "" || id == "" {
+		return "", "", fmt.Errorf("%w: malformed", ErrInvalidCursor)
+	}
+
+	return createDate, id, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanQuotation lê as colunas de quotationColumns, precedidas dos destinos em prefix
+func scanQuotation(row scanner, prefix ...any) (gateways.Quotation, error) {
+	var (
+		quotation  gateways.Quotation
+		name       sql.NullString
+		timestamp  sql.NullString
+		provider   sql.NullString
+		createDate sql.NullString
+		fetchedAt  sql.NullString
 	)
+
+	dest := append(prefix,
+		&quotation.Code,
+		&quotation.Codein,
+		&name,
+		&quotation.High,
+		&quotation.Low,
+		&quotation.VarBid,
+		&quotation.PctChange,
+		&quotation.Bid,
+		&quotation.Ask,
+		&timestamp,
+		&createDate,
+		&provider,
+		&fetchedAt,
+	)
+
+	err := row.Scan(dest...)
 	if err != nil {
-		return fmt.Errorf("falha ao inserir cotação: %w", err)
+		return gateways.Quotation{}, err
 	}
 
-	return nil
+	quotation.Name = name.String
+	quotation.Timestamp = timestamp.String
+	quotation.Provider = provider.String
+
+	quotation.CreateDate, err = parseTime(createDate)
+	if err != nil {
+		return gateways.Quotation{}, err
+	}
+
+	quotation.FetchedAt, err = parseTime(fetchedAt)
+	if err != nil {
+		return gateways.Quotation{}, err
+	}
+
+	return quotation, nil
+}
+
+func parseTime(value sql.NullString) (time.Time, error) {
+	if !value.Valid || value.String == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range timeLayouts {
+		parsed, err := time.Parse(layout, value.String)
+		if err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("formato de data inválido: %q", value.String)
 }
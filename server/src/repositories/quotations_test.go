@@ -3,46 +3,46 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"os"
 	"testing"
 	"time"
 
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/database"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
 	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/migrations"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
+// RepositoryTestSuite is the behaviour contract every Repository backend must satisfy
 type RepositoryTestSuite struct {
 	suite.Suite
+	dsn        string
 	db         *sql.DB
-	repository *QuotationsRepository
+	repository Repository
 }
 
 func (suite *RepositoryTestSuite) SetupTest() {
-	// Create an in-memory SQLite database for testing
-	db, err := sql.Open("sqlite3", ":memory:")
-	require.NoError(suite.T(), err)
-
-	// Create the table
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS quotations (
-		id TEXT PRIMARY KEY,
-		code TEXT,
-		codein TEXT,
-		name TEXT,
-		high TEXT,
-		low TEXT,
-		varBid TEXT,
-		pctChange TEXT,
-		bid TEXT,
-		ask TEXT,
-		timestamp TEXT,
-		create_date TEXT
-	)`)
+	db, dialect, err := database.Open(suite.dsn)
 	require.NoError(suite.T(), err)
 
+	// Create the schema through the embedded migrations
+	migrator, err := migrations.NewMigrator(db, dialect)
+	require.NoError(suite.T(), err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(suite.T(), err)
+
+	// Unlike an in-memory SQLite database, a Postgres database outlives the test
+	for _, table := range []string{"quotations", "quotation_rollups"} {
+		_, err = db.Exec("DELETE FROM " + table)
+		require.NoError(suite.T(), err)
+	}
+
 	suite.db = db
-	suite.repository = NewQuotationsRepository(db)
+	suite.repository = NewRepository(db, dialect)
 }
 
 func (suite *RepositoryTestSuite) TearDownTest() {
@@ -53,19 +53,18 @@ func (suite *RepositoryTestSuite) TestCreate() {
 	// Create a test quotation
 	createDate, _ := time.Parse("2006-01-02 15:04:05", "2023-11-29 17:55:42")
 	quotation := gateways.Quotation{
-		USDBRL: gateways.USDBRL{
-			Code:       "USD",
-			Codein:     "BRL",
-			Name:       "Dólar Americano/Real Brasileiro",
-			High:       "5.8688",
-			Low:        "5.8213",
-			VarBid:     "0.0313",
-			PctChange:  "0.54",
-			Bid:        "5.8576",
-			Ask:        "5.8582",
-			Timestamp:  "1701278942",
-			CreateDate: createDate,
-		},
+		Code:       "USD",
+		Codein:     "BRL",
+		Name:       "Dólar Americano/Real Brasileiro",
+		High:       decimal.MustParse("5.8688"),
+		Low:        decimal.MustParse("5.8213"),
+		VarBid:     decimal.MustParse("0.0313"),
+		PctChange:  decimal.MustParse("0.54"),
+		Bid:        decimal.MustParse("5.8576"),
+		Ask:        decimal.MustParse("5.8582"),
+		Timestamp:  "1701278942",
+		CreateDate: createDate,
+		Provider:   "awesomeapi",
 	}
 
 	// Test the Create method
@@ -83,25 +82,29 @@ func (suite *RepositoryTestSuite) TestCreate() {
 	err = suite.db.QueryRow("SELECT bid FROM quotations").Scan(&savedBid)
 	assert.NoError(suite.T(), err)
 	assert.Equal(suite.T(), "5.8576", savedBid)
+
+	// Verify which provider served the quotation
+	var savedProvider string
+	err = suite.db.QueryRow("SELECT provider FROM quotations").Scan(&savedProvider)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "awesomeapi", savedProvider)
 }
 
 func (suite *RepositoryTestSuite) TestCreateWithContextTimeout() {
 	// Create a test quotation
 	createDate, _ := time.Parse("2006-01-02 15:04:05", "2023-11-29 17:55:42")
 	quotation := gateways.Quotation{
-		USDBRL: gateways.USDBRL{
-			Code:       "USD",
-			Codein:     "BRL",
-			Name:       "Dólar Americano/Real Brasileiro",
-			High:       "5.8688",
-			Low:        "5.8213",
-			VarBid:     "0.0313",
-			PctChange:  "0.54",
-			Bid:        "5.8576",
-			Ask:        "5.8582",
-			Timestamp:  "1701278942",
-			CreateDate: createDate,
-		},
+		Code:       "USD",
+		Codein:     "BRL",
+		Name:       "Dólar Americano/Real Brasileiro",
+		High:       decimal.MustParse("5.8688"),
+		Low:        decimal.MustParse("5.8213"),
+		VarBid:     decimal.MustParse("0.0313"),
+		PctChange:  decimal.MustParse("0.54"),
+		Bid:        decimal.MustParse("5.8576"),
+		Ask:        decimal.MustParse("5.8582"),
+		Timestamp:  "1701278942",
+		CreateDate: createDate,
 	}
 
 	// Create a context with a very short timeout
@@ -117,7 +120,317 @@ func (suite *RepositoryTestSuite) TestCreateWithContextTimeout() {
 	assert.Contains(suite.T(), err.Error(), "context deadline exceeded")
 }
 
-// Run the test suite
-func TestRepositorySuite(t *testing.T) {
-	suite.Run(t, new(RepositoryTestSuite))
+func (suite *RepositoryTestSuite) TestCreateWithContextCanceled() {
+	quotation := gateways.Quotation{
+		Code:   "USD",
+		Codein: "BRL",
+		Bid:    decimal.MustParse("5.8576"),
+		Ask:    decimal.MustParse("5.8582"),
+	}
+
+	// A caller that already gave up must not get its quotation inserted
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := suite.repository.CreateWithContext(ctx, quotation)
+	assert.ErrorIs(suite.T(), err, context.Canceled)
+
+	var count int
+	err = suite.db.QueryRow("SELECT COUNT(*) FROM quotations").Scan(&count)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+
+	_, err = suite.repository.FindLatest(ctx, gateways.DefaultPair)
+	assert.ErrorIs(suite.T(), err, context.Canceled)
+}
+
+func (suite *RepositoryTestSuite) TestCreateBatch() {
+	quotations := []gateways.Quotation{
+		{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("5.8582"), Provider: "awesomeapi"},
+		{Code: "EUR", Codein: "BRL", Bid: decimal.MustParse("6.3894"), Ask: decimal.MustParse("6.3922"), Provider: "awesomeapi"},
+		{Code: "GBP", Codein: "BRL", Bid: decimal.MustParse("7.4010"), Ask: decimal.MustParse("7.4105"), Provider: "frankfurter"},
+	}
+
+	duplicates, err := suite.repository.CreateBatch(context.Background(), quotations)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, duplicates)
+
+	rows, err := suite.db.Query("SELECT code, bid, provider FROM quotations ORDER BY code")
+	require.NoError(suite.T(), err)
+	defer rows.Close()
+
+	var saved []string
+	for rows.Next() {
+		var code, bid, provider string
+		require.NoError(suite.T(), rows.Scan(&code, &bid, &provider))
+		saved = append(saved, code+" "+bid+" "+provider)
+	}
+	require.NoError(suite.T(), rows.Err())
+	assert.Equal(suite.T(), []string{"EUR 6.3894 awesomeapi", "GBP 7.4010 frankfurter", "USD 5.8576 awesomeapi"}, saved)
+}
+
+func (suite *RepositoryTestSuite) TestCreateBatchCanceled() {
+	quotations := []gateways.Quotation{
+		{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("5.8582")},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := suite.repository.CreateBatch(ctx, quotations)
+	assert.ErrorIs(suite.T(), err, context.Canceled)
+
+	var count int
+	require.NoError(suite.T(), suite.db.QueryRow("SELECT COUNT(*) FROM quotations").Scan(&count))
+	assert.Equal(suite.T(), 0, count)
+}
+
+func (suite *RepositoryTestSuite) TestCreateBatchRejectsInvalidQuotations() {
+	quotations := []gateways.Quotation{
+		{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("5.8582")},
+		{Code: "EUR", Codein: "BRL", Bid: decimal.MustParse("6.3894")},
+	}
+
+	_, err := suite.repository.CreateBatch(context.Background(), quotations)
+	assert.ErrorIs(suite.T(), err, gateways.ErrInvalidQuotation)
+
+	var count int
+	require.NoError(suite.T(), suite.db.QueryRow("SELECT COUNT(*) FROM quotations").Scan(&count))
+	assert.Equal(suite.T(), 0, count)
+}
+
+func (suite *RepositoryTestSuite) TestCreateKeepsExactPrices() {
+	quotation := gateways.Quotation{
+		Code:      "USD",
+		Codein:    "BRL",
+		High:      decimal.MustParse("5.8700"),
+		Low:       decimal.MustParse("-0.000000000000000000012345"),
+		Bid:       decimal.MustParse("5.8500"),
+		Ask:       decimal.MustParse("123456789012345678901234.56789012345678900"),
+		Timestamp: "1701278942",
+		Provider:  "awesomeapi",
+		FetchedAt: time.Date(2023, 11, 29, 17, 55, 43, 0, time.UTC),
+	}
+	require.NoError(suite.T(), suite.repository.Create(quotation))
+
+	// Trailing zeros and digits beyond float precision come back unchanged
+	latest, err := suite.repository.FindLatest(context.Background(), "USD-BRL")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "5.8700", latest.High.String())
+	assert.Equal(suite.T(), "-0.000000000000000000012345", latest.Low.String())
+	assert.Equal(suite.T(), "5.8500", latest.Bid.String())
+	assert.Equal(suite.T(), "123456789012345678901234.56789012345678900", latest.Ask.String())
+}
+
+func (suite *RepositoryTestSuite) TestCreateSkipsDuplicates() {
+	fetchedAt := time.Date(2023, 11, 29, 17, 55, 43, 0, time.UTC)
+	quotation := gateways.Quotation{
+		Code:      "USD",
+		Codein:    "BRL",
+		Bid:       decimal.MustParse("5.8576"),
+		Ask:       decimal.MustParse("5.8582"),
+		Timestamp: "1701278942",
+		Provider:  "awesomeapi",
+		FetchedAt: fetchedAt,
+	}
+
+	require.NoError(suite.T(), suite.repository.Create(quotation))
+
+	// The provider returns the same quote again
+	quotation.FetchedAt = fetchedAt.Add(30 * time.Second)
+	require.NoError(suite.T(), suite.repository.Create(quotation))
+
+	// The same timestamp from another provider is a different quote
+	other := quotation
+	other.Provider = "frankfurter"
+	require.NoError(suite.T(), suite.repository.Create(other))
+
+	var count int
+	require.NoError(suite.T(), suite.db.QueryRow("SELECT COUNT(*) FROM quotations").Scan(&count))
+	assert.Equal(suite.T(), 2, count)
+
+	// The repeated quote refreshes fetched_at, so it still counts as fresh
+	latest, err := suite.repository.FindLatest(context.Background(), "USD-BRL")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), fetchedAt.Add(30*time.Second), latest.FetchedAt)
+
+	// Quotes without a provider timestamp cannot be told apart and are all kept
+	for i := 0; i < 2; i++ {
+		require.NoError(suite.T(), suite.repository.Create(gateways.Quotation{Code: "EUR", Codein: "BRL", Bid: decimal.MustParse("6.3894"), Ask: decimal.MustParse("6.3922"), Provider: "awesomeapi"}))
+	}
+	require.NoError(suite.T(), suite.db.QueryRow("SELECT COUNT(*) FROM quotations WHERE pair = 'EUR-BRL'").Scan(&count))
+	assert.Equal(suite.T(), 2, count)
+}
+
+func (suite *RepositoryTestSuite) TestCreateBatchReportsDuplicates() {
+	quotation := gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("5.8582"), Timestamp: "1701278942", Provider: "awesomeapi"}
+	require.NoError(suite.T(), suite.repository.Create(quotation))
+
+	later := quotation
+	later.Timestamp = "1701279002"
+
+	duplicates, err := suite.repository.CreateBatch(context.Background(), []gateways.Quotation{quotation, later, later})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, duplicates)
+
+	var count int
+	require.NoError(suite.T(), suite.db.QueryRow("SELECT COUNT(*) FROM quotations").Scan(&count))
+	assert.Equal(suite.T(), 2, count)
+}
+
+func (suite *RepositoryTestSuite) TestRemoveDuplicates() {
+	// Databases written before the uniqueness rule may hold repeated quotes
+	_, err := suite.db.Exec("DROP INDEX idx_quotations_pair_provider_timestamp")
+	require.NoError(suite.T(), err)
+
+	rows := []struct {
+		id        string
+		provider  any
+		timestamp string
+		fetchedAt any
+	}{
+		{id: "a", provider: "awesomeapi", timestamp: "1701278942", fetchedAt: "2023-11-29 17:55:43+00:00"},
+		{id: "b", provider: "awesomeapi", timestamp: "1701278942", fetchedAt: "2023-11-29 17:56:43+00:00"},
+		{id: "c", provider: "awesomeapi", timestamp: "1701278942", fetchedAt: nil},
+		{id: "d", provider: "frankfurter", timestamp: "1701278942", fetchedAt: "2023-11-29 17:55:43+00:00"},
+		{id: "e", provider: nil, timestamp: "1701279002", fetchedAt: nil},
+		{id: "f", provider: nil, timestamp: "1701279002", fetchedAt: nil},
+	}
+	insert := database.SQLite.Rebind("INSERT INTO quotations (id, code, codein, pair, provider, timestamp, fetched_at) VALUES (?, 'USD', 'BRL', 'USD-BRL', ?, ?, ?)")
+	if repository, ok := suite.repository.(*QuotationsRepository); ok {
+		insert = repository.Dialect.Rebind(insert)
+	}
+	for _, row := range rows {
+		_, err := suite.db.Exec(insert, row.id, row.provider, row.timestamp, row.fetchedAt)
+		require.NoError(suite.T(), err)
+	}
+
+	count, err := suite.repository.CountDuplicates(context.Background())
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, count)
+
+	removed, err := suite.repository.RemoveDuplicates(context.Background())
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 3, removed)
+
+	// The most recently fetched row of each group is kept
+	var kept []string
+	result, err := suite.db.Query("SELECT id FROM quotations ORDER BY id")
+	require.NoError(suite.T(), err)
+	defer result.Close()
+	for result.Next() {
+		var id string
+		require.NoError(suite.T(), result.Scan(&id))
+		kept = append(kept, id)
+	}
+	require.NoError(suite.T(), result.Err())
+	assert.Equal(suite.T(), []string{"b", "d", "f"}, kept)
+
+	count, err = suite.repository.CountDuplicates(context.Background())
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, count)
+}
+
+func (suite *RepositoryTestSuite) TestFindLatest() {
+	createDate, _ := time.Parse("2006-01-02 15:04:05", "2023-11-29 17:55:42")
+	fetchedAt := time.Date(2023, 11, 29, 17, 55, 43, 0, time.UTC)
+
+	quotations := []gateways.Quotation{
+		{Code: "USD", Codein: "BRL", High: decimal.MustParse("5.8688"), Low: decimal.MustParse("5.8213"), Bid: decimal.MustParse("5.8576"), Ask: decimal.MustParse("5.8582"), Timestamp: "1701278942", CreateDate: createDate, Provider: "awesomeapi", FetchedAt: fetchedAt},
+		{Code: "USD", Codein: "BRL", High: decimal.MustParse("5.8688"), Low: decimal.MustParse("5.8213"), Bid: decimal.MustParse("5.8601"), Ask: decimal.MustParse("5.8607"), Timestamp: "1701279002", CreateDate: createDate.Add(time.Minute), Provider: "frankfurter", FetchedAt: fetchedAt.Add(time.Minute)},
+		{Code: "EUR", Codein: "BRL", High: decimal.MustParse("6.4102"), Low: decimal.MustParse("6.3511"), Bid: decimal.MustParse("6.3894"), Ask: decimal.MustParse("6.3922"), Timestamp: "1701279062", CreateDate: createDate.Add(2 * time.Minute), Provider: "awesomeapi", FetchedAt: fetchedAt.Add(2 * time.Minute)},
+	}
+	for _, quotation := range quotations {
+		require.NoError(suite.T(), suite.repository.Create(quotation))
+	}
+
+	latest, err := suite.repository.FindLatest(context.Background(), "USD-BRL")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "5.8601", latest.Bid.String())
+	assert.Equal(suite.T(), "frankfurter", latest.Provider)
+	assert.Equal(suite.T(), fetchedAt.Add(time.Minute), latest.FetchedAt)
+	assert.Equal(suite.T(), createDate.Add(time.Minute), latest.CreateDate)
+
+	_, err = suite.repository.FindLatest(context.Background(), "GBP-BRL")
+	assert.ErrorIs(suite.T(), err, ErrQuotationNotFound)
+}
+
+func (suite *RepositoryTestSuite) TestFindHistory() {
+	start := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		quotation := gateways.Quotation{
+			Code:       "USD",
+			Codein:     "BRL",
+			High:       decimal.MustParse("5.8688"),
+			Low:        decimal.MustParse("5.8213"),
+			Bid:        decimal.MustParse(fmt.Sprintf("5.85%02d", i)),
+			Ask:        decimal.MustParse("5.8582"),
+			Timestamp:  fmt.Sprint(start.Add(time.Duration(i) * time.Minute).Unix()),
+			CreateDate: start.Add(time.Duration(i) * time.Minute),
+			Provider:   "awesomeapi",
+		}
+		require.NoError(suite.T(), suite.repository.Create(quotation))
+	}
+	require.NoError(suite.T(), suite.repository.Create(gateways.Quotation{Code: "EUR", Codein: "BRL", Bid: decimal.MustParse("6.3894"), Ask: decimal.MustParse("6.3922"), CreateDate: start}))
+
+	// First page
+	page, err := suite.repository.FindHistory(context.Background(), HistoryFilter{Pair: "USD-BRL", Limit: 2})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page.Quotations, 2)
+	assert.Equal(suite.T(), "5.8500", page.Quotations[0].Bid.String())
+	assert.Equal(suite.T(), "5.8501", page.Quotations[1].Bid.String())
+	assert.Equal(suite.T(), start, page.Quotations[0].CreateDate)
+	require.NotEmpty(suite.T(), page.NextCursor)
+
+	// Following pages
+	page, err = suite.repository.FindHistory(context.Background(), HistoryFilter{Pair: "USD-BRL", Limit: 2, Cursor: page.NextCursor})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page.Quotations, 2)
+	assert.Equal(suite.T(), "5.8502", page.Quotations[0].Bid.String())
+	assert.Equal(suite.T(), "5.8503", page.Quotations[1].Bid.String())
+
+	page, err = suite.repository.FindHistory(context.Background(), HistoryFilter{Pair: "USD-BRL", Limit: 2, Cursor: page.NextCursor})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page.Quotations, 1)
+	assert.Equal(suite.T(), "5.8504", page.Quotations[0].Bid.String())
+	assert.Empty(suite.T(), page.NextCursor)
+
+	// Time range
+	page, err = suite.repository.FindHistory(context.Background(), HistoryFilter{
+		Pair: "USD-BRL",
+		From: start.Add(time.Minute),
+		To:   start.Add(3 * time.Minute),
+	})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page.Quotations, 2)
+	assert.Equal(suite.T(), "5.8501", page.Quotations[0].Bid.String())
+	assert.Equal(suite.T(), "5.8502", page.Quotations[1].Bid.String())
+	assert.Empty(suite.T(), page.NextCursor)
+
+	// Other pair
+	page, err = suite.repository.FindHistory(context.Background(), HistoryFilter{Pair: "EUR-BRL"})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page.Quotations, 1)
+	assert.Equal(suite.T(), "6.3894", page.Quotations[0].Bid.String())
+
+	// Invalid cursor
+	_, err = suite.repository.FindHistory(context.Background(), HistoryFilter{Pair: "USD-BRL", Cursor: "not a cursor"})
+	assert.ErrorIs(suite.T(), err, ErrInvalidCursor)
+}
+
+// Run the contract suite against an in-memory SQLite database
+func TestSQLiteRepositorySuite(t *testing.T) {
+	suite.Run(t, &RepositoryTestSuite{dsn: ":memory:"})
+}
+
+// Run the contract suite against Postgres when TEST_POSTGRES_DSN points to one,
+// e.g. postgres://postgres@localhost:5432/quotations_test?sslmode=disable
+func TestPostgresRepositorySuite(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+
+	suite.Run(t, &RepositoryTestSuite{dsn: dsn})
 }
@@ -0,0 +1,274 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/aggregations"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+)
+
+// RollupResolutions são as resoluções guardadas em quotation_rollups
+var RollupResolutions = []aggregations.Interval{aggregations.Hour, aggregations.Day}
+
+// CompactionResult resume uma execução de Compact
+type CompactionResult struct {
+	Removed int `json:"removed"`
+	Rollups int `json:"rollups"`
+}
+
+const rollupColumns = "pair, resolution, bucket_start, open, high, low, close, avg, close_ask, samples"
+
+const upsertRollupQuery = `
+	INSERT INTO quotation_rollups (` + rollupColumns + `)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (pair, resolution, bucket_start) DO UPDATE SET
+	open = excluded.open,
+	high = excluded.high,
+	low = excluded.low,
+	close = excluded.close,
+	avg = excluded.avg,
+	close_ask = excluded.close_ask,
+	samples = excluded.samples
+`
+
+// Compact resume em quotation_rollups, por hora e por dia, as cotações com
+// create_date anterior a cutoff e então as apaga. Cada dia é processado em
+// uma transação própria, de forma que uma interrupção não perde nem duplica
+// amostras; resumos já existentes do mesmo intervalo são combinados.
+func (r *QuotationsRepository) Compact(ctx context.Context, cutoff time.Time) (CompactionResult, error) {
+	var result CompactionResult
+
+	for {
+		oldest, found, err := r.oldestBefore(ctx, cutoff)
+		if err != nil {
+			return result, err
+		}
+		if !found {
+			return result, nil
+		}
+
+		dayStart := oldest.Truncate(24 * time.Hour)
+		dayEnd := dayStart.Add(24 * time.Hour)
+		if dayEnd.After(cutoff) {
+			dayEnd = cutoff
+		}
+
+		day, err := r.compactRange(ctx, dayStart, dayEnd)
+		if err != nil {
+			return result, err
+		}
+		if day.Removed == 0 {
+			// Sem progresso a próxima volta encontraria a mesma cotação
+			return result, fmt.Errorf("falha ao compactar cotações de %s: nenhuma cotação apagada", dayStart.Format(time.DateOnly))
+		}
+
+		result.Removed += day.Removed
+		result.Rollups += day.Rollups
+	}
+}
+
+func (r *QuotationsRepository) oldestBefore(ctx context.Context, cutoff time.Time) (time.Time, bool, error) {
+	var oldest sql.NullString
+	err := r.Db.QueryRowContext(ctx, r.Dialect.Rebind("SELECT MIN(create_date) FROM quotations WHERE create_date < ?"), cutoff.UTC()).Scan(&oldest)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("falha ao buscar cotações antigas: %w", err)
+	}
+	if !oldest.Valid {
+		return time.Time{}, false, nil
+	}
+
+	parsed, err := parseTime(oldest)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return parsed, true, nil
+}
+
+// compactRange resume e apaga as cotações de [from, to) em uma única transação
+func (r *QuotationsRepository) compactRange(ctx context.Context, from, to time.Time) (CompactionResult, error) {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return CompactionResult{}, fmt.Errorf("falha ao iniciar transação: %w", err)
+	}
+	defer tx.Rollback()
+
+	quotations, err := r.rangeQuotations(ctx, tx, from, to)
+	if err != nil {
+		return CompactionResult{}, err
+	}
+
+	var result CompactionResult
+	for _, resolution := range RollupResolutions {
+		// Um intervalo que começa antes de from pode já ter sido resumido em parte
+		existing, err := r.rollupsIn(ctx, tx, resolution, from.Truncate(resolution.Duration()), to)
+		if err != nil {
+			return CompactionResult{}, err
+		}
+
+		for _, rollup := range aggregations.BuildRollups(quotations, resolution) {
+			if previous, ok := existing[rollupKey(rollup.Pair, rollup.Start)]; ok {
+				rollup = previous.Merge(rollup)
+			}
+
+			_, err := tx.ExecContext(ctx, r.Dialect.Rebind(upsertRollupQuery),
+				rollup.Pair.String(), string(rollup.Resolution), rollup.Start.UTC(),
+				rollup.Open, rollup.High, rollup.Low, rollup.Close, rollup.Average, rollup.CloseAsk, rollup.Samples)
+			if err != nil {
+				return CompactionResult{}, fmt.Errorf("falha ao gravar resumo: %w", err)
+			}
+			result.Rollups++
+		}
+	}
+
+	deleted, err := tx.ExecContext(ctx, r.Dialect.Rebind("DELETE FROM quotations WHERE create_date >= ? AND create_date < ?"), from.UTC(), to.UTC())
+	if err != nil {
+		return CompactionResult{}, fmt.Errorf("falha ao apagar cotações resumidas: %w", err)
+	}
+	removed, err := deleted.RowsAffected()
+	if err != nil {
+		return CompactionResult{}, fmt.Errorf("falha ao apagar cotações resumidas: %w", err)
+	}
+	result.Removed = int(removed)
+
+	if err := tx.Commit(); err != nil {
+		return CompactionResult{}, fmt.Errorf("falha ao confirmar transação: %w", err)
+	}
+
+	return result, nil
+}
+
+func (r *QuotationsRepository) rangeQuotations(ctx context.Context, tx *sql.Tx, from, to time.Time) ([]gateways.Quotation, error) {
+	query := `
+		SELECT ` + quotationColumns + `
+		FROM quotations
+		WHERE create_date >= ? AND create_date < ?
+		ORDER BY create_date ASC, id ASC
+	`
+
+	rows, err := tx.QueryContext(ctx, r.Dialect.Rebind(query), from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar cotações antigas: %w", err)
+	}
+	defer rows.Close()
+
+	var quotations []gateways.Quotation
+	for rows.Next() {
+		quotation, err := scanQuotation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler cotações antigas: %w", err)
+		}
+		quotations = append(quotations, quotation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("falha ao ler cotações antigas: %w", err)
+	}
+
+	return quotations, nil
+}
+
+func rollupKey(pair gateways.Pair, start time.Time) string {
+	return pair.String() + "|" + start.UTC().Format(time.RFC3339)
+}
+
+// rollupsIn devolve os resumos de todos os pares com início em [from, to), por rollupKey
+func (r *QuotationsRepository) rollupsIn(ctx context.Context, tx *sql.Tx, resolution aggregations.Interval, from, to time.Time) (map[string]aggregations.Rollup, error) {
+	query := `
+		SELECT ` + rollupColumns + `
+		FROM quotation_rollups
+		WHERE resolution = ? AND bucket_start >= ? AND bucket_start < ?
+	`
+
+	rows, err := tx.QueryContext(ctx, r.Dialect.Rebind(query), string(resolution), from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar resumos: %w", err)
+	}
+	defer rows.Close()
+
+	rollups := make(map[string]aggregations.Rollup)
+	for rows.Next() {
+		rollup, err := scanRollup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler resumos: %w", err)
+		}
+		rollups[rollupKey(rollup.Pair, rollup.Start)] = rollup
+	}
+
+	return rollups, rows.Err()
+}
+
+// FindRollups lista, em ordem cronológica, os resumos do par na resolução
+// informada com início em [from, to)
+func (r *QuotationsRepository) FindRollups(ctx context.Context, pair gateways.Pair, resolution aggregations.Interval, from, to time.Time) ([]aggregations.Rollup, error) {
+	query := `
+		SELECT ` + rollupColumns + `
+		FROM quotation_rollups
+		WHERE pair = ? AND resolution = ? AND bucket_start >= ? AND bucket_start < ?
+		ORDER BY bucket_start ASC
+	`
+
+	rows, err := r.Db.QueryContext(ctx, r.Dialect.Rebind(query), pair.String(), string(resolution), from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("falha ao buscar resumos: %w", err)
+	}
+	defer rows.Close()
+
+	rollups := []aggregations.Rollup{}
+	for rows.Next() {
+		rollup, err := scanRollup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler resumos: %w", err)
+		}
+		rollups = append(rollups, rollup)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("falha ao ler resumos: %w", err)
+	}
+
+	return rollups, nil
+}
+
+// scanRollup lê as colunas de rollupColumns, precedidas dos destinos em prefix
+func scanRollup(row scanner, prefix ...any) (aggregations.Rollup, error) {
+	var (
+		rollup      aggregations.Rollup
+		pair        string
+		resolution  string
+		bucketStart sql.NullString
+	)
+
+	dest := append(prefix,
+		&pair,
+		&resolution,
+		&bucketStart,
+		&rollup.Open,
+		&rollup.High,
+		&rollup.Low,
+		&rollup.Close,
+		&rollup.Average,
+		&rollup.CloseAsk,
+		&rollup.Samples,
+	)
+
+	if err := row.Scan(dest...); err != nil {
+		return aggregations.Rollup{}, err
+	}
+
+	rollup.Pair = gateways.Pair(pair)
+	rollup.Resolution = aggregations.Interval(resolution)
+
+	start, err := parseTime(bucketStart)
+	if err != nil {
+		return aggregations.Rollup{}, err
+	}
+	if start.IsZero() {
+		return aggregations.Rollup{}, errors.New("resumo sem bucket_start")
+	}
+	rollup.Start = start
+
+	return rollup, nil
+}
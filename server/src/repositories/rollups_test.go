@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/aggregations"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (suite *RepositoryTestSuite) TestCompact() {
+	day := time.Date(2023, 11, 29, 0, 0, 0, 0, time.UTC)
+
+	// Two hours of USD-BRL and one EUR-BRL quote on the 29th, one USD-BRL quote on the 30th
+	samples := []struct {
+		pair       gateways.Pair
+		createDate time.Time
+		bid        string
+	}{
+		{"USD-BRL", day.Add(17*time.Hour + 5*time.Minute), "5.8500"},
+		{"USD-BRL", day.Add(17*time.Hour + 35*time.Minute), "5.8700"},
+		{"USD-BRL", day.Add(18*time.Hour + 10*time.Minute), "5.8600"},
+		{"EUR-BRL", day.Add(17*time.Hour + 20*time.Minute), "6.3894"},
+		{"USD-BRL", day.Add(24*time.Hour + time.Hour), "5.9000"},
+	}
+	for i, sample := range samples {
+		require.NoError(suite.T(), suite.repository.Create(gateways.Quotation{
+			Code:       sample.pair.Code(),
+			Codein:     sample.pair.Codein(),
+			Bid:        decimal.MustParse(sample.bid),
+			Ask:        decimal.MustParse(sample.bid),
+			Timestamp:  fmt.Sprint(i),
+			CreateDate: sample.createDate,
+			Provider:   "awesomeapi",
+		}))
+	}
+
+	// Only the 29th is older than the cutoff
+	result, err := suite.repository.Compact(context.Background(), day.Add(24*time.Hour))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), CompactionResult{Removed: 4, Rollups: 5}, result)
+
+	var count int
+	require.NoError(suite.T(), suite.db.QueryRow("SELECT COUNT(*) FROM quotations").Scan(&count))
+	assert.Equal(suite.T(), 1, count)
+
+	hourly, err := suite.repository.FindRollups(context.Background(), "USD-BRL", aggregations.Hour, day, day.Add(48*time.Hour))
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), hourly, 2)
+	assert.Equal(suite.T(), day.Add(17*time.Hour), hourly[0].Start)
+	assert.Equal(suite.T(), "5.8500", hourly[0].Open.String())
+	assert.Equal(suite.T(), "5.8700", hourly[0].Close.String())
+	assert.Equal(suite.T(), 2, hourly[0].Samples)
+	assert.Equal(suite.T(), 1, hourly[1].Samples)
+
+	daily, err := suite.repository.FindRollups(context.Background(), "USD-BRL", aggregations.Day, day, day.Add(48*time.Hour))
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), daily, 1)
+	assert.Equal(suite.T(), "5.8700", daily[0].High.String())
+	assert.Equal(suite.T(), "5.8600", daily[0].Close.String())
+	assert.Equal(suite.T(), 0, daily[0].Average.Cmp(decimal.MustParse("5.86")))
+	assert.Equal(suite.T(), 3, daily[0].Samples)
+
+	// A late quote for a compacted hour is merged into the existing rollups
+	require.NoError(suite.T(), suite.repository.Create(gateways.Quotation{Code: "USD", Codein: "BRL", Bid: decimal.MustParse("5.8400"), Ask: decimal.MustParse("5.8400"), Timestamp: "late", CreateDate: day.Add(17*time.Hour + 50*time.Minute), Provider: "awesomeapi"}))
+
+	result, err = suite.repository.Compact(context.Background(), day.Add(24*time.Hour))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), CompactionResult{Removed: 1, Rollups: 2}, result)
+
+	hourly, err = suite.repository.FindRollups(context.Background(), "USD-BRL", aggregations.Hour, day, day.Add(18*time.Hour))
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), hourly, 1)
+	assert.Equal(suite.T(), "5.8400", hourly[0].Low.String())
+	assert.Equal(suite.T(), "5.8400", hourly[0].Close.String())
+	assert.Equal(suite.T(), 3, hourly[0].Samples)
+
+	// Nothing left to compact
+	result, err = suite.repository.Compact(context.Background(), day.Add(24*time.Hour))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), CompactionResult{}, result)
+}
+
+func (suite *RepositoryTestSuite) TestCompactLargePrices() {
+	day := time.Date(2023, 11, 29, 0, 0, 0, 0, time.UTC)
+	large := strings.Repeat("9", decimal.MaxDigits-4)
+
+	for i, bid := range []string{large + ".1001", large + ".2502", large + ".3103"} {
+		require.NoError(suite.T(), suite.repository.Create(gateways.Quotation{
+			Code:       "BTC",
+			Codein:     "BRL",
+			Bid:        decimal.MustParse(bid),
+			Ask:        decimal.MustParse(bid),
+			Timestamp:  fmt.Sprint(i),
+			CreateDate: day.Add(time.Duration(i) * time.Minute),
+			Provider:   "awesomeapi",
+		}))
+	}
+
+	// Averages of prices near MaxDigits are stored and read back, so retention moves on
+	result, err := suite.repository.Compact(context.Background(), day.Add(24*time.Hour))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), CompactionResult{Removed: 3, Rollups: 2}, result)
+
+	daily, err := suite.repository.FindRollups(context.Background(), "BTC-BRL", aggregations.Day, day, day.Add(24*time.Hour))
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), daily, 1)
+	assert.Equal(suite.T(), large+".2202", daily[0].Average.String())
+	assert.Equal(suite.T(), large+".3103", daily[0].High.String())
+}
+
+func (suite *RepositoryTestSuite) TestFindHistoryReadsRollups() {
+	start := time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC)
+
+	// Three compacted hours followed by two raw quotes
+	for i := 0; i < 5; i++ {
+		require.NoError(suite.T(), suite.repository.Create(gateways.Quotation{
+			Code:       "USD",
+			Codein:     "BRL",
+			Bid:        decimal.MustParse(fmt.Sprintf("5.85%02d", i)),
+			Ask:        decimal.MustParse("5.8600"),
+			Timestamp:  fmt.Sprint(i),
+			CreateDate: start.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+			Provider:   "awesomeapi",
+		}))
+	}
+	_, err := suite.repository.Compact(context.Background(), start.Add(3*time.Hour))
+	require.NoError(suite.T(), err)
+
+	// Pages cross from rollups into raw quotes
+	var history []gateways.Quotation
+	filter := HistoryFilter{Pair: "USD-BRL", Limit: 2}
+	for {
+		page, err := suite.repository.FindHistory(context.Background(), filter)
+		require.NoError(suite.T(), err)
+		history = append(history, page.Quotations...)
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	require.Len(suite.T(), history, 5)
+	for i, quotation := range history {
+		assert.Equal(suite.T(), fmt.Sprintf("5.85%02d", i), quotation.Bid.String())
+	}
+	assert.Equal(suite.T(), "rollup-1h", history[0].Provider)
+	assert.Equal(suite.T(), start, history[0].CreateDate)
+	assert.Equal(suite.T(), "awesomeapi", history[4].Provider)
+
+	// Candles and other raw readers can leave the rollups out
+	page, err := suite.repository.FindHistory(context.Background(), HistoryFilter{Pair: "USD-BRL", RawOnly: true})
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), page.Quotations, 2)
+
+	// Time ranges apply to rollups too
+	page, err = suite.repository.FindHistory(context.Background(), HistoryFilter{Pair: "USD-BRL", From: start.Add(time.Hour), To: start.Add(4 * time.Hour)})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), page.Quotations, 3)
+	assert.Equal(suite.T(), "5.8501", page.Quotations[0].Bid.String())
+}
@@ -0,0 +1,74 @@
+// Package responses reúne utilitários para o http.ResponseWriter compartilhados
+// pelos middlewares do servidor.
+package responses
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// Recorder envolve um http.ResponseWriter e guarda o status escrito pelo
+// handler, mantendo o suporte a streaming (Flush) e o acesso ao ResponseWriter
+// original via http.ResponseController (Unwrap).
+type Recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// NewRecorder devolve um Recorder cujo status é 200 até o handler escrever outro
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Wrap devolve w se ele já é um Recorder, de forma que middlewares encadeados
+// compartilham o mesmo, ou um novo Recorder sobre w. Um Recorder alcançado só
+// via Unwrap não é reaproveitado: as escritas pulariam os wrappers no caminho.
+func Wrap(w http.ResponseWriter) *Recorder {
+	if recorder, ok := w.(*Recorder); ok {
+		return recorder
+	}
+	return NewRecorder(w)
+}
+
+// Status devolve o status da resposta
+func (r *Recorder) Status() int {
+	return r.status
+}
+
+func (r *Recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(body []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(body)
+}
+
+// Flush mantém o suporte a respostas em streaming
+func (r *Recorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack permite que o handler assuma a conexão, como no upgrade para
+// WebSocket, que é registrado com status 101
+func (r *Recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && !r.wroteHeader {
+		r.status = http.StatusSwitchingProtocols
+		r.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap permite que http.ResponseController alcance o ResponseWriter original
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,105 @@
+package responses
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  func(w http.ResponseWriter)
+		status int
+	}{
+		{
+			name:   "nothing written",
+			write:  func(w http.ResponseWriter) {},
+			status: http.StatusOK,
+		},
+		{
+			name:   "explicit status",
+			write:  func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) },
+			status: http.StatusNotFound,
+		},
+		{
+			name: "body without status",
+			write: func(w http.ResponseWriter) {
+				w.Write([]byte("{}"))
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "first status wins",
+			write: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := NewRecorder(httptest.NewRecorder())
+			tt.write(recorder)
+			assert.Equal(t, tt.status, recorder.Status())
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	inner := httptest.NewRecorder()
+	recorder := Wrap(inner)
+	assert.Same(t, http.ResponseWriter(inner), recorder.Unwrap())
+
+	// Chained middlewares share the recorder instead of stacking new ones
+	assert.Same(t, recorder, Wrap(recorder))
+
+	// A recorder behind another wrapper is not reused, so writes still go through it
+	outer := struct{ http.ResponseWriter }{recorder}
+	assert.NotSame(t, recorder, Wrap(outer))
+}
+
+func TestRecorderKeepsFlusher(t *testing.T) {
+	inner := httptest.NewRecorder()
+	recorder := NewRecorder(inner)
+
+	recorder.Write([]byte("data: 1\n\n"))
+
+	assert.NoError(t, http.NewResponseController(recorder).Flush())
+	assert.True(t, inner.Flushed)
+}
+
+func TestRecorderKeepsHijacker(t *testing.T) {
+	status := make(chan int, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := NewRecorder(w)
+		defer func() { status <- recorder.Status() }()
+
+		conn, _, err := http.NewResponseController(recorder).Hijack()
+		if err != nil {
+			recorder.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\n"))
+		conn.Close()
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/cotacao/ws")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// The upgraded connection is recorded as 101, not as the default 200
+	assert.Equal(t, http.StatusSwitchingProtocols, <-status)
+}
+
+func TestRecorderUnwrap(t *testing.T) {
+	inner := httptest.NewRecorder()
+	assert.Same(t, http.ResponseWriter(inner), NewRecorder(inner).Unwrap())
+}
@@ -0,0 +1,125 @@
+package schedulers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+)
+
+// Interfaces para dependências
+type QuotationGateway interface {
+	GetQuotation(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error)
+}
+
+type QuotationRepository interface {
+	CreateWithContext(ctx context.Context, quotation gateways.Quotation) error
+}
+
+// QuotationPoller busca periodicamente as cotações de cada par configurado e as
+// persiste, desacoplando a consulta aos provedores do atendimento das requisições
+type QuotationPoller struct {
+	gateway        QuotationGateway
+	repository     QuotationRepository
+	schedule       map[gateways.Pair]time.Duration
+	PersistTimeout time.Duration
+}
+
+func NewQuotationPoller(gateway QuotationGateway, repository QuotationRepository, schedule map[gateways.Pair]time.Duration) *QuotationPoller {
+	return &QuotationPoller{
+		gateway:        gateway,
+		repository:     repository,
+		schedule:       schedule,
+		PersistTimeout: 10 * time.Millisecond,
+	}
+}
+
+// Run consulta cada par imediatamente e depois a cada intervalo configurado,
+// bloqueando até que o contexto seja cancelado
+func (p *QuotationPoller) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for pair, interval := range p.schedule {
+		wg.Add(1)
+		go func(pair gateways.Pair, interval time.Duration) {
+			defer wg.Done()
+			p.poll(ctx, pair, interval)
+		}(pair, interval)
+	}
+
+	wg.Wait()
+}
+
+func (p *QuotationPoller) poll(ctx context.Context, pair gateways.Pair, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.refresh(ctx, pair)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *QuotationPoller) refresh(ctx context.Context, pair gateways.Pair) {
+	quotation, err := p.gateway.GetQuotation(ctx, pair)
+	if err != nil {
+		slog.ErrorContext(ctx, "Erro ao atualizar cotação", "pair", pair, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.PersistTimeout)
+	defer cancel()
+
+	err = p.repository.CreateWithContext(ctx, quotation)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			slog.ErrorContext(ctx, "Tempo excedido ao persistir cotação no banco de dados", "pair", pair, "error", err)
+		} else {
+			slog.ErrorContext(ctx, "Erro ao persistir cotação no banco de dados", "pair", pair, "error", err)
+		}
+	}
+}
+
+// ParseSchedule interpreta uma lista no formato "USD-BRL=30s,EUR-BRL=1m"
+func ParseSchedule(value string) (map[gateways.Pair]time.Duration, error) {
+	schedule := make(map[gateways.Pair]time.Duration)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		rawPair, rawInterval, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule entry %q: expected PAIR=INTERVAL", entry)
+		}
+
+		pair, err := gateways.ParsePair(rawPair)
+		if err != nil {
+			return nil, err
+		}
+
+		interval, err := time.ParseDuration(strings.TrimSpace(rawInterval))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval for %s: %w", pair, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("invalid interval for %s: must be positive", pair)
+		}
+
+		schedule[pair] = interval
+	}
+
+	return schedule, nil
+}
@@ -0,0 +1,147 @@
+package schedulers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Fake gateway
+type fakeGateway struct {
+	mu    sync.Mutex
+	calls map[gateways.Pair]int
+	err   error
+}
+
+func (g *fakeGateway) GetQuotation(ctx context.Context, pair gateways.Pair) (gateways.Quotation, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.calls == nil {
+		g.calls = make(map[gateways.Pair]int)
+	}
+	g.calls[pair]++
+
+	if g.err != nil {
+		return gateways.Quotation{}, g.err
+	}
+	return gateways.Quotation{Code: pair.Code(), Codein: pair.Codein(), Bid: decimal.MustParse("5.8576")}, nil
+}
+
+func (g *fakeGateway) callsFor(pair gateways.Pair) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.calls[pair]
+}
+
+// Fake repository
+type fakeRepository struct {
+	mu         sync.Mutex
+	quotations []gateways.Quotation
+}
+
+func (r *fakeRepository) CreateWithContext(ctx context.Context, quotation gateways.Quotation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quotations = append(r.quotations, quotation)
+	return nil
+}
+
+func (r *fakeRepository) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.quotations)
+}
+
+func TestQuotationPollerRun(t *testing.T) {
+	gateway := &fakeGateway{}
+	repository := &fakeRepository{}
+	poller := NewQuotationPoller(gateway, repository, map[gateways.Pair]time.Duration{
+		"USD-BRL": 20 * time.Millisecond,
+		"EUR-BRL": time.Hour,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 110*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		poller.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("poller did not stop after context cancellation")
+	}
+
+	// USD-BRL is polled immediately and then on every tick
+	assert.GreaterOrEqual(t, gateway.callsFor("USD-BRL"), 4)
+	// EUR-BRL is polled once at startup only
+	assert.Equal(t, 1, gateway.callsFor("EUR-BRL"))
+	assert.Equal(t, gateway.callsFor("USD-BRL")+1, repository.count())
+}
+
+func TestQuotationPollerSkipsPersistenceOnGatewayError(t *testing.T) {
+	gateway := &fakeGateway{err: errors.New("all quotation providers failed")}
+	repository := &fakeRepository{}
+	poller := NewQuotationPoller(gateway, repository, map[gateways.Pair]time.Duration{
+		"USD-BRL": 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	poller.Run(ctx)
+
+	assert.GreaterOrEqual(t, gateway.callsFor("USD-BRL"), 1)
+	assert.Equal(t, 0, repository.count())
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected map[gateways.Pair]time.Duration
+		wantErr  bool
+	}{
+		{
+			name:  "multiple pairs",
+			value: "USD-BRL=30s, eur-brl=1m",
+			expected: map[gateways.Pair]time.Duration{
+				"USD-BRL": 30 * time.Second,
+				"EUR-BRL": time.Minute,
+			},
+		},
+		{
+			name:     "empty",
+			value:    "",
+			expected: map[gateways.Pair]time.Duration{},
+		},
+		{name: "missing interval", value: "USD-BRL", wantErr: true},
+		{name: "invalid interval", value: "USD-BRL=soon", wantErr: true},
+		{name: "non positive interval", value: "USD-BRL=0s", wantErr: true},
+		{name: "unsupported pair", value: "XYZ-BRL=30s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.value)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, schedule)
+		})
+	}
+}
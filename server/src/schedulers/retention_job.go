@@ -0,0 +1,70 @@
+package schedulers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/repositories"
+)
+
+type RetentionRepository interface {
+	Compact(ctx context.Context, cutoff time.Time) (repositories.CompactionResult, error)
+}
+
+// RetentionJob resume e apaga periodicamente as cotações brutas mais antigas
+// que KeepRaw, mantendo apenas os resumos por hora e por dia
+type RetentionJob struct {
+	repository RetentionRepository
+	KeepRaw    time.Duration
+	Interval   time.Duration
+	// Timeout limita cada execução; a compactação é retomada na próxima
+	Timeout time.Duration
+	now     func() time.Time
+}
+
+func NewRetentionJob(repository RetentionRepository, keepRaw time.Duration) *RetentionJob {
+	return &RetentionJob{
+		repository: repository,
+		KeepRaw:    keepRaw,
+		Interval:   time.Hour,
+		Timeout:    5 * time.Minute,
+		now:        time.Now,
+	}
+}
+
+// Cutoff é o início, em UTC, do dia mais antigo cujas cotações brutas são
+// mantidas. Compactar apenas dias inteiros evita resumos diários parciais.
+func (j *RetentionJob) Cutoff() time.Time {
+	return j.now().UTC().Add(-j.KeepRaw).Truncate(24 * time.Hour)
+}
+
+// RunOnce executa uma compactação até Cutoff
+func (j *RetentionJob) RunOnce(ctx context.Context) (repositories.CompactionResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, j.Timeout)
+	defer cancel()
+
+	return j.repository.Compact(ctx, j.Cutoff())
+}
+
+// Run compacta imediatamente e depois a cada Interval, bloqueando até que o
+// contexto seja cancelado
+func (j *RetentionJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := j.RunOnce(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "Erro ao compactar cotações antigas", "error", err)
+		} else if result.Removed > 0 {
+			slog.InfoContext(ctx, "Compactação concluída", "removed", result.Removed, "rollups", result.Rollups)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package schedulers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Fake retention repository
+type fakeRetentionRepository struct {
+	mu      sync.Mutex
+	cutoffs []time.Time
+	err     error
+}
+
+func (r *fakeRetentionRepository) Compact(ctx context.Context, cutoff time.Time) (repositories.CompactionResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cutoffs = append(r.cutoffs, cutoff)
+
+	if r.err != nil {
+		return repositories.CompactionResult{}, r.err
+	}
+	return repositories.CompactionResult{Removed: 10, Rollups: 2}, nil
+}
+
+func (r *fakeRetentionRepository) calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.cutoffs)
+}
+
+func TestRetentionJobCutoff(t *testing.T) {
+	job := NewRetentionJob(&fakeRetentionRepository{}, 30*24*time.Hour)
+	job.now = func() time.Time {
+		return time.Date(2023, 12, 29, 1, 30, 0, 0, time.FixedZone("BRT", -3*60*60))
+	}
+
+	// 2023-12-29T04:30Z minus 30 days, truncated to the UTC day
+	assert.Equal(t, time.Date(2023, 11, 29, 0, 0, 0, 0, time.UTC), job.Cutoff())
+}
+
+func TestRetentionJobRunOnce(t *testing.T) {
+	repository := &fakeRetentionRepository{}
+	job := NewRetentionJob(repository, 7*24*time.Hour)
+	job.now = func() time.Time { return time.Date(2023, 11, 29, 17, 0, 0, 0, time.UTC) }
+
+	result, err := job.RunOnce(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, repositories.CompactionResult{Removed: 10, Rollups: 2}, result)
+	assert.Equal(t, []time.Time{time.Date(2023, 11, 22, 0, 0, 0, 0, time.UTC)}, repository.cutoffs)
+}
+
+func TestRetentionJobRun(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "compacts on every tick"},
+		{name: "keeps running after errors", err: errors.New("database is locked")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &fakeRetentionRepository{err: tt.err}
+			job := NewRetentionJob(repository, 24*time.Hour)
+			job.Interval = 20 * time.Millisecond
+
+			ctx, cancel := context.WithTimeout(context.Background(), 90*time.Millisecond)
+			defer cancel()
+
+			done := make(chan struct{})
+			go func() {
+				job.Run(ctx)
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("retention job did not stop after context cancellation")
+			}
+
+			// Compacted immediately and then on every tick
+			assert.GreaterOrEqual(t, repository.calls(), 3)
+		})
+	}
+}
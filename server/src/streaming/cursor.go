@@ -0,0 +1,79 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+)
+
+var ErrInvalidCursor = errors.New("invalid event ID")
+
+// Cursor guarda, para cada par, o EventTime da última cotação enviada a um
+// assinante. É o ID de cada evento, então o cliente que reconecta com
+// Last-Event-ID informa onde parou em todos os pares, e não só no último.
+type Cursor map[gateways.Pair]time.Time
+
+// ParseCursor lê um Cursor no formato de String. O valor vazio é um cursor vazio.
+func ParseCursor(value string) (Cursor, error) {
+	cursor := make(Cursor)
+	if strings.TrimSpace(value) == "" {
+		return cursor, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		rawPair, rawMillis, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: expected PAIR=MILLISECONDS, got %q", ErrInvalidCursor, entry)
+		}
+
+		pair, err := gateways.ParsePair(rawPair)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+		}
+
+		millis, err := strconv.ParseInt(rawMillis, 10, 64)
+		if err != nil || millis < 0 {
+			return nil, fmt.Errorf("%w: invalid time %q for %s", ErrInvalidCursor, rawMillis, pair)
+		}
+
+		cursor[pair] = time.UnixMilli(millis).UTC()
+	}
+
+	return cursor, nil
+}
+
+// String formata o cursor como PAIR=MILLISECONDS separados por vírgula, em
+// ordem de par (ex.: EUR-BRL=1701278900000,USD-BRL=1701278942000)
+func (c Cursor) String() string {
+	pairs := make([]gateways.Pair, 0, len(c))
+	for pair := range c {
+		pairs = append(pairs, pair)
+	}
+	slices.Sort(pairs)
+
+	entries := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		entries = append(entries, fmt.Sprintf("%s=%d", pair, c[pair].UnixMilli()))
+	}
+	return strings.Join(entries, ",")
+}
+
+// Advance registra a cotação se ela for mais recente que a última enviada do
+// par e informa se ela deve ser enviada
+func (c Cursor) Advance(quotation gateways.Quotation) bool {
+	pair := quotation.Pair()
+	// O ID tem precisão de milissegundos: compara já truncado para que uma
+	// cotação reenviada após reconexão não pareça mais recente
+	at := EventTime(quotation).Truncate(time.Millisecond)
+
+	if last, ok := c[pair]; ok && !at.After(last) {
+		return false
+	}
+	c[pair] = at.UTC()
+	return true
+}
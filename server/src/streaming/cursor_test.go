@@ -0,0 +1,71 @@
+package streaming
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCursor(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected Cursor
+		err      error
+	}{
+		{name: "empty", value: "", expected: Cursor{}},
+		{
+			name:     "single pair",
+			value:    "USD-BRL=1701280542000",
+			expected: Cursor{"USD-BRL": baseTime},
+		},
+		{
+			name:     "multiple pairs",
+			value:    "eur-brl=1701280541000,USD-BRL=1701280542000",
+			expected: Cursor{"EUR-BRL": baseTime.Add(-time.Second), "USD-BRL": baseTime},
+		},
+		{name: "missing separator", value: "USD-BRL", err: ErrInvalidCursor},
+		{name: "unsupported pair", value: "XYZ-BRL=1701280542000", err: ErrInvalidCursor},
+		{name: "invalid time", value: "USD-BRL=yesterday", err: ErrInvalidCursor},
+		{name: "negative time", value: "USD-BRL=-1", err: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := ParseCursor(tt.value)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cursor)
+		})
+	}
+}
+
+func TestCursorString(t *testing.T) {
+	cursor := Cursor{"USD-BRL": baseTime, "EUR-BRL": baseTime.Add(-time.Second)}
+	assert.Equal(t, "EUR-BRL=1701280541000,USD-BRL=1701280542000", cursor.String())
+
+	parsed, err := ParseCursor(cursor.String())
+	require.NoError(t, err)
+	assert.Equal(t, cursor, parsed)
+}
+
+func TestCursorAdvance(t *testing.T) {
+	cursor := Cursor{}
+
+	assert.True(t, cursor.Advance(quotationAt("USD", baseTime.Add(500*time.Microsecond), "4.90")))
+	assert.Equal(t, baseTime, cursor[gateways.Pair("USD-BRL")])
+
+	// Quotations within the same millisecond, or older, were already sent
+	assert.False(t, cursor.Advance(quotationAt("USD", baseTime, "4.90")))
+	assert.False(t, cursor.Advance(quotationAt("USD", baseTime.Add(-time.Second), "4.80")))
+
+	assert.True(t, cursor.Advance(quotationAt("USD", baseTime.Add(time.Second), "4.91")))
+	assert.True(t, cursor.Advance(quotationAt("EUR", baseTime, "5.30")))
+	assert.Len(t, cursor, 2)
+}
@@ -0,0 +1,238 @@
+package streaming
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+)
+
+// Hub distribui as cotações obtidas dos provedores para os assinantes de cada
+// par. Só repassa uma cotação mais recente que a última distribuída do par, de
+// forma que a mesma cotação devolvida em consultas seguidas não gera eventos
+// repetidos. Implementa gateways.FetchObserver.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	latest      map[gateways.Pair]time.Time
+	closed      bool
+
+	published atomic.Uint64
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[*Subscription]struct{}),
+		latest:      make(map[gateways.Pair]time.Time),
+	}
+}
+
+// ObserveFetch não faz nada: o Hub só se interessa pelas cotações obtidas
+func (h *Hub) ObserveFetch(provider string, pair gateways.Pair, duration time.Duration, err error) {}
+
+// ObserveQuotation distribui a cotação se ela for mais recente que a última do par
+func (h *Hub) ObserveQuotation(quotation gateways.Quotation) {
+	h.Publish(quotation)
+}
+
+// Publish entrega a cotação aos assinantes do par sem nunca bloquear: cada
+// assinatura guarda só a cotação mais recente de cada par ainda não lida.
+// Devolve false se a cotação não é mais recente que a última distribuída.
+func (h *Hub) Publish(quotation gateways.Quotation) bool {
+	pair := quotation.Pair()
+	at := EventTime(quotation)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed || !at.After(h.latest[pair]) {
+		return false
+	}
+	h.latest[pair] = at
+	h.published.Add(1)
+
+	for subscription := range h.subscribers {
+		subscription.offer(pair, quotation)
+	}
+	return true
+}
+
+// Subscribe cria uma assinatura das cotações dos pares informados, que deve
+// ser encerrada com Close
+func (h *Hub) Subscribe(pairs []gateways.Pair) *Subscription {
+	subscription := &Subscription{
+		hub:     h,
+		pairs:   make(map[gateways.Pair]bool, len(pairs)),
+		pending: make(map[gateways.Pair]gateways.Quotation),
+		ready:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	for _, pair := range pairs {
+		subscription.pairs[pair] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(subscription.done)
+		return subscription
+	}
+	h.subscribers[subscription] = struct{}{}
+	return subscription
+}
+
+// Close encerra todas as assinaturas e recusa as próximas; usado no
+// desligamento para que as conexões de streaming terminem
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for subscription := range h.subscribers {
+		close(subscription.done)
+		delete(h.subscribers, subscription)
+	}
+}
+
+// HubStats resume a atividade do Hub
+type HubStats struct {
+	Subscribers int    `json:"subscribers"`
+	Published   uint64 `json:"published"`
+}
+
+func (h *Hub) Stats() HubStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return HubStats{Subscribers: len(h.subscribers), Published: h.published.Load()}
+}
+
+func (h *Hub) unsubscribe(subscription *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[subscription]; ok {
+		delete(h.subscribers, subscription)
+		close(subscription.done)
+	}
+}
+
+// Subscription recebe as cotações dos pares assinados. Um consumidor lento
+// não atrasa o Hub nem os demais: enquanto ele não lê, uma cotação nova do
+// mesmo par substitui a pendente (Conflated conta as substituídas).
+type Subscription struct {
+	hub   *Hub
+	ready chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	pairs   map[gateways.Pair]bool
+	pending map[gateways.Pair]gateways.Quotation
+
+	conflated atomic.Uint64
+}
+
+// Ready é sinalizado quando há cotações pendentes para Next
+func (s *Subscription) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Done é fechado quando a assinatura é encerrada, por Close ou pelo Hub
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Next devolve e remove as cotações pendentes, da mais antiga para a mais recente
+func (s *Subscription) Next() []gateways.Quotation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quotations := make([]gateways.Quotation, 0, len(s.pending))
+	for pair, quotation := range s.pending {
+		quotations = append(quotations, quotation)
+		delete(s.pending, pair)
+	}
+	slices.SortFunc(quotations, func(a, b gateways.Quotation) int {
+		return EventTime(a).Compare(EventTime(b))
+	})
+	return quotations
+}
+
+// Pairs devolve os pares assinados, em ordem alfabética
+func (s *Subscription) Pairs() []gateways.Pair {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pairs := make([]gateways.Pair, 0, len(s.pairs))
+	for pair := range s.pairs {
+		pairs = append(pairs, pair)
+	}
+	slices.Sort(pairs)
+	return pairs
+}
+
+// Add passa a receber as cotações dos pares informados
+func (s *Subscription) Add(pairs ...gateways.Pair) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pair := range pairs {
+		s.pairs[pair] = true
+	}
+}
+
+// Remove deixa de receber as cotações dos pares informados e descarta as
+// pendentes deles
+func (s *Subscription) Remove(pairs ...gateways.Pair) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pair := range pairs {
+		delete(s.pairs, pair)
+		delete(s.pending, pair)
+	}
+}
+
+// Conflated conta as cotações substituídas antes de serem lidas
+func (s *Subscription) Conflated() uint64 {
+	return s.conflated.Load()
+}
+
+// Close encerra a assinatura; pode ser chamado mais de uma vez
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+func (s *Subscription) offer(pair gateways.Pair, quotation gateways.Quotation) {
+	s.mu.Lock()
+	if !s.pairs[pair] {
+		s.mu.Unlock()
+		return
+	}
+	if _, ok := s.pending[pair]; ok {
+		s.conflated.Add(1)
+	}
+	s.pending[pair] = quotation
+	s.mu.Unlock()
+
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// EventTime é o instante que ordena as cotações de um par: o create_date
+// informado pelo provedor, o mesmo usado pelo histórico, ou, na falta dele,
+// quando a cotação foi obtida
+func EventTime(quotation gateways.Quotation) time.Time {
+	if !quotation.CreateDate.IsZero() {
+		return quotation.CreateDate
+	}
+	return quotation.FetchedAt
+}
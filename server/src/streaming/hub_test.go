@@ -0,0 +1,160 @@
+package streaming
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/decimal"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var baseTime = time.Date(2023, 11, 29, 17, 55, 42, 0, time.UTC)
+
+func quotationAt(code string, at time.Time, bid string) gateways.Quotation {
+	return gateways.Quotation{Code: code, Codein: "BRL", Bid: decimal.MustParse(bid), Ask: decimal.MustParse(bid), CreateDate: at}
+}
+
+func receive(t *testing.T, subscription *Subscription) []gateways.Quotation {
+	t.Helper()
+	select {
+	case <-subscription.Ready():
+		return subscription.Next()
+	case <-time.After(time.Second):
+		t.Fatal("no quotation received")
+		return nil
+	}
+}
+
+func TestHubPublish(t *testing.T) {
+	hub := NewHub()
+	usd := hub.Subscribe([]gateways.Pair{"USD-BRL"})
+	defer usd.Close()
+	eur := hub.Subscribe([]gateways.Pair{"EUR-BRL"})
+	defer eur.Close()
+
+	assert.True(t, hub.Publish(quotationAt("USD", baseTime, "4.90")))
+
+	received := receive(t, usd)
+	require.Len(t, received, 1)
+	assert.Equal(t, "4.90", received[0].Bid.String())
+
+	select {
+	case <-eur.Ready():
+		t.Fatal("subscriber of another pair was notified")
+	default:
+	}
+
+	assert.Equal(t, HubStats{Subscribers: 2, Published: 1}, hub.Stats())
+}
+
+func TestHubPublishSkipsStaleQuotations(t *testing.T) {
+	hub := NewHub()
+	subscription := hub.Subscribe([]gateways.Pair{"USD-BRL"})
+	defer subscription.Close()
+
+	assert.True(t, hub.Publish(quotationAt("USD", baseTime, "4.90")))
+	// The same quotation returned again, and an older one, are not published
+	assert.False(t, hub.Publish(quotationAt("USD", baseTime, "4.90")))
+	assert.False(t, hub.Publish(quotationAt("USD", baseTime.Add(-time.Minute), "4.80")))
+
+	assert.Len(t, receive(t, subscription), 1)
+	assert.Equal(t, uint64(1), hub.Stats().Published)
+}
+
+func TestHubObserveQuotationUsesFetchedAtWithoutCreateDate(t *testing.T) {
+	hub := NewHub()
+	subscription := hub.Subscribe([]gateways.Pair{"USD-BRL"})
+	defer subscription.Close()
+
+	quotation := quotationAt("USD", time.Time{}, "4.90")
+	quotation.FetchedAt = baseTime
+	hub.ObserveFetch("awesomeapi", "USD-BRL", time.Millisecond, nil)
+	hub.ObserveQuotation(quotation)
+
+	received := receive(t, subscription)
+	require.Len(t, received, 1)
+	assert.Equal(t, baseTime, EventTime(received[0]))
+}
+
+func TestSubscriptionConflatesSlowConsumer(t *testing.T) {
+	hub := NewHub()
+	subscription := hub.Subscribe([]gateways.Pair{"USD-BRL", "EUR-BRL"})
+	defer subscription.Close()
+
+	// Publishing never blocks, even if the subscriber does not read
+	for i := 0; i < 100; i++ {
+		hub.Publish(quotationAt("USD", baseTime.Add(time.Duration(i)*time.Second), "4.90"))
+	}
+	hub.Publish(quotationAt("EUR", baseTime.Add(-time.Hour), "5.30"))
+
+	received := receive(t, subscription)
+	require.Len(t, received, 2)
+	// Oldest first, and only the latest quotation of each pair
+	assert.Equal(t, gateways.Pair("EUR-BRL"), received[0].Pair())
+	assert.Equal(t, baseTime.Add(99*time.Second), received[1].CreateDate)
+	assert.Equal(t, uint64(99), subscription.Conflated())
+	assert.Empty(t, subscription.Next())
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	hub := NewHub()
+	subscription := hub.Subscribe([]gateways.Pair{"USD-BRL"})
+	assert.Equal(t, []gateways.Pair{"USD-BRL"}, subscription.Pairs())
+
+	subscription.Close()
+	subscription.Close()
+
+	select {
+	case <-subscription.Done():
+	default:
+		t.Fatal("subscription not done after Close")
+	}
+	assert.Equal(t, 0, hub.Stats().Subscribers)
+}
+
+func TestHubClose(t *testing.T) {
+	hub := NewHub()
+	subscription := hub.Subscribe([]gateways.Pair{"USD-BRL"})
+
+	hub.Close()
+	<-subscription.Done()
+	subscription.Close()
+
+	assert.False(t, hub.Publish(quotationAt("USD", baseTime, "4.90")))
+
+	late := hub.Subscribe([]gateways.Pair{"USD-BRL"})
+	select {
+	case <-late.Done():
+	default:
+		t.Fatal("subscription after Close should be done")
+	}
+}
+
+func TestSubscriptionAddRemove(t *testing.T) {
+	hub := NewHub()
+	subscription := hub.Subscribe(nil)
+	defer subscription.Close()
+
+	subscription.Add("USD-BRL", "EUR-BRL")
+	assert.Equal(t, []gateways.Pair{"EUR-BRL", "USD-BRL"}, subscription.Pairs())
+
+	hub.Publish(quotationAt("USD", baseTime, "4.90"))
+	hub.Publish(quotationAt("EUR", baseTime, "5.30"))
+
+	// Removing a pair also drops its pending quotation
+	subscription.Remove("USD-BRL")
+	assert.Equal(t, []gateways.Pair{"EUR-BRL"}, subscription.Pairs())
+
+	received := receive(t, subscription)
+	require.Len(t, received, 1)
+	assert.Equal(t, gateways.Pair("EUR-BRL"), received[0].Pair())
+
+	hub.Publish(quotationAt("USD", baseTime.Add(time.Second), "4.91"))
+	select {
+	case <-subscription.Ready():
+		assert.Empty(t, subscription.Next())
+	default:
+	}
+}
@@ -1,15 +1,18 @@
 package integration
 
 import (
+	"context"
 	"database/sql"
-	"io"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/database"
 	"github.com/CaiqueRibeiro/client-api-ex/server/src/gateways"
 	"github.com/CaiqueRibeiro/client-api-ex/server/src/handlers"
+	"github.com/CaiqueRibeiro/client-api-ex/server/src/migrations"
 	"github.com/CaiqueRibeiro/client-api-ex/server/src/repositories"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
@@ -36,31 +39,23 @@ func (suite *ServerIntegrationTestSuite) SetupSuite() {
 	require.NoError(suite.T(), err)
 	suite.db = db
 
-	// Create the table
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS quotations (
-		id TEXT PRIMARY KEY,
-		code TEXT,
-		codein TEXT,
-		name TEXT,
-		high TEXT,
-		low TEXT,
-		varBid TEXT,
-		pctChange TEXT,
-		bid TEXT,
-		ask TEXT,
-		timestamp TEXT,
-		create_date TEXT
-	)`)
+	// Create the schema through the embedded migrations
+	migrator, err := migrations.NewMigrator(db, database.SQLite)
+	require.NoError(suite.T(), err)
+	_, err = migrator.Up(context.Background())
 	require.NoError(suite.T(), err)
 
 	// Create dependencies
 	repository := repositories.NewQuotationsRepository(db)
 	gateway := gateways.NewQuotationGateway()
 	handler := handlers.NewQuotationHandler(gateway, repository)
+	historyHandler := handlers.NewHistoryHandler(repository)
 
 	// Create a test server
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /cotacao", handler.HandleGetQuotation)
+	mux.HandleFunc("GET /cotacao/{pair}", handler.HandleGetQuotation)
+	mux.HandleFunc("GET /cotacao/history", historyHandler.HandleGetHistory)
 	server := httptest.NewServer(mux)
 	suite.server = server
 }
@@ -80,12 +75,11 @@ func (suite *ServerIntegrationTestSuite) TestQuotationEndpoint() {
 	// Check the status code
 	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
-	require.NoError(suite.T(), err)
-
-	// The response should not be empty (exact bid value will vary)
-	assert.NotEmpty(suite.T(), string(body))
+	// Decode the response body (exact bid value will vary)
+	var quotation handlers.QuotationResponse
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&quotation))
+	assert.Equal(suite.T(), handlers.ResponseVersion, quotation.Version)
+	assert.Equal(suite.T(), gateways.DefaultPair, quotation.Pair)
 
 	// Verify that the quotation was saved in the database
 	var count int
@@ -97,7 +91,66 @@ func (suite *ServerIntegrationTestSuite) TestQuotationEndpoint() {
 	var bid string
 	err = suite.db.QueryRow("SELECT bid FROM quotations").Scan(&bid)
 	require.NoError(suite.T(), err)
-	assert.Equal(suite.T(), string(body), bid)
+	assert.Equal(suite.T(), quotation.Bid.String(), bid)
+
+	// Verify that the serving provider was recorded
+	var provider string
+	err = suite.db.QueryRow("SELECT provider FROM quotations").Scan(&provider)
+	require.NoError(suite.T(), err)
+	assert.NotEmpty(suite.T(), provider)
+}
+
+func (suite *ServerIntegrationTestSuite) TestQuotationEndpointWithPair() {
+	resp, err := http.Get(suite.server.URL + "/cotacao/EUR-BRL")
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	var quotation handlers.QuotationResponse
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&quotation))
+	assert.Equal(suite.T(), gateways.Pair("EUR-BRL"), quotation.Pair)
+
+	// Verify that the quotation was saved with the requested pair
+	var code, codein string
+	err = suite.db.QueryRow("SELECT code, codein FROM quotations WHERE bid = ?", quotation.Bid.String()).Scan(&code, &codein)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "EUR", code)
+	assert.Equal(suite.T(), "BRL", codein)
+}
+
+func (suite *ServerIntegrationTestSuite) TestQuotationEndpointWithUnsupportedPair() {
+	resp, err := http.Get(suite.server.URL + "/cotacao/XYZ-BRL")
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+
+	assert.Equal(suite.T(), http.StatusBadRequest, resp.StatusCode)
+
+	var errorResponse handlers.ErrorResponse
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&errorResponse))
+	assert.Equal(suite.T(), handlers.ErrorCodeUnsupportedPair, errorResponse.Error.Code)
+}
+
+func (suite *ServerIntegrationTestSuite) TestHistoryEndpoint() {
+	// Make sure there is at least one quotation stored
+	resp, err := http.Get(suite.server.URL + "/cotacao")
+	require.NoError(suite.T(), err)
+	resp.Body.Close()
+	require.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(suite.server.URL + "/cotacao/history?pair=USD-BRL&limit=1")
+	require.NoError(suite.T(), err)
+	defer resp.Body.Close()
+
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode)
+	assert.Equal(suite.T(), "application/json", resp.Header.Get("Content-Type"))
+
+	var history handlers.HistoryResponse
+	require.NoError(suite.T(), json.NewDecoder(resp.Body).Decode(&history))
+	assert.Equal(suite.T(), gateways.Pair("USD-BRL"), history.Pair)
+	require.Len(suite.T(), history.Quotations, 1)
+	assert.NotEmpty(suite.T(), history.Quotations[0].Bid)
+	assert.NotEmpty(suite.T(), history.NextCursor)
 }
 
 // Run the test suite
@@ -0,0 +1,193 @@
+// Package configloader carrega as opções de linha de comando do servidor e do
+// cliente a partir de um arquivo de configuração, das variáveis de ambiente e
+// dos argumentos, e imprime a configuração efetiva.
+package configloader
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Origem do valor efetivo de cada opção, da menor para a maior precedência
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Opções tratadas pelo próprio carregador, que não vão para o arquivo
+const (
+	ConfigOption      = "config"
+	PrintConfigOption = "print-config"
+)
+
+var (
+	ErrUnsupportedFile = errors.New("unsupported config file extension")
+	ErrUnknownOption   = errors.New("unknown option")
+	ErrInvalidValue    = errors.New("invalid value")
+)
+
+// EnvName devolve a variável de ambiente de uma opção: o prefixo seguido do
+// nome da opção em maiúsculas, com "_" no lugar de "-"
+// (ex.: QUOTATION_SERVER_UPSTREAM_TIMEOUT)
+func EnvName(prefix, option string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(option, "-", "_"))
+}
+
+// Load preenche as opções registradas em flags a partir do arquivo de
+// configuração, das variáveis de ambiente e dos argumentos, nessa ordem, de
+// forma que cada fonte sobrescreve a anterior. Todas as fontes passam pelo
+// mesmo flag.Value, então um valor é aceito no arquivo se e somente se é
+// aceito na linha de comando. Devolve a origem do valor de cada opção.
+func Load(flags *flag.FlagSet, envPrefix string, args []string, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	// A primeira leitura dos argumentos só descobre o arquivo e quais opções
+	// foram passadas; a segunda, no fim, faz os argumentos prevalecerem
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("%w: unexpected argument %q", ErrUnknownOption, flags.Arg(0))
+	}
+
+	fromArgs := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { fromArgs[f.Name] = true })
+
+	sources := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) { sources[f.Name] = SourceDefault })
+
+	path := flags.Lookup(ConfigOption).Value.String()
+	if path == "" {
+		path, _ = lookupEnv(EnvName(envPrefix, ConfigOption))
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, option := range sortedKeys(values) {
+			if option == ConfigOption || option == PrintConfigOption || flags.Lookup(option) == nil {
+				return nil, fmt.Errorf("%s: %w %q", path, ErrUnknownOption, option)
+			}
+			if err := flags.Set(option, values[option]); err != nil {
+				return nil, fmt.Errorf("%s: %w %q for %s: %w", path, ErrInvalidValue, values[option], option, err)
+			}
+			sources[option] = SourceFile
+		}
+	}
+
+	var errs []error
+	flags.VisitAll(func(f *flag.Flag) {
+		name := EnvName(envPrefix, f.Name)
+		value, ok := lookupEnv(name)
+		if !ok {
+			return
+		}
+		if err := flags.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("%w %q for %s: %w", ErrInvalidValue, value, name, err))
+			return
+		}
+		sources[f.Name] = SourceEnv
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	for option := range fromArgs {
+		sources[option] = SourceFlag
+	}
+
+	return sources, nil
+}
+
+// readFile lê um arquivo YAML (.yaml, .yml) ou TOML (.toml) de chaves com os
+// mesmos nomes das opções de linha de comando e valores simples
+func readFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".toml":
+		err = toml.Unmarshal(content, &raw)
+	default:
+		return nil, fmt.Errorf("%w %q: expected .yaml, .yml or .toml", ErrUnsupportedFile, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for option, value := range raw {
+		switch value.(type) {
+		case map[string]any, []any:
+			return nil, fmt.Errorf("%s: %w for %s: expected a single value", path, ErrInvalidValue, option)
+		case nil:
+			values[option] = ""
+		default:
+			values[option] = fmt.Sprint(value)
+		}
+	}
+	return values, nil
+}
+
+// PrintEffective escreve a configuração efetiva em YAML, no formato aceito por
+// -config, com a origem de cada valor em comentário. redact esconde valores
+// sensíveis.
+func PrintEffective(w io.Writer, flags *flag.FlagSet, envPrefix string, sources map[string]string, redact func(option, value string) string) error {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Name == ConfigOption || f.Name == PrintConfigOption {
+			return
+		}
+
+		value := &yaml.Node{Kind: yaml.ScalarNode, Value: redact(f.Name, f.Value.String())}
+		if getter, ok := f.Value.(flag.Getter); ok {
+			if _, isString := getter.Get().(string); isString {
+				value.Tag = "!!str"
+			}
+		}
+
+		comment := sources[f.Name]
+		if comment == SourceEnv {
+			comment += " " + EnvName(envPrefix, f.Name)
+		}
+		value.LineComment = comment
+
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.Name}, value)
+	})
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(mapping); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
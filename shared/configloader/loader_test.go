@@ -0,0 +1,223 @@
+package configloader
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Options registered on a throwaway flag set, as each Config.bind does
+type testOptions struct {
+	name    string
+	timeout time.Duration
+	retries int
+	path    string
+}
+
+func newTestFlags(options *testOptions) *flag.FlagSet {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&options.name, "name", "default-name", "")
+	flags.DurationVar(&options.timeout, "timeout", time.Second, "")
+	flags.IntVar(&options.retries, "retries", 3, "")
+	flags.StringVar(&options.path, ConfigOption, "", "")
+	flags.Bool(PrintConfigOption, false, "")
+	return flags
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "QUOTATION_SERVER_UPSTREAM_TIMEOUT", EnvName("QUOTATION_SERVER_", "upstream-timeout"))
+	assert.Equal(t, "APP_PORT", EnvName("APP_", "port"))
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", "name: from-file\ntimeout: 200ms\nretries: 5\n")
+	tomlFile := writeFile(t, "config.toml", "name = \"from-file\"\ntimeout = \"200ms\"\nretries = 5\n")
+
+	tests := []struct {
+		name            string
+		args            []string
+		env             map[string]string
+		expected        testOptions
+		expectedSources map[string]string
+	}{
+		{
+			name:            "defaults",
+			expected:        testOptions{name: "default-name", timeout: time.Second, retries: 3},
+			expectedSources: map[string]string{"name": SourceDefault, "timeout": SourceDefault, "retries": SourceDefault},
+		},
+		{
+			name:            "yaml file overrides defaults",
+			args:            []string{"-config", yamlFile},
+			expected:        testOptions{name: "from-file", timeout: 200 * time.Millisecond, retries: 5, path: yamlFile},
+			expectedSources: map[string]string{"name": SourceFile, "timeout": SourceFile, "retries": SourceFile, ConfigOption: SourceFlag},
+		},
+		{
+			name:            "toml file named in the environment",
+			env:             map[string]string{"APP_CONFIG": tomlFile},
+			expected:        testOptions{name: "from-file", timeout: 200 * time.Millisecond, retries: 5, path: tomlFile},
+			expectedSources: map[string]string{"name": SourceFile, "timeout": SourceFile, "retries": SourceFile, ConfigOption: SourceEnv},
+		},
+		{
+			name:            "environment overrides file",
+			args:            []string{"-config=" + yamlFile},
+			env:             map[string]string{"APP_TIMEOUT": "300ms"},
+			expected:        testOptions{name: "from-file", timeout: 300 * time.Millisecond, retries: 5, path: yamlFile},
+			expectedSources: map[string]string{"name": SourceFile, "timeout": SourceEnv, "retries": SourceFile},
+		},
+		{
+			name:            "flags override environment and file",
+			args:            []string{"-config", yamlFile, "-timeout", "400ms", "--retries=7"},
+			env:             map[string]string{"APP_TIMEOUT": "300ms", "APP_RETRIES": "6"},
+			expected:        testOptions{name: "from-file", timeout: 400 * time.Millisecond, retries: 7, path: yamlFile},
+			expectedSources: map[string]string{"name": SourceFile, "timeout": SourceFlag, "retries": SourceFlag},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options testOptions
+			flags := newTestFlags(&options)
+
+			sources, err := Load(flags, "APP_", tt.args, env(tt.env))
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, options)
+			for option, source := range tt.expectedSources {
+				assert.Equal(t, source, sources[option], option)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		args        []string
+		env         map[string]string
+		expectedErr error
+		contains    string
+	}{
+		{
+			name:        "unknown key in file",
+			file:        "config.yaml",
+			content:     "name: x\nbogus: 1\n",
+			expectedErr: ErrUnknownOption,
+			contains:    `"bogus"`,
+		},
+		{
+			name:        "config file cannot name another config file",
+			file:        "config.yaml",
+			content:     "config: other.yaml\n",
+			expectedErr: ErrUnknownOption,
+		},
+		{
+			name:        "invalid value in file",
+			file:        "config.toml",
+			content:     "timeout = \"soon\"\n",
+			expectedErr: ErrInvalidValue,
+			contains:    "timeout",
+		},
+		{
+			name:        "nested value in file",
+			file:        "config.yaml",
+			content:     "name:\n  first: x\n",
+			expectedErr: ErrInvalidValue,
+			contains:    "expected a single value",
+		},
+		{
+			name:        "unsupported extension",
+			file:        "config.json",
+			content:     "{}",
+			expectedErr: ErrUnsupportedFile,
+		},
+		{
+			name:        "invalid environment value",
+			env:         map[string]string{"APP_RETRIES": "many"},
+			expectedErr: ErrInvalidValue,
+			contains:    "APP_RETRIES",
+		},
+		{
+			name:     "unknown flag",
+			args:     []string{"-bogus"},
+			contains: "flag provided but not defined",
+		},
+		{
+			name:        "positional argument",
+			args:        []string{"extra"},
+			expectedErr: ErrUnknownOption,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file, tt.content)}, args...)
+			}
+
+			var options testOptions
+			_, err := Load(newTestFlags(&options), "APP_", args, env(tt.env))
+
+			require.Error(t, err)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			}
+			assert.Contains(t, err.Error(), tt.contains)
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	var options testOptions
+	_, err := Load(newTestFlags(&options), "APP_", []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, env(nil))
+
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestPrintEffective(t *testing.T) {
+	var options testOptions
+	flags := newTestFlags(&options)
+	sources, err := Load(flags, "APP_", []string{"-retries", "4"}, env(map[string]string{"APP_NAME": "secret"}))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	err = PrintEffective(&out, flags, "APP_", sources, func(option, value string) string {
+		if option == "name" {
+			return "xxxxx"
+		}
+		return value
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "name: xxxxx # env APP_NAME\nretries: 4 # flag\ntimeout: 1s # default\n", out.String())
+
+	// The output is itself a valid config file
+	path := writeFile(t, "printed.yaml", out.String())
+	values, err := readFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "xxxxx", "retries": "4", "timeout": "1s"}, values)
+}